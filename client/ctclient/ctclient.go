@@ -28,6 +28,7 @@ import (
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/client"
 	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/loglist"
 	"github.com/google/certificate-transparency-go/x509"
 	"github.com/google/certificate-transparency-go/x509util"
 	"golang.org/x/net/context"
@@ -35,6 +36,8 @@ import (
 
 var logURI = flag.String("log_uri", "http://ct.googleapis.com/aviator", "CT log base URI")
 var pubKey = flag.String("pub_key", "", "Name of file containing log's public key")
+var logList = flag.String("log_list", loglist.AllLogListURL, "Location (URL or file) of the JSON list of known logs")
+var logName = flag.String("log_name", "", "Name of the log to use, from --log_list; overrides --log_uri, and --pub_key if that is not set")
 var certChain = flag.String("cert_chain", "", "Name of file containing certificate chain as concatenated PEM files")
 var textOut = flag.Bool("text", true, "Display certificates as text")
 var getFirst = flag.Int64("first", -1, "First entry to get")
@@ -201,7 +204,21 @@ func main() {
 			ExpectContinueTimeout: 1 * time.Second,
 		},
 	}
+	ctx := context.Background()
+	uri := *logURI
 	var opts jsonclient.Options
+	if *logName != "" {
+		ll, err := loglist.Read(ctx, httpClient, *logList)
+		if err != nil {
+			log.Fatal(err)
+		}
+		l, err := ll.LogByName(*logName)
+		if err != nil {
+			log.Fatal(err)
+		}
+		uri = l.URI()
+		opts.PublicKey = l.PublicKeyPEM()
+	}
 	if *pubKey != "" {
 		pubkey, err := ioutil.ReadFile(*pubKey)
 		if err != nil {
@@ -209,7 +226,7 @@ func main() {
 		}
 		opts.PublicKey = string(pubkey)
 	}
-	logClient, err := client.New(*logURI, httpClient, opts)
+	logClient, err := client.New(uri, httpClient, opts)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -217,7 +234,6 @@ func main() {
 	if len(args) != 1 {
 		dieWithUsage("Need command argument")
 	}
-	ctx := context.Background()
 	cmd := args[0]
 	switch cmd {
 	case "sth":
@@ -20,7 +20,7 @@ import (
 	"strconv"
 
 	ct "github.com/google/certificate-transparency-go"
-	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/merkletree"
 	"golang.org/x/net/context"
 )
 
@@ -59,40 +59,68 @@ func (c *LogClient) GetEntries(ctx context.Context, start, end int64) ([]ct.LogE
 	}
 	entries := make([]ct.LogEntry, len(resp.Entries))
 	for index, entry := range resp.Entries {
-		var leaf ct.MerkleTreeLeaf
-		if rest, err := tls.Unmarshal(entry.LeafInput, &leaf); err != nil {
-			return nil, fmt.Errorf("failed to unmarshal MerkleTreeLeaf: %v", err)
-		} else if len(rest) > 0 {
-			return nil, fmt.Errorf("trailing data (%d bytes) after MerkleTreeLeaf", len(rest))
+		logEntry, err := ct.LogEntryFromLeaf(start+int64(index), &entry)
+		if err != nil {
+			return nil, err
 		}
-		entries[index].Leaf = leaf
+		entries[index] = *logEntry
+	}
+	return entries, nil
+}
 
-		var chain []ct.ASN1Cert
-		switch leaf.TimestampedEntry.EntryType {
-		case ct.X509LogEntryType:
-			var certChain ct.CertificateChain
-			if rest, err := tls.Unmarshal(entry.ExtraData, &certChain); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal ExtraData for index %d: %v", index, err)
-			} else if len(rest) > 0 {
-				return nil, fmt.Errorf("trailing data (%d bytes) after CertificateChain for index %d", len(rest), index)
-			}
-			chain = certChain.Entries
+// GetRawEntryAndProof exposes the /ct/v1/get-entry-and-proof result with only
+// the JSON parsing done.
+func (c *LogClient) GetRawEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.GetEntryAndProofResponse, error) {
+	base10 := 10
+	params := map[string]string{
+		"leaf_index": strconv.FormatUint(index, base10),
+		"tree_size":  strconv.FormatUint(treeSize, base10),
+	}
+	if ctx == nil {
+		ctx = context.TODO()
+	}
 
-		case ct.PrecertLogEntryType:
-			var precertChain ct.PrecertChainEntry
-			if rest, err := tls.Unmarshal(entry.ExtraData, &precertChain); err != nil {
-				return nil, fmt.Errorf("failed to unmarshal PrecertChainEntry: %v", err)
-			} else if len(rest) > 0 {
-				return nil, fmt.Errorf("trailing data (%d bytes) after PrecertChainEntry for index %d", len(rest), index)
-			}
-			chain = append(chain, precertChain.PreCertificate)
-			chain = append(chain, precertChain.CertificateChain...)
+	var resp ct.GetEntryAndProofResponse
+	if _, err := c.GetAndParse(ctx, ct.GetEntryAndProofPath, params, &resp); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
 
-		default:
-			return nil, fmt.Errorf("saw unknown entry type: %v", leaf.TimestampedEntry.EntryType)
-		}
-		entries[index].Chain = chain
-		entries[index].Index = start + int64(index)
+// GetEntryAndProof retrieves the entry at |index| from the CT log server,
+// along with an audit path proving its inclusion in the tree of size
+// |treeSize|. (see section 4.8.)
+// The audit path is not checked; use GetVerifiedEntryAndProof for that.
+func (c *LogClient) GetEntryAndProof(ctx context.Context, index, treeSize uint64) (*ct.LogEntry, [][]byte, error) {
+	resp, err := c.GetRawEntryAndProof(ctx, index, treeSize)
+	if err != nil {
+		return nil, nil, err
 	}
-	return entries, nil
+	entry, err := ct.LogEntryFromLeaf(int64(index), &ct.LeafEntry{LeafInput: resp.LeafInput, ExtraData: resp.ExtraData})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, resp.AuditPath, nil
+}
+
+// GetVerifiedEntryAndProof is like GetEntryAndProof, but fetches the audit
+// path for the tree described by |sth| and checks that it proves the entry's
+// inclusion under the STH's root hash.  The STH's signature is not checked.
+func (c *LogClient) GetVerifiedEntryAndProof(ctx context.Context, index uint64, sth *ct.SignedTreeHead) (*ct.LogEntry, [][]byte, error) {
+	if index >= sth.TreeSize {
+		return nil, nil, fmt.Errorf("index %d is beyond tree size %d", index, sth.TreeSize)
+	}
+	resp, err := c.GetRawEntryAndProof(ctx, index, sth.TreeSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	verifier := merkletree.NewMerkleVerifier(sha256Hash)
+	if err := verifier.VerifyInclusionProof(int64(index), int64(sth.TreeSize), resp.AuditPath, sth.SHA256RootHash[:], resp.LeafInput); err != nil {
+		return nil, nil, fmt.Errorf("failed to verify inclusion of entry %d in tree of size %d: %v", index, sth.TreeSize, err)
+	}
+	entry, err := ct.LogEntryFromLeaf(int64(index), &ct.LeafEntry{LeafInput: resp.LeafInput, ExtraData: resp.ExtraData})
+	if err != nil {
+		return nil, nil, err
+	}
+	return entry, resp.AuditPath, nil
 }
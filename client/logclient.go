@@ -27,8 +27,8 @@ import (
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/merkletree"
 	"github.com/google/certificate-transparency-go/tls"
-	"github.com/google/certificate-transparency-go/x509"
 	"golang.org/x/net/context"
 )
 
@@ -173,48 +173,13 @@ func (c *LogClient) VerifySCTSignature(sct ct.SignedCertificateTimestamp, ctype
 		return nil
 	}
 
-	// Build enough of a Merkle tree leaf for the verifier to work on.
-	leaf := ct.MerkleTreeLeaf{
-		Version:  sct.SCTVersion,
-		LeafType: ct.TimestampedEntryLeafType,
-		TimestampedEntry: &ct.TimestampedEntry{
-			Timestamp:  sct.Timestamp,
-			EntryType:  ctype,
-			Extensions: sct.Extensions,
-		},
-	}
-	if ctype == ct.X509LogEntryType {
-		leaf.TimestampedEntry.X509Entry = &certData[0]
-	} else {
-		// Pre-certs are more complicated; we need the issuer key hash and the
-		// DER-encoded TBSCertificate.  First, parse the issuer to get its
-		// public key hash.
-		if len(certData) < 2 {
-			return fmt.Errorf("no issuer cert available for precert SCT validation")
-		}
-		issuer, err := x509.ParseCertificate(certData[1].Data)
-		if err != nil {
-			return fmt.Errorf("failed to parse issuer cert: %v", err)
-		}
-		issuerKeyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
-
-		// Second, parse the pre-certificate to extract its DER-encoded
-		// TBSCertificate, then post-process this to remove the CT poison
-		// extension.
-		cert, err := x509.ParseCertificate(certData[0].Data)
-		if err != nil {
-			return fmt.Errorf("failed to parse leaf pre-cert: %v", err)
-		}
-		defangedTBS, err := x509.RemoveCTPoison(cert.RawTBSCertificate)
-		if err != nil {
-			return fmt.Errorf("failed to remove poison extension: %v", err)
-		}
-		leaf.TimestampedEntry.PrecertEntry = &ct.PreCert{
-			IssuerKeyHash:  issuerKeyHash,
-			TBSCertificate: defangedTBS,
-		}
+	leaf, err := ct.MerkleTreeLeafFromRawChain(certData, ctype, sct.Timestamp)
+	if err != nil {
+		return err
 	}
-	entry := ct.LogEntry{Leaf: leaf}
+	leaf.Version = sct.SCTVersion
+	leaf.TimestampedEntry.Extensions = sct.Extensions
+	entry := ct.LogEntry{Leaf: *leaf}
 	return c.Verifier.VerifySCTSignature(sct, entry)
 }
 
@@ -232,6 +197,79 @@ func (c *LogClient) GetSTHConsistency(ctx context.Context, first, second uint64)
 	return resp.Consistency, nil
 }
 
+// STHSignatureError indicates that the signature on an STH failed to verify.
+type STHSignatureError struct {
+	STH ct.SignedTreeHead
+	Err error
+}
+
+func (e STHSignatureError) Error() string {
+	return fmt.Sprintf("signature on STH for tree size %d failed to verify: %v", e.STH.TreeSize, e.Err)
+}
+
+// ConsistencyProofError indicates that the consistency proof returned by a log
+// for a pair of STHs failed to verify.
+type ConsistencyProofError struct {
+	First, Second ct.SignedTreeHead
+	Proof         [][]byte
+	Err           error
+}
+
+func (e ConsistencyProofError) Error() string {
+	return fmt.Sprintf("consistency proof between STHs for tree sizes %d and %d failed to verify: %v", e.First.TreeSize, e.Second.TreeSize, e.Err)
+}
+
+// LogForkError indicates that a log has signed two STHs which cannot both
+// describe the same append-only tree, i.e. that the log has forked.
+type LogForkError struct {
+	First, Second ct.SignedTreeHead
+}
+
+func (e LogForkError) Error() string {
+	return fmt.Sprintf("log fork: STHs for tree size %d have different roots %x and %x", e.First.TreeSize, e.First.SHA256RootHash, e.Second.SHA256RootHash)
+}
+
+// VerifySTHConsistency checks that the trees described by two STHs from the
+// log are consistent, i.e. that the smaller is a prefix of the larger.  The
+// signatures on both STHs are checked (if the client has a Verifier), then a
+// consistency proof is fetched from the log and verified.  The STHs may be
+// given in either order.  The proof is returned, and is empty if no proof was
+// needed because one of the trees is empty or both are the same size.
+//
+// An STHSignatureError, ConsistencyProofError or LogForkError is returned if
+// the log has produced bad data; any other error means that consistency could
+// not be checked.
+func (c *LogClient) VerifySTHConsistency(ctx context.Context, sth1, sth2 *ct.SignedTreeHead) ([][]byte, error) {
+	for _, sth := range []*ct.SignedTreeHead{sth1, sth2} {
+		if err := c.VerifySTHSignature(*sth); err != nil {
+			return nil, STHSignatureError{STH: *sth, Err: err}
+		}
+	}
+	if sth1.TreeSize > sth2.TreeSize {
+		sth1, sth2 = sth2, sth1
+	}
+	if sth1.TreeSize == sth2.TreeSize {
+		if sth1.SHA256RootHash != sth2.SHA256RootHash {
+			return nil, LogForkError{First: *sth1, Second: *sth2}
+		}
+		return nil, nil
+	}
+	if sth1.TreeSize == 0 {
+		// Any tree is consistent with the empty tree.
+		return nil, nil
+	}
+
+	proof, err := c.GetSTHConsistency(ctx, sth1.TreeSize, sth2.TreeSize)
+	if err != nil {
+		return nil, err
+	}
+	verifier := merkletree.NewMerkleVerifier(sha256Hash)
+	if err := verifier.VerifyConsistencyProof(int64(sth1.TreeSize), int64(sth2.TreeSize), sth1.SHA256RootHash[:], sth2.SHA256RootHash[:], proof); err != nil {
+		return nil, ConsistencyProofError{First: *sth1, Second: *sth2, Proof: proof, Err: err}
+	}
+	return proof, nil
+}
+
 // GetProofByHash returns an audit path for the hash of an SCT.
 func (c *LogClient) GetProofByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.GetProofByHashResponse, error) {
 	b64Hash := url.QueryEscape(base64.StdEncoding.EncodeToString(hash))
@@ -247,6 +285,48 @@ func (c *LogClient) GetProofByHash(ctx context.Context, hash []byte, treeSize ui
 	return &resp, nil
 }
 
+func sha256Hash(data []byte) []byte {
+	hash := sha256.Sum256(data)
+	return hash[:]
+}
+
+// VerifySCTInclusion checks that the entry described by an SCT and the
+// certificate chain it was issued for has been incorporated into the tree
+// described by sth, and returns the index of the entry in the log.  The STH
+// signature is checked first (if the client has a Verifier), then the audit
+// path is fetched with GetProofByHash and verified against the STH root hash.
+// For a precertificate SCT, certData must include the issuer after the leaf.
+func (c *LogClient) VerifySCTInclusion(ctx context.Context, sth *ct.SignedTreeHead, sct ct.SignedCertificateTimestamp, ctype ct.LogEntryType, certData []ct.ASN1Cert) (int64, error) {
+	if err := c.VerifySTHSignature(*sth); err != nil {
+		return -1, fmt.Errorf("failed to verify STH signature: %v", err)
+	}
+	if sct.Timestamp > sth.Timestamp {
+		return -1, fmt.Errorf("SCT timestamp %d is later than STH timestamp %d", sct.Timestamp, sth.Timestamp)
+	}
+
+	leaf, err := ct.MerkleTreeLeafFromRawChain(certData, ctype, sct.Timestamp)
+	if err != nil {
+		return -1, fmt.Errorf("failed to build Merkle tree leaf: %v", err)
+	}
+	leaf.TimestampedEntry.Extensions = sct.Extensions
+	leafData, err := tls.Marshal(*leaf)
+	if err != nil {
+		return -1, fmt.Errorf("failed to tls-encode Merkle tree leaf: %v", err)
+	}
+	// Leaf hash = SHA256(0x00 | tls-encode(MerkleTreeLeaf))
+	hash := sha256.Sum256(append([]byte{merkletree.LeafPrefix}, leafData...))
+
+	resp, err := c.GetProofByHash(ctx, hash[:], sth.TreeSize)
+	if err != nil {
+		return -1, err
+	}
+	verifier := merkletree.NewMerkleVerifier(sha256Hash)
+	if err := verifier.VerifyInclusionProof(resp.LeafIndex, int64(sth.TreeSize), resp.AuditPath, sth.SHA256RootHash[:], leafData); err != nil {
+		return -1, fmt.Errorf("failed to verify inclusion of leaf %d in tree of size %d: %v", resp.LeafIndex, sth.TreeSize, err)
+	}
+	return resp.LeafIndex, nil
+}
+
 // GetAcceptedRoots retrieves the set of acceptable root certificates for a log.
 func (c *LogClient) GetAcceptedRoots(ctx context.Context) ([]ct.ASN1Cert, error) {
 	var resp ct.GetRootsResponse
@@ -16,13 +16,16 @@ package client
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
+	"encoding/pem"
 	"fmt"
 	"math"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
 	"reflect"
 	"regexp"
 	"strconv"
@@ -32,6 +35,7 @@ import (
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/fixchain"
 	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/merkletree"
 	"github.com/google/certificate-transparency-go/testdata"
 	"github.com/google/certificate-transparency-go/tls"
 	"golang.org/x/net/context"
@@ -427,6 +431,101 @@ func TestGetSTHConsistency(t *testing.T) {
 	}
 }
 
+func TestVerifySTHConsistency(t *testing.T) {
+	tree := merkletree.NewInMemoryMerkleTree(func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	})
+	for i := 0; i < 11; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	// The log serves consistency proofs from its tree, but can be made to
+	// return a bad proof.
+	corrupt := false
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/get-sth-consistency" {
+			t.Fatalf("Incorrect URL path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		first, err := strconv.ParseUint(q.Get("first"), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid first %q: %v", q.Get("first"), err)
+		}
+		second, err := strconv.ParseUint(q.Get("second"), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid second %q: %v", q.Get("second"), err)
+		}
+		proof, err := tree.SnapshotConsistency(first, second)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if corrupt && len(proof) > 0 {
+			proof[0] = sha256Hash([]byte("bad"))
+		}
+		json.NewEncoder(w).Encode(ct.GetSTHConsistencyResponse{Consistency: proof})
+	}))
+	defer hs.Close()
+	client, err := New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	sthAt := func(size uint64) *ct.SignedTreeHead {
+		root, err := tree.RootAtSnapshot(size)
+		if err != nil {
+			t.Fatalf("RootAtSnapshot(%d) failed: %v", size, err)
+		}
+		sth := &ct.SignedTreeHead{TreeSize: size}
+		copy(sth.SHA256RootHash[:], root)
+		return sth
+	}
+	forked := sthAt(7)
+	forked.SHA256RootHash[0] ^= 0x01
+
+	tests := []struct {
+		desc       string
+		sth1, sth2 *ct.SignedTreeHead
+		corrupt    bool
+		wantErr    reflect.Type
+		wantProof  bool
+	}{
+		{desc: "consistent", sth1: sthAt(3), sth2: sthAt(11), wantProof: true},
+		{desc: "reversed", sth1: sthAt(11), sth2: sthAt(3), wantProof: true},
+		{desc: "same", sth1: sthAt(7), sth2: sthAt(7)},
+		{desc: "empty", sth1: sthAt(0), sth2: sthAt(7)},
+		{desc: "fork", sth1: sthAt(7), sth2: forked, wantErr: reflect.TypeOf(LogForkError{})},
+		{desc: "bad-root", sth1: forked, sth2: sthAt(11), wantErr: reflect.TypeOf(ConsistencyProofError{})},
+		{desc: "bad-proof", sth1: sthAt(3), sth2: sthAt(11), corrupt: true, wantErr: reflect.TypeOf(ConsistencyProofError{})},
+	}
+	for _, test := range tests {
+		corrupt = test.corrupt
+		proof, err := client.VerifySTHConsistency(context.Background(), test.sth1, test.sth2)
+		if test.wantErr != nil {
+			if got := reflect.TypeOf(err); got != test.wantErr {
+				t.Errorf("%s: VerifySTHConsistency()=_,%v (%v); want error of type %v", test.desc, err, got, test.wantErr)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: VerifySTHConsistency()=_,%v; want _,nil", test.desc, err)
+		} else if got := len(proof) > 0; got != test.wantProof {
+			t.Errorf("%s: VerifySTHConsistency() returned proof %x; want proof %v", test.desc, proof, test.wantProof)
+		}
+	}
+
+	// With a public key configured, the STH signatures are checked first.
+	client, err = New(hs.URL, &http.Client{}, jsonclient.Options{PublicKey: testdata.LogPublicKeyPEM})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := client.VerifySTHConsistency(context.Background(), sthAt(3), sthAt(11)); err == nil {
+		t.Error("VerifySTHConsistency(unsigned STHs)=_,nil; want error")
+	} else if _, ok := err.(STHSignatureError); !ok {
+		t.Errorf("VerifySTHConsistency(unsigned STHs)=_,%v; want STHSignatureError", err)
+	}
+}
+
 func TestGetProofByHash(t *testing.T) {
 	hs := ctServer(t)
 	defer hs.Close()
@@ -452,6 +551,281 @@ func TestGetProofByHash(t *testing.T) {
 	}
 }
 
+// entryAndProofServer serves get-entry-and-proof from a tree holding the
+// given leaves.
+func entryAndProofServer(t *testing.T, leaves []ct.LeafEntry) (*httptest.Server, *merkletree.InMemoryMerkleTree) {
+	tree := merkletree.NewInMemoryMerkleTree(func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	})
+	for _, leaf := range leaves {
+		tree.AddLeaf(leaf.LeafInput)
+	}
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/get-entry-and-proof" {
+			t.Fatalf("Incorrect URL path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		index, err := strconv.ParseUint(q.Get("leaf_index"), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid leaf_index %q: %v", q.Get("leaf_index"), err)
+		}
+		treeSize, err := strconv.ParseUint(q.Get("tree_size"), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid tree_size %q: %v", q.Get("tree_size"), err)
+		}
+		path, err := tree.PathToRootAtSnapshot(index, treeSize)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(ct.GetEntryAndProofResponse{
+			LeafInput: leaves[index].LeafInput,
+			ExtraData: leaves[index].ExtraData,
+			AuditPath: path,
+		})
+	}))
+	return hs, tree
+}
+
+func TestGetEntryAndProof(t *testing.T) {
+	leaves := []ct.LeafEntry{
+		{LeafInput: b64(PrecertEntryB64), ExtraData: b64(PrecertEntryExtraDataB64)},
+		{LeafInput: b64(CertEntryB64), ExtraData: b64(CertEntryExtraDataB64)},
+		{LeafInput: b64(CertEntryB64), ExtraData: b64(CertEntryExtraDataB64)},
+	}
+	hs, tree := entryAndProofServer(t, leaves)
+	defer hs.Close()
+	client, err := New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for index := range leaves {
+		entry, proof, err := client.GetEntryAndProof(context.Background(), uint64(index), uint64(len(leaves)))
+		if err != nil {
+			t.Errorf("GetEntryAndProof(%d, %d)=nil,nil,%v; want entry,proof,nil", index, len(leaves), err)
+			continue
+		}
+		if entry.Index != int64(index) {
+			t.Errorf("GetEntryAndProof(%d, %d).Index=%d; want %d", index, len(leaves), entry.Index, index)
+		}
+		if got, want := entry.Leaf.TimestampedEntry.EntryType == ct.PrecertLogEntryType, index == 0; got != want {
+			t.Errorf("GetEntryAndProof(%d, %d): got precert %v; want %v", index, len(leaves), got, want)
+		}
+		want, err := tree.PathToRootAtSnapshot(uint64(index), uint64(len(leaves)))
+		if err != nil {
+			t.Fatalf("PathToRootAtSnapshot(%d, %d) failed: %v", index, len(leaves), err)
+		}
+		if !reflect.DeepEqual(proof, want) {
+			t.Errorf("GetEntryAndProof(%d, %d) proof=%x; want %x", index, len(leaves), proof, want)
+		}
+	}
+
+	if _, _, err := client.GetEntryAndProof(context.Background(), 3, uint64(len(leaves))); err == nil {
+		t.Errorf("GetEntryAndProof(3, %d)=_,_,nil; want error", len(leaves))
+	}
+}
+
+func TestGetVerifiedEntryAndProof(t *testing.T) {
+	leaves := []ct.LeafEntry{
+		{LeafInput: b64(PrecertEntryB64), ExtraData: b64(PrecertEntryExtraDataB64)},
+		{LeafInput: b64(CertEntryB64), ExtraData: b64(CertEntryExtraDataB64)},
+		{LeafInput: b64(CertEntryB64), ExtraData: b64(CertEntryExtraDataB64)},
+	}
+	hs, tree := entryAndProofServer(t, leaves)
+	defer hs.Close()
+	client, err := New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	for size := uint64(1); size <= uint64(len(leaves)); size++ {
+		root, err := tree.RootAtSnapshot(size)
+		if err != nil {
+			t.Fatalf("RootAtSnapshot(%d) failed: %v", size, err)
+		}
+		sth := ct.SignedTreeHead{TreeSize: size}
+		copy(sth.SHA256RootHash[:], root)
+		for index := uint64(0); index < size; index++ {
+			if _, _, err := client.GetVerifiedEntryAndProof(context.Background(), index, &sth); err != nil {
+				t.Errorf("GetVerifiedEntryAndProof(%d, size=%d)=nil,nil,%v; want entry,proof,nil", index, size, err)
+			}
+		}
+	}
+
+	sth := ct.SignedTreeHead{TreeSize: uint64(len(leaves))}
+	copy(sth.SHA256RootHash[:], dh("4a9e8edbe5ce2d2da69d483edb45186675d4be37b649d40923b156a7d1277463"))
+	if _, _, err := client.GetVerifiedEntryAndProof(context.Background(), 1, &sth); err == nil {
+		t.Error("GetVerifiedEntryAndProof() against wrong root=_,_,nil; want error")
+	}
+	if _, _, err := client.GetVerifiedEntryAndProof(context.Background(), uint64(len(leaves)), &sth); err == nil {
+		t.Error("GetVerifiedEntryAndProof() beyond tree size=_,_,nil; want error")
+	}
+}
+
+func pemChain(t *testing.T, pems ...string) []ct.ASN1Cert {
+	var chain []ct.ASN1Cert
+	for _, data := range pems {
+		block, _ := pem.Decode([]byte(data))
+		if block == nil {
+			t.Fatalf("Failed to decode PEM certificate")
+		}
+		chain = append(chain, ct.ASN1Cert{Data: block.Bytes})
+	}
+	return chain
+}
+
+func TestVerifySCTSignature(t *testing.T) {
+	client, err := New("http://localhost", &http.Client{}, jsonclient.Options{PublicKey: testdata.LogPublicKeyPEM})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	tests := []struct {
+		desc  string
+		proof []byte
+		ctype ct.LogEntryType
+		chain []ct.ASN1Cert
+		ok    bool
+	}{
+		{"cert", testdata.TestCertProof, ct.X509LogEntryType, pemChain(t, testdata.TestCertPEM, testdata.CACertPEM), true},
+		{"precert", testdata.TestPreCertProof, ct.PrecertLogEntryType, pemChain(t, testdata.TestPreCertPEM, testdata.CACertPEM), true},
+		{"precert-no-issuer", testdata.TestPreCertProof, ct.PrecertLogEntryType, pemChain(t, testdata.TestPreCertPEM), false},
+		{"wrong-sct", testdata.TestCertProof, ct.PrecertLogEntryType, pemChain(t, testdata.TestPreCertPEM, testdata.CACertPEM), false},
+	}
+	for _, test := range tests {
+		var sct ct.SignedCertificateTimestamp
+		if _, err := tls.Unmarshal(test.proof, &sct); err != nil {
+			t.Fatalf("%s: failed to unmarshal SCT: %v", test.desc, err)
+		}
+		err := client.VerifySCTSignature(sct, test.ctype, test.chain)
+		if test.ok && err != nil {
+			t.Errorf("%s: VerifySCTSignature()=%v; want nil", test.desc, err)
+		} else if !test.ok && err == nil {
+			t.Errorf("%s: VerifySCTSignature()=nil; want error", test.desc)
+		}
+	}
+}
+
+func TestVerifySCTInclusion(t *testing.T) {
+	var certSCT, precertSCT ct.SignedCertificateTimestamp
+	if _, err := tls.Unmarshal(testdata.TestCertProof, &certSCT); err != nil {
+		t.Fatalf("Failed to unmarshal SCT: %v", err)
+	}
+	if _, err := tls.Unmarshal(testdata.TestPreCertProof, &precertSCT); err != nil {
+		t.Fatalf("Failed to unmarshal SCT: %v", err)
+	}
+	certChain := pemChain(t, testdata.TestCertPEM, testdata.CACertPEM)
+	precertChain := pemChain(t, testdata.TestPreCertPEM, testdata.CACertPEM)
+
+	// Build a log holding both entries, surrounded by some other leaves.
+	tree := merkletree.NewInMemoryMerkleTree(func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	})
+	tree.AddLeaf([]byte("filler 0"))
+	for _, entry := range []struct {
+		sct   ct.SignedCertificateTimestamp
+		ctype ct.LogEntryType
+		chain []ct.ASN1Cert
+	}{
+		{certSCT, ct.X509LogEntryType, certChain},
+		{precertSCT, ct.PrecertLogEntryType, precertChain},
+	} {
+		leaf, err := ct.MerkleTreeLeafFromRawChain(entry.chain, entry.ctype, entry.sct.Timestamp)
+		if err != nil {
+			t.Fatalf("MerkleTreeLeafFromRawChain() failed: %v", err)
+		}
+		data, err := tls.Marshal(*leaf)
+		if err != nil {
+			t.Fatalf("Failed to marshal leaf: %v", err)
+		}
+		tree.AddLeaf(data)
+	}
+	tree.AddLeaf([]byte("filler 3"))
+
+	hs := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ct/v1/get-proof-by-hash" {
+			t.Fatalf("Incorrect URL path: %s", r.URL.Path)
+		}
+		q := r.URL.Query()
+		// The client escapes the hash itself before it goes into the query
+		// string, so it needs unescaping again (as done by the CTFE).
+		b64Hash, err := url.QueryUnescape(q.Get("hash"))
+		if err != nil {
+			t.Fatalf("Invalid escaped hash %q: %v", q.Get("hash"), err)
+		}
+		hash, err := base64.StdEncoding.DecodeString(b64Hash)
+		if err != nil {
+			t.Fatalf("Invalid hash %q: %v", b64Hash, err)
+		}
+		treeSize, err := strconv.ParseUint(q.Get("tree_size"), 10, 64)
+		if err != nil {
+			t.Fatalf("Invalid tree_size %q: %v", q.Get("tree_size"), err)
+		}
+		for i := uint64(0); i < treeSize; i++ {
+			leafHash, err := tree.LeafHash(i)
+			if err != nil || !bytes.Equal(leafHash, hash) {
+				continue
+			}
+			path, err := tree.PathToRootAtSnapshot(i, treeSize)
+			if err != nil {
+				t.Fatalf("PathToRootAtSnapshot(%d, %d) failed: %v", i, treeSize, err)
+			}
+			json.NewEncoder(w).Encode(ct.GetProofByHashResponse{LeafIndex: int64(i), AuditPath: path})
+			return
+		}
+		http.Error(w, "hash not found", http.StatusNotFound)
+	}))
+	defer hs.Close()
+	client, err := New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+
+	root, err := tree.CurrentRoot()
+	if err != nil {
+		t.Fatalf("CurrentRoot() failed: %v", err)
+	}
+	sth := ct.SignedTreeHead{TreeSize: tree.LeafCount(), Timestamp: precertSCT.Timestamp + 1000}
+	copy(sth.SHA256RootHash[:], root)
+	badRootSTH := sth
+	badRootSTH.SHA256RootHash[0] ^= 0x01
+	oldSTH := sth
+	oldSTH.Timestamp = certSCT.Timestamp - 1
+
+	tests := []struct {
+		desc      string
+		sth       ct.SignedTreeHead
+		sct       ct.SignedCertificateTimestamp
+		ctype     ct.LogEntryType
+		chain     []ct.ASN1Cert
+		wantIndex int64
+		wantErr   bool
+	}{
+		{desc: "cert", sth: sth, sct: certSCT, ctype: ct.X509LogEntryType, chain: certChain, wantIndex: 1},
+		{desc: "precert", sth: sth, sct: precertSCT, ctype: ct.PrecertLogEntryType, chain: precertChain, wantIndex: 2},
+		{desc: "precert-no-issuer", sth: sth, sct: precertSCT, ctype: ct.PrecertLogEntryType, chain: precertChain[:1], wantErr: true},
+		{desc: "wrong-type", sth: sth, sct: precertSCT, ctype: ct.X509LogEntryType, chain: precertChain, wantErr: true},
+		{desc: "bad-root", sth: badRootSTH, sct: certSCT, ctype: ct.X509LogEntryType, chain: certChain, wantErr: true},
+		{desc: "sth-too-old", sth: oldSTH, sct: certSCT, ctype: ct.X509LogEntryType, chain: certChain, wantErr: true},
+	}
+	for _, test := range tests {
+		index, err := client.VerifySCTInclusion(context.Background(), &test.sth, test.sct, test.ctype, test.chain)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: VerifySCTInclusion()=%d,nil; want error", test.desc, index)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: VerifySCTInclusion()=_,%v; want %d,nil", test.desc, err, test.wantIndex)
+		} else if index != test.wantIndex {
+			t.Errorf("%s: VerifySCTInclusion()=%d,nil; want %d,nil", test.desc, index, test.wantIndex)
+		}
+	}
+}
+
 func TestGetAcceptedRoots(t *testing.T) {
 	hs := ctServer(t)
 	defer hs.Close()
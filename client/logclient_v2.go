@@ -0,0 +1,369 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+// This file holds client methods for the CT v2 API described in RFC 9162;
+// section numbers in comments refer to that RFC.
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/merkletree"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/net/context"
+)
+
+// SubmitEntry submits a certificate or (CMS-encoded) precertificate to a v2
+// log, along with the chain of certificates from its issuer towards a trust
+// anchor.  (see section 5.1.)
+//
+// For a certificate submission the SCT signature is checked (if the client
+// has a Verifier), using an x509_entry_v2 built from the submission and the
+// first certificate of chain.  A precertificate SCT is not checked, as that
+// would need the CMS object to be parsed; use VerifySCTV2Signature for that.
+// Any STH in the response has its signature checked.
+func (c *LogClient) SubmitEntry(ctx context.Context, stype ct.SubmissionType, submission []byte, chain []ct.ASN1Cert) (*ct.SubmitEntryV2Response, error) {
+	sctType, err := stype.SCTType()
+	if err != nil {
+		return nil, err
+	}
+	req := ct.SubmitEntryV2Request{Submission: submission, Type: stype}
+	for _, link := range chain {
+		req.Chain = append(req.Chain, link.Data)
+	}
+
+	var resp ct.SubmitEntryV2Response
+	if _, err := c.PostAndParseWithRetry(ctx, ct.SubmitEntryV2Path, &req, &resp); err != nil {
+		return nil, err
+	}
+	if resp.SCT.VersionedType != sctType {
+		return nil, fmt.Errorf("got %v in response to %v; want %v", resp.SCT.VersionedType, stype, sctType)
+	}
+	sct := resp.SCT.SCT()
+	if sct == nil {
+		return nil, errors.New("missing SCT in response")
+	}
+	if c.Verifier != nil && stype == ct.X509SubmissionType && len(chain) > 0 {
+		entry, err := x509EntryV2(submission, chain[0].Data, sct)
+		if err != nil {
+			return nil, err
+		}
+		if err := c.VerifySCTV2Signature(*sct, *entry); err != nil {
+			return nil, err
+		}
+	}
+	if resp.STH != nil {
+		if _, err := c.signedTreeHeadV2(*resp.STH); err != nil {
+			return nil, err
+		}
+	}
+	if resp.Inclusion != nil && resp.Inclusion.VersionedType != ct.InclusionProofV2VersionedType {
+		return nil, fmt.Errorf("got %v for inclusion proof", resp.Inclusion.VersionedType)
+	}
+	return &resp, nil
+}
+
+// x509EntryV2 builds the x509_entry_v2 TransItem that a v2 SCT for the
+// DER-encoded certificate is signed over (section 4.7).
+func x509EntryV2(certData, issuerData []byte, sct *ct.SignedCertificateTimestampDataV2) (*ct.TransItem, error) {
+	cert, err := x509.ParseCertificate(certData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse submission: %v", err)
+	}
+	issuer, err := x509.ParseCertificate(issuerData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse issuer: %v", err)
+	}
+	keyHash := sha256.Sum256(issuer.RawSubjectPublicKeyInfo)
+	return ct.NewEntryTransItem(ct.X509SCTV2VersionedType, ct.TimestampedCertificateEntryDataV2{
+		Timestamp:      sct.Timestamp,
+		IssuerKeyHash:  keyHash[:],
+		TBSCertificate: cert.RawTBSCertificate,
+		SCTExtensions:  sct.SCTExtensions,
+	})
+}
+
+// GetSTHV2 retrieves the current STH from a v2 log, and checks its signature
+// (if the client has a Verifier).  (see section 5.2.)
+func (c *LogClient) GetSTHV2(ctx context.Context) (*ct.SignedTreeHeadDataV2, error) {
+	var resp ct.GetSTHV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetSTHV2Path, nil, &resp); err != nil {
+		return nil, err
+	}
+	return c.signedTreeHeadV2(resp.STH)
+}
+
+// signedTreeHeadV2 extracts the STH from a signed_tree_head_v2 TransItem, and
+// checks its signature.
+func (c *LogClient) signedTreeHeadV2(item ct.TransItem) (*ct.SignedTreeHeadDataV2, error) {
+	if item.VersionedType != ct.SignedTreeHeadV2VersionedType || item.SignedTreeHeadV2 == nil {
+		return nil, fmt.Errorf("got %v for STH", item.VersionedType)
+	}
+	sth := item.SignedTreeHeadV2
+	if err := c.VerifySTHV2Signature(*sth); err != nil {
+		return nil, STHV2SignatureError{STH: *sth, Err: err}
+	}
+	return sth, nil
+}
+
+// VerifySTHV2Signature checks the signature in a v2 STH, returning any error
+// encountered or nil if verification is successful.
+func (c *LogClient) VerifySTHV2Signature(sth ct.SignedTreeHeadDataV2) error {
+	if c.Verifier == nil {
+		// Can't verify signatures without a verifier
+		return nil
+	}
+	return c.Verifier.VerifySTHV2Signature(sth)
+}
+
+// VerifySCTV2Signature checks the signature in a v2 SCT over entry, which
+// should be an x509_entry_v2 or precert_entry_v2 TransItem.
+func (c *LogClient) VerifySCTV2Signature(sct ct.SignedCertificateTimestampDataV2, entry ct.TransItem) error {
+	if c.Verifier == nil {
+		// Can't verify signatures without a verifier
+		return nil
+	}
+	return c.Verifier.VerifySCTV2Signature(sct, entry)
+}
+
+// STHV2SignatureError indicates that the signature on a v2 STH failed to
+// verify.
+type STHV2SignatureError struct {
+	STH ct.SignedTreeHeadDataV2
+	Err error
+}
+
+func (e STHV2SignatureError) Error() string {
+	return fmt.Sprintf("signature on v2 STH for tree size %d failed to verify: %v", e.STH.TreeHead.TreeSize, e.Err)
+}
+
+// GetSTHConsistencyV2 retrieves a consistency proof between two tree sizes
+// from a v2 log, along with the log's current STH (whose signature is
+// checked if the client has a Verifier).  The proof is not checked; use
+// VerifyConsistencyProofV2 for that.  (see section 5.3.)
+func (c *LogClient) GetSTHConsistencyV2(ctx context.Context, first, second uint64) (*ct.ConsistencyProofDataV2, *ct.SignedTreeHeadDataV2, error) {
+	base10 := 10
+	params := map[string]string{
+		"first":  strconv.FormatUint(first, base10),
+		"second": strconv.FormatUint(second, base10),
+	}
+	var resp ct.GetSTHConsistencyV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetSTHConsistencyV2Path, params, &resp); err != nil {
+		return nil, nil, err
+	}
+	proof, err := consistencyProofV2(resp.Consistency, first, second)
+	if err != nil {
+		return nil, nil, err
+	}
+	sth, err := c.signedTreeHeadV2(resp.STH)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, sth, nil
+}
+
+func consistencyProofV2(item ct.TransItem, first, second uint64) (*ct.ConsistencyProofDataV2, error) {
+	if item.VersionedType != ct.ConsistencyProofV2VersionedType || item.ConsistencyProofV2 == nil {
+		return nil, fmt.Errorf("got %v for consistency proof", item.VersionedType)
+	}
+	proof := item.ConsistencyProofV2
+	if proof.TreeSize1 != first || proof.TreeSize2 != second {
+		return nil, fmt.Errorf("got consistency proof between tree sizes %d and %d; want %d and %d", proof.TreeSize1, proof.TreeSize2, first, second)
+	}
+	return proof, nil
+}
+
+// GetProofByHashV2 retrieves an inclusion proof for the entry with the given
+// leaf hash from a v2 log, in the tree of size treeSize, along with the log's
+// current STH (whose signature is checked if the client has a Verifier).
+// The proof is not checked; use VerifyInclusionProofV2 for that.
+// (see section 5.4.)
+func (c *LogClient) GetProofByHashV2(ctx context.Context, hash []byte, treeSize uint64) (*ct.InclusionProofDataV2, *ct.SignedTreeHeadDataV2, error) {
+	var resp ct.GetProofByHashV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetProofByHashV2Path, hashParams(hash, treeSize), &resp); err != nil {
+		return nil, nil, err
+	}
+	proof, err := inclusionProofV2(resp.Inclusion, treeSize)
+	if err != nil {
+		return nil, nil, err
+	}
+	sth, err := c.signedTreeHeadV2(resp.STH)
+	if err != nil {
+		return nil, nil, err
+	}
+	return proof, sth, nil
+}
+
+// GetAllByHash retrieves an inclusion proof for the entry with the given
+// leaf hash from a v2 log, in the tree described by the log's current STH,
+// along with that STH and a consistency proof from the tree of size
+// treeSize.  The STH signature is checked if the client has a Verifier,
+// but the proofs are not.  (see section 5.5.)
+func (c *LogClient) GetAllByHash(ctx context.Context, hash []byte, treeSize uint64) (*ct.InclusionProofDataV2, *ct.ConsistencyProofDataV2, *ct.SignedTreeHeadDataV2, error) {
+	var resp ct.GetAllByHashV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetAllByHashV2Path, hashParams(hash, treeSize), &resp); err != nil {
+		return nil, nil, nil, err
+	}
+	sth, err := c.signedTreeHeadV2(resp.STH)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	inclusion, err := inclusionProofV2(resp.Inclusion, sth.TreeHead.TreeSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	consistency, err := consistencyProofV2(resp.Consistency, treeSize, sth.TreeHead.TreeSize)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return inclusion, consistency, sth, nil
+}
+
+func hashParams(hash []byte, treeSize uint64) map[string]string {
+	base10 := 10
+	return map[string]string{
+		"hash":      base64.StdEncoding.EncodeToString(hash),
+		"tree_size": strconv.FormatUint(treeSize, base10),
+	}
+}
+
+func inclusionProofV2(item ct.TransItem, treeSize uint64) (*ct.InclusionProofDataV2, error) {
+	if item.VersionedType != ct.InclusionProofV2VersionedType || item.InclusionProofV2 == nil {
+		return nil, fmt.Errorf("got %v for inclusion proof", item.VersionedType)
+	}
+	proof := item.InclusionProofV2
+	if proof.TreeSize != treeSize {
+		return nil, fmt.Errorf("got inclusion proof for tree size %d; want %d", proof.TreeSize, treeSize)
+	}
+	return proof, nil
+}
+
+// GetEntriesV2 retrieves the entries in the sequence [start, end] from a v2
+// log, along with the log's current STH.  The signatures on the STH and on
+// each entry's SCT are checked if the client has a Verifier.
+// (see section 5.6.)
+func (c *LogClient) GetEntriesV2(ctx context.Context, start, end uint64) ([]ct.EntryV2, *ct.SignedTreeHeadDataV2, error) {
+	if end < start {
+		return nil, nil, errors.New("start should be <= end")
+	}
+	base10 := 10
+	params := map[string]string{
+		"start": strconv.FormatUint(start, base10),
+		"end":   strconv.FormatUint(end, base10),
+	}
+	var resp ct.GetEntriesV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetEntriesV2Path, params, &resp); err != nil {
+		return nil, nil, err
+	}
+	if uint64(len(resp.Entries)) > end-start+1 {
+		return nil, nil, fmt.Errorf("got %d entries; want at most %d", len(resp.Entries), end-start+1)
+	}
+	for i, entry := range resp.Entries {
+		index := start + uint64(i)
+		if entry.LogEntry.Entry() == nil {
+			return nil, nil, fmt.Errorf("got %v for entry %d", entry.LogEntry.VersionedType, index)
+		}
+		sct := entry.SCT.SCT()
+		if sct == nil {
+			return nil, nil, fmt.Errorf("got %v for SCT of entry %d", entry.SCT.VersionedType, index)
+		}
+		if err := c.VerifySCTV2Signature(*sct, entry.LogEntry); err != nil {
+			return nil, nil, fmt.Errorf("failed to verify SCT for entry %d: %v", index, err)
+		}
+	}
+	sth, err := c.signedTreeHeadV2(resp.STH)
+	if err != nil {
+		return nil, nil, err
+	}
+	return resp.Entries, sth, nil
+}
+
+// GetAnchors retrieves the trust anchors accepted by a v2 log, and the
+// maximum length of submitted chains (or zero if there is no limit).
+// (see section 5.7.)
+func (c *LogClient) GetAnchors(ctx context.Context) ([]ct.ASN1Cert, int, error) {
+	var resp ct.GetAnchorsV2Response
+	if _, err := c.GetAndParse(ctx, ct.GetAnchorsV2Path, nil, &resp); err != nil {
+		return nil, 0, err
+	}
+	var anchors []ct.ASN1Cert
+	for _, cert := range resp.Certificates {
+		anchors = append(anchors, ct.ASN1Cert{Data: cert})
+	}
+	return anchors, resp.MaxChainLength, nil
+}
+
+// LeafHashV2 returns the Merkle tree leaf hash for a v2 log entry, which
+// should be an x509_entry_v2 or precert_entry_v2 TransItem.  This is the
+// hash used to request proofs with GetProofByHashV2 and GetAllByHash.
+func LeafHashV2(entry ct.TransItem) ([]byte, error) {
+	if entry.Entry() == nil {
+		return nil, fmt.Errorf("cannot hash TransItem of type %v", entry.VersionedType)
+	}
+	leafData, err := tls.Marshal(entry)
+	if err != nil {
+		return nil, fmt.Errorf("failed to tls-encode entry: %v", err)
+	}
+	return merkletree.NewTreeHasher(sha256Hash).HashLeaf(leafData), nil
+}
+
+// VerifyInclusionProofV2 checks that proof shows the inclusion of entry, an
+// x509_entry_v2 or precert_entry_v2 TransItem, in the tree described by sth.
+// The STH signature is not checked.
+func VerifyInclusionProofV2(proof *ct.InclusionProofDataV2, sth *ct.SignedTreeHeadDataV2, entry ct.TransItem) error {
+	if proof.TreeSize != sth.TreeHead.TreeSize {
+		return fmt.Errorf("inclusion proof is for tree size %d, but STH has tree size %d", proof.TreeSize, sth.TreeHead.TreeSize)
+	}
+	if entry.Entry() == nil {
+		return fmt.Errorf("cannot check inclusion of TransItem of type %v", entry.VersionedType)
+	}
+	leafData, err := tls.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("failed to tls-encode entry: %v", err)
+	}
+	verifier := merkletree.NewMerkleVerifier(sha256Hash)
+	if err := verifier.VerifyInclusionProof(int64(proof.LeafIndex), int64(proof.TreeSize), nodeHashes(proof.InclusionPath), sth.TreeHead.RootHash.Value, leafData); err != nil {
+		return fmt.Errorf("failed to verify inclusion of leaf %d in tree of size %d: %v", proof.LeafIndex, proof.TreeSize, err)
+	}
+	return nil
+}
+
+// VerifyConsistencyProofV2 checks that proof shows that the tree described
+// by sth1 is a prefix of the tree described by sth2.  The STH signatures are
+// not checked.
+func VerifyConsistencyProofV2(proof *ct.ConsistencyProofDataV2, sth1, sth2 *ct.SignedTreeHeadDataV2) error {
+	if proof.TreeSize1 != sth1.TreeHead.TreeSize || proof.TreeSize2 != sth2.TreeHead.TreeSize {
+		return fmt.Errorf("consistency proof is between tree sizes %d and %d, but STHs have tree sizes %d and %d", proof.TreeSize1, proof.TreeSize2, sth1.TreeHead.TreeSize, sth2.TreeHead.TreeSize)
+	}
+	verifier := merkletree.NewMerkleVerifier(sha256Hash)
+	if err := verifier.VerifyConsistencyProof(int64(proof.TreeSize1), int64(proof.TreeSize2), sth1.TreeHead.RootHash.Value, sth2.TreeHead.RootHash.Value, nodeHashes(proof.ConsistencyPath)); err != nil {
+		return fmt.Errorf("failed to verify consistency between tree sizes %d and %d: %v", proof.TreeSize1, proof.TreeSize2, err)
+	}
+	return nil
+}
+
+func nodeHashes(path []ct.NodeHash) [][]byte {
+	hashes := make([][]byte, len(path))
+	for i, h := range path {
+		hashes[i] = h.Value
+	}
+	return hashes
+}
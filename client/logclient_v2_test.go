@@ -0,0 +1,460 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package client
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/merkletree"
+	"github.com/google/certificate-transparency-go/testdata"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/net/context"
+)
+
+// testLogV2 is a minimal in-memory v2 log, signing with the Ed25519 test key.
+type testLogV2 struct {
+	t       *testing.T
+	key     crypto.PrivateKey
+	tree    *merkletree.InMemoryMerkleTree
+	entries []ct.EntryV2
+	// badSig makes the log corrupt the signatures on new SCTs and STHs.
+	badSig bool
+}
+
+var testLogIDV2 = ct.LogIDV2{Value: []byte{0x2b, 0x06, 0x01, 0x04, 0x01, 0xd6, 0x79}} // 1.3.6.1.4.1.11129
+
+func newTestLogV2(t *testing.T) *testLogV2 {
+	block, _ := pem.Decode([]byte(testdata.Ed25519PrivateKeyPEM))
+	if block == nil {
+		t.Fatal("Failed to decode Ed25519 private key PEM")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse Ed25519 private key: %v", err)
+	}
+	return &testLogV2{t: t, key: key, tree: merkletree.NewInMemoryMerkleTree(sha256Hash)}
+}
+
+func (l *testLogV2) sign(data []byte) []byte {
+	ds, err := tls.CreateSignature(l.key, tls.SHA256, data)
+	if err != nil {
+		l.t.Fatalf("Failed to sign: %v", err)
+	}
+	return ds.Signature
+}
+
+// add logs an x509 submission, returning its SCT.
+func (l *testLogV2) add(chain []ct.ASN1Cert) ct.TransItem {
+	sct := ct.SignedCertificateTimestampDataV2{LogID: testLogIDV2, Timestamp: uint64(1000 + len(l.entries)), SCTExtensions: []ct.Extension{}}
+	entry, err := x509EntryV2(chain[0].Data, chain[1].Data, &sct)
+	if err != nil {
+		l.t.Fatalf("Failed to build entry: %v", err)
+	}
+	data, err := ct.SerializeSCTV2SignatureInput(*entry)
+	if err != nil {
+		l.t.Fatalf("Failed to serialize entry: %v", err)
+	}
+	sct.Signature = l.sign(data)
+	if l.badSig {
+		sct.Signature[0] ^= 0xff
+	}
+	l.tree.AddLeaf(data)
+	item := ct.TransItem{VersionedType: ct.X509SCTV2VersionedType, X509SCTV2: &sct}
+	l.entries = append(l.entries, ct.EntryV2{
+		LogEntry:       *entry,
+		SubmittedEntry: ct.SubmitEntryV2Request{Submission: chain[0].Data, Type: ct.X509SubmissionType, Chain: [][]byte{chain[1].Data}},
+		SCT:            item,
+	})
+	return item
+}
+
+func (l *testLogV2) sth() ct.TransItem {
+	size := l.tree.LeafCount()
+	root, err := l.tree.RootAtSnapshot(size)
+	if err != nil {
+		l.t.Fatalf("Failed to get root: %v", err)
+	}
+	sth := ct.SignedTreeHeadDataV2{
+		LogID: testLogIDV2,
+		TreeHead: ct.TreeHeadDataV2{
+			Timestamp:     5000,
+			TreeSize:      size,
+			RootHash:      ct.NodeHash{Value: root},
+			STHExtensions: []ct.Extension{},
+		},
+	}
+	data, err := ct.SerializeSTHV2SignatureInput(sth)
+	if err != nil {
+		l.t.Fatalf("Failed to serialize STH: %v", err)
+	}
+	sth.Signature = l.sign(data)
+	if l.badSig {
+		sth.Signature[0] ^= 0xff
+	}
+	return ct.TransItem{VersionedType: ct.SignedTreeHeadV2VersionedType, SignedTreeHeadV2: &sth}
+}
+
+func (l *testLogV2) inclusion(hash string, treeSize uint64) (ct.TransItem, error) {
+	leafHash, err := base64.StdEncoding.DecodeString(hash)
+	if err != nil {
+		return ct.TransItem{}, err
+	}
+	for i := uint64(0); i < treeSize; i++ {
+		if h, _ := l.tree.LeafHash(i); bytes.Equal(h, leafHash) {
+			path, err := l.tree.PathToRootAtSnapshot(i, treeSize)
+			if err != nil {
+				return ct.TransItem{}, err
+			}
+			return ct.TransItem{
+				VersionedType: ct.InclusionProofV2VersionedType,
+				InclusionProofV2: &ct.InclusionProofDataV2{
+					LogID:         testLogIDV2,
+					TreeSize:      treeSize,
+					LeafIndex:     i,
+					InclusionPath: toNodeHashes(path),
+				},
+			}, nil
+		}
+	}
+	return ct.TransItem{}, errors.New("hash not found")
+}
+
+func (l *testLogV2) consistency(first, second uint64) (ct.TransItem, error) {
+	proof, err := l.tree.SnapshotConsistency(first, second)
+	if err != nil {
+		return ct.TransItem{}, err
+	}
+	return ct.TransItem{
+		VersionedType: ct.ConsistencyProofV2VersionedType,
+		ConsistencyProofV2: &ct.ConsistencyProofDataV2{
+			LogID:           testLogIDV2,
+			TreeSize1:       first,
+			TreeSize2:       second,
+			ConsistencyPath: toNodeHashes(proof),
+		},
+	}, nil
+}
+
+func toNodeHashes(path [][]byte) []ct.NodeHash {
+	hashes := []ct.NodeHash{}
+	for _, h := range path {
+		hashes = append(hashes, ct.NodeHash{Value: h})
+	}
+	return hashes
+}
+
+func (l *testLogV2) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	uintParam := func(name string) uint64 {
+		v, _ := strconv.ParseUint(q.Get(name), 10, 64)
+		return v
+	}
+	var rsp interface{}
+	var err error
+	switch r.URL.Path {
+	case ct.SubmitEntryV2Path:
+		var req ct.SubmitEntryV2Request
+		if err = json.NewDecoder(r.Body).Decode(&req); err != nil {
+			break
+		}
+		chain := []ct.ASN1Cert{{Data: req.Submission}}
+		for _, c := range req.Chain {
+			chain = append(chain, ct.ASN1Cert{Data: c})
+		}
+		rsp = ct.SubmitEntryV2Response{SCT: l.add(chain)}
+	case ct.GetSTHV2Path:
+		rsp = ct.GetSTHV2Response{STH: l.sth()}
+	case ct.GetSTHConsistencyV2Path:
+		var proof ct.TransItem
+		proof, err = l.consistency(uintParam("first"), uintParam("second"))
+		rsp = ct.GetSTHConsistencyV2Response{Consistency: proof, STH: l.sth()}
+	case ct.GetProofByHashV2Path:
+		var proof ct.TransItem
+		proof, err = l.inclusion(q.Get("hash"), uintParam("tree_size"))
+		rsp = ct.GetProofByHashV2Response{Inclusion: proof, STH: l.sth()}
+	case ct.GetAllByHashV2Path:
+		var inclusion, consistency ct.TransItem
+		if inclusion, err = l.inclusion(q.Get("hash"), l.tree.LeafCount()); err != nil {
+			break
+		}
+		consistency, err = l.consistency(uintParam("tree_size"), l.tree.LeafCount())
+		rsp = ct.GetAllByHashV2Response{Inclusion: inclusion, STH: l.sth(), Consistency: consistency}
+	case ct.GetEntriesV2Path:
+		start, end := uintParam("start"), uintParam("end")
+		if end >= uint64(len(l.entries)) {
+			end = uint64(len(l.entries)) - 1
+		}
+		rsp = ct.GetEntriesV2Response{Entries: l.entries[start : end+1], STH: l.sth()}
+	case ct.GetAnchorsV2Path:
+		rsp = ct.GetAnchorsV2Response{Certificates: [][]byte{pemChain(l.t, testdata.CACertPEM)[0].Data}, MaxChainLength: 3}
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	json.NewEncoder(w).Encode(rsp)
+}
+
+func testChainV2(t *testing.T) []ct.ASN1Cert {
+	return pemChain(t, testdata.TestCertPEM, testdata.CACertPEM)
+}
+
+func newTestClientV2(t *testing.T, hs *httptest.Server) *LogClient {
+	client, err := New(hs.URL, &http.Client{}, jsonclient.Options{PublicKey: testdata.Ed25519PublicKeyPEM})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	return client
+}
+
+func TestSubmitEntry(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	chain := testChainV2(t)
+	resp, err := client.SubmitEntry(ctx, ct.X509SubmissionType, chain[0].Data, chain[1:])
+	if err != nil {
+		t.Fatalf("SubmitEntry()=nil,%v; want resp,nil", err)
+	}
+	if got := resp.SCT.SCT(); got == nil || got.Timestamp != 1000 {
+		t.Errorf("SubmitEntry().SCT=%+v; want timestamp 1000", got)
+	}
+
+	l.badSig = true
+	if _, err := client.SubmitEntry(ctx, ct.X509SubmissionType, chain[0].Data, chain[1:]); err == nil {
+		t.Error("SubmitEntry(bad signature)=_,nil; want error")
+	}
+	l.badSig = false
+	// The test log only issues x509 SCTs.
+	if _, err := client.SubmitEntry(ctx, ct.PrecertSubmissionType, chain[0].Data, chain[1:]); err == nil {
+		t.Error("SubmitEntry(precert)=_,nil; want error for wrong SCT type")
+	}
+	if _, err := client.SubmitEntry(ctx, ct.SubmissionType(3), chain[0].Data, chain[1:]); err == nil {
+		t.Error("SubmitEntry(type 3)=_,nil; want error")
+	}
+}
+
+func TestGetSTHV2(t *testing.T) {
+	l := newTestLogV2(t)
+	l.add(testChainV2(t))
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	sth, err := client.GetSTHV2(ctx)
+	if err != nil {
+		t.Fatalf("GetSTHV2()=nil,%v; want sth,nil", err)
+	}
+	if got, want := sth.TreeHead.TreeSize, uint64(1); got != want {
+		t.Errorf("GetSTHV2().TreeSize=%d; want %d", got, want)
+	}
+	if got, want := sth.LogID.String(), "1.3.6.1.4.1.11129"; got != want {
+		t.Errorf("GetSTHV2().LogID=%s; want %s", got, want)
+	}
+
+	l.badSig = true
+	_, err = client.GetSTHV2(ctx)
+	if _, ok := err.(STHV2SignatureError); !ok {
+		t.Errorf("GetSTHV2(bad signature)=_,%v; want STHV2SignatureError", err)
+	}
+	// Without a verifier, the signature isn't checked.
+	noVerify, err := New(hs.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatalf("Failed to create client: %v", err)
+	}
+	if _, err := noVerify.GetSTHV2(ctx); err != nil {
+		t.Errorf("GetSTHV2(bad signature, no verifier)=_,%v; want _,nil", err)
+	}
+}
+
+func TestGetSTHConsistencyV2(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	chain := testChainV2(t)
+	l.add(chain)
+	l.add(chain)
+	l.add(chain)
+	sth3, err := client.GetSTHV2(ctx)
+	if err != nil {
+		t.Fatalf("GetSTHV2()=nil,%v", err)
+	}
+	l.add(chain)
+	l.add(chain)
+	proof, sth5, err := client.GetSTHConsistencyV2(ctx, 3, 5)
+	if err != nil {
+		t.Fatalf("GetSTHConsistencyV2(3, 5)=nil,nil,%v; want proof,sth,nil", err)
+	}
+	if err := VerifyConsistencyProofV2(proof, sth3, sth5); err != nil {
+		t.Errorf("VerifyConsistencyProofV2()=%v; want nil", err)
+	}
+	if err := VerifyConsistencyProofV2(proof, sth5, sth3); err == nil {
+		t.Error("VerifyConsistencyProofV2(swapped)=nil; want error")
+	}
+	proof.ConsistencyPath[0].Value[0] ^= 0xff
+	if err := VerifyConsistencyProofV2(proof, sth3, sth5); err == nil {
+		t.Error("VerifyConsistencyProofV2(corrupt)=nil; want error")
+	}
+
+	if _, _, err := client.GetSTHConsistencyV2(ctx, 3, 6); err == nil {
+		t.Error("GetSTHConsistencyV2(3, 6)=_,_,nil; want error")
+	}
+}
+
+func TestGetProofByHashV2(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	chain := testChainV2(t)
+	for i := 0; i < 5; i++ {
+		l.add(chain)
+	}
+	for i, entry := range l.entries {
+		hash, err := LeafHashV2(entry.LogEntry)
+		if err != nil {
+			t.Fatalf("LeafHashV2(%d)=nil,%v", i, err)
+		}
+		proof, sth, err := client.GetProofByHashV2(ctx, hash, 5)
+		if err != nil {
+			t.Errorf("GetProofByHashV2(%d)=nil,nil,%v; want proof,sth,nil", i, err)
+			continue
+		}
+		if got, want := proof.LeafIndex, uint64(i); got != want {
+			t.Errorf("GetProofByHashV2(%d).LeafIndex=%d; want %d", i, got, want)
+		}
+		if err := VerifyInclusionProofV2(proof, sth, entry.LogEntry); err != nil {
+			t.Errorf("VerifyInclusionProofV2(%d)=%v; want nil", i, err)
+		}
+		if err := VerifyInclusionProofV2(proof, sth, l.entries[(i+1)%5].LogEntry); err == nil {
+			t.Errorf("VerifyInclusionProofV2(%d, wrong entry)=nil; want error", i)
+		}
+	}
+	if _, _, err := client.GetProofByHashV2(ctx, make([]byte, sha256.Size), 5); err == nil {
+		t.Error("GetProofByHashV2(unknown hash)=_,_,nil; want error")
+	}
+	if _, err := LeafHashV2(l.entries[0].SCT); err == nil {
+		t.Error("LeafHashV2(SCT)=_,nil; want error")
+	}
+}
+
+func TestGetAllByHash(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	chain := testChainV2(t)
+	l.add(chain)
+	l.add(chain)
+	sth2, err := client.GetSTHV2(ctx)
+	if err != nil {
+		t.Fatalf("GetSTHV2()=nil,%v", err)
+	}
+	l.add(chain)
+	l.add(chain)
+	l.add(chain)
+
+	hash, err := LeafHashV2(l.entries[3].LogEntry)
+	if err != nil {
+		t.Fatalf("LeafHashV2()=nil,%v", err)
+	}
+	inclusion, consistency, sth, err := client.GetAllByHash(ctx, hash, 2)
+	if err != nil {
+		t.Fatalf("GetAllByHash()=nil,nil,nil,%v; want proofs,sth,nil", err)
+	}
+	if err := VerifyInclusionProofV2(inclusion, sth, l.entries[3].LogEntry); err != nil {
+		t.Errorf("VerifyInclusionProofV2()=%v; want nil", err)
+	}
+	if err := VerifyConsistencyProofV2(consistency, sth2, sth); err != nil {
+		t.Errorf("VerifyConsistencyProofV2()=%v; want nil", err)
+	}
+}
+
+func TestGetEntriesV2(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+	ctx := context.Background()
+
+	chain := testChainV2(t)
+	for i := 0; i < 4; i++ {
+		l.add(chain)
+	}
+	entries, sth, err := client.GetEntriesV2(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetEntriesV2(1, 10)=nil,nil,%v; want entries,sth,nil", err)
+	}
+	if !reflect.DeepEqual(entries, l.entries[1:]) {
+		t.Errorf("GetEntriesV2(1, 10)=%+v; want %+v", entries, l.entries[1:])
+	}
+	if got, want := sth.TreeHead.TreeSize, uint64(4); got != want {
+		t.Errorf("GetEntriesV2().STH.TreeSize=%d; want %d", got, want)
+	}
+	if _, _, err := client.GetEntriesV2(ctx, 2, 1); err == nil {
+		t.Error("GetEntriesV2(2, 1)=_,_,nil; want error")
+	}
+
+	// An SCT that doesn't match its entry is rejected.
+	l.entries[2].LogEntry.X509EntryV2.Timestamp++
+	_, _, err = client.GetEntriesV2(ctx, 0, 3)
+	if err == nil || !strings.Contains(err.Error(), "entry 2") {
+		t.Errorf("GetEntriesV2(bad SCT)=_,_,%v; want error for entry 2", err)
+	}
+}
+
+func TestGetAnchors(t *testing.T) {
+	l := newTestLogV2(t)
+	hs := httptest.NewServer(l)
+	defer hs.Close()
+	client := newTestClientV2(t, hs)
+
+	anchors, maxLen, err := client.GetAnchors(context.Background())
+	if err != nil {
+		t.Fatalf("GetAnchors()=nil,0,%v; want anchors,3,nil", err)
+	}
+	if want := pemChain(t, testdata.CACertPEM); !reflect.DeepEqual(anchors, want) || maxLen != 3 {
+		t.Errorf("GetAnchors()=%v,%d; want %v,3", anchors, maxLen, want)
+	}
+}
@@ -0,0 +1,273 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ctpolicy checks whether a certificate and its SCTs satisfy a
+// Certificate Transparency policy, such as requiring SCTs from a minimum
+// number of distinct known logs and log operators.
+package ctpolicy
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// LogInfo describes a known log.
+type LogInfo struct {
+	Description string
+	// Operator names the organization running the log.  Logs with an empty
+	// Operator are each treated as having a distinct operator.
+	Operator string
+	Verifier ct.SignatureVerifier
+}
+
+// LogMap holds the known logs, keyed by LogID.
+type LogMap map[ct.SHA256Hash]LogInfo
+
+// LifetimeRequirement gives the number of distinct logs which must have
+// issued SCTs for certificates whose lifetime is below a limit.
+type LifetimeRequirement struct {
+	// MaxLifetimeMonths is the (exclusive) upper limit on certificate
+	// lifetime that this requirement covers, or zero for no limit.
+	MaxLifetimeMonths int
+	MinLogs           int
+}
+
+// Policy describes the CT requirements that a certificate must meet.
+type Policy struct {
+	// Requirements are checked in order, and the first whose lifetime limit
+	// covers the certificate applies.  A certificate covered by none of them
+	// does not comply.
+	Requirements []LifetimeRequirement
+	// MinOperators is the minimum number of distinct log operators that
+	// must have issued SCTs for the certificate.
+	MinOperators int
+	// MaxClockSkew is how far in the future an SCT timestamp may be before
+	// the SCT is rejected.
+	MaxClockSkew time.Duration
+}
+
+// DefaultPolicy returns a Policy requiring SCTs from between 2 and 5 logs,
+// depending on certificate lifetime, run by at least 2 distinct operators.
+func DefaultPolicy() Policy {
+	return Policy{
+		Requirements: []LifetimeRequirement{
+			{MaxLifetimeMonths: 15, MinLogs: 2},
+			{MaxLifetimeMonths: 27, MinLogs: 3},
+			{MaxLifetimeMonths: 39, MinLogs: 4},
+			{MaxLifetimeMonths: 0, MinLogs: 5},
+		},
+		MinOperators: 2,
+		MaxClockSkew: 5 * time.Minute,
+	}
+}
+
+// Source indicates how an SCT was delivered.
+type Source int
+
+// Ways of delivering SCTs; see RFC 6962 s3.3.
+const (
+	Embedded Source = iota
+	TLSExtension
+	OCSPResponse
+)
+
+func (s Source) String() string {
+	switch s {
+	case Embedded:
+		return "embedded"
+	case TLSExtension:
+		return "TLS extension"
+	case OCSPResponse:
+		return "OCSP response"
+	default:
+		return fmt.Sprintf("Source(%d)", int(s))
+	}
+}
+
+// ValidSCT is an SCT which verified against a known log.
+type ValidSCT struct {
+	SCT    ct.SignedCertificateTimestamp
+	Source Source
+	Log    LogInfo
+}
+
+// RejectedSCT is an SCT which was not counted towards the policy.
+type RejectedSCT struct {
+	SCT    ct.SignedCertificateTimestamp
+	Source Source
+	Err    error
+}
+
+// Result describes the outcome of checking a certificate against a Policy.
+type Result struct {
+	ValidSCTs    []ValidSCT
+	RejectedSCTs []RejectedSCT
+	// Logs and Operators count the distinct logs and log operators which
+	// issued ValidSCTs, and RequiredLogs and RequiredOperators give the
+	// numbers needed by the Policy.
+	Logs, RequiredLogs           int
+	Operators, RequiredOperators int
+	// Failures lists the ways in which the certificate does not comply with
+	// the Policy.
+	Failures []string
+}
+
+// Compliant reports whether the certificate complies with the Policy.
+func (r *Result) Compliant() bool {
+	return len(r.Failures) == 0
+}
+
+type clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+// Evaluator checks certificates against a Policy, using a set of known logs.
+type Evaluator struct {
+	policy Policy
+	logs   LogMap
+	clock  clock
+}
+
+// NewEvaluator creates an Evaluator for the given Policy and known logs.
+func NewEvaluator(policy Policy, logs LogMap) *Evaluator {
+	return newEvaluatorWithClock(policy, logs, realClock{})
+}
+
+func newEvaluatorWithClock(policy Policy, logs LogMap, c clock) *Evaluator {
+	return &Evaluator{
+		policy: policy,
+		logs:   logs,
+		clock:  c,
+	}
+}
+
+// Evaluate checks a certificate against the Policy.  The chain holds the
+// certificate followed by its issuer; the issuer is needed to verify SCTs
+// embedded in the certificate.  SCTs delivered in the TLS extension or an
+// OCSP response are passed in separately, e.g. as parsed by
+// ct.DeserializeSCTList.  An error is returned only if the certificate could
+// not be evaluated at all.
+func (e *Evaluator) Evaluate(chain []*x509.Certificate, tlsSCTs, ocspSCTs []ct.SignedCertificateTimestamp) (*Result, error) {
+	if len(chain) == 0 {
+		return nil, errors.New("no certificate to evaluate")
+	}
+	cert := chain[0]
+	embedded, err := ct.SCTsFromX509List(&cert.SCTList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded SCTs: %v", err)
+	}
+
+	var result Result
+	for _, scts := range []struct {
+		source Source
+		scts   []ct.SignedCertificateTimestamp
+	}{
+		{Embedded, embedded},
+		{TLSExtension, tlsSCTs},
+		{OCSPResponse, ocspSCTs},
+	} {
+		for _, sct := range scts.scts {
+			log, err := e.checkSCT(chain, sct, scts.source)
+			if err != nil {
+				result.RejectedSCTs = append(result.RejectedSCTs, RejectedSCT{SCT: sct, Source: scts.source, Err: err})
+				continue
+			}
+			result.ValidSCTs = append(result.ValidSCTs, ValidSCT{SCT: sct, Source: scts.source, Log: log})
+		}
+	}
+
+	logs := make(map[ct.SHA256Hash]bool)
+	operators := make(map[string]bool)
+	for _, valid := range result.ValidSCTs {
+		id := ct.SHA256Hash(valid.SCT.LogID.KeyID)
+		logs[id] = true
+		operator := valid.Log.Operator
+		if operator == "" {
+			operator = "log:" + id.Base64String()
+		}
+		operators[operator] = true
+	}
+	result.Logs = len(logs)
+	result.Operators = len(operators)
+	result.RequiredOperators = e.policy.MinOperators
+
+	if !cert.NotBefore.Before(cert.NotAfter) {
+		result.Failures = append(result.Failures, fmt.Sprintf("certificate NotAfter %v is not after NotBefore %v", cert.NotAfter, cert.NotBefore))
+	} else if req := e.requirement(cert); req == nil {
+		result.Failures = append(result.Failures, "no requirement covers the certificate lifetime")
+	} else {
+		result.RequiredLogs = req.MinLogs
+	}
+	if result.RequiredLogs > 0 && result.Logs < result.RequiredLogs {
+		result.Failures = append(result.Failures, fmt.Sprintf("SCTs from %d distinct logs; need %d", result.Logs, result.RequiredLogs))
+	}
+	if result.Operators < result.RequiredOperators {
+		result.Failures = append(result.Failures, fmt.Sprintf("SCTs from %d distinct log operators; need %d", result.Operators, result.RequiredOperators))
+	}
+	return &result, nil
+}
+
+// requirement returns the first of the Policy's requirements which covers
+// the lifetime of cert, or nil if there is none.
+func (e *Evaluator) requirement(cert *x509.Certificate) *LifetimeRequirement {
+	for i, req := range e.policy.Requirements {
+		if req.MaxLifetimeMonths == 0 || cert.NotAfter.Before(cert.NotBefore.AddDate(0, req.MaxLifetimeMonths, 0)) {
+			return &e.policy.Requirements[i]
+		}
+	}
+	return nil
+}
+
+// checkSCT verifies an SCT for the certificate in chain, returning the log
+// which issued it.
+func (e *Evaluator) checkSCT(chain []*x509.Certificate, sct ct.SignedCertificateTimestamp, source Source) (LogInfo, error) {
+	log, ok := e.logs[ct.SHA256Hash(sct.LogID.KeyID)]
+	if !ok {
+		return LogInfo{}, fmt.Errorf("unknown log %s", ct.SHA256Hash(sct.LogID.KeyID).Base64String())
+	}
+
+	cert := chain[0]
+	timestamp := time.Unix(0, int64(sct.Timestamp)*int64(time.Millisecond))
+	if limit := e.clock.Now().Add(e.policy.MaxClockSkew); timestamp.After(limit) {
+		return log, fmt.Errorf("SCT timestamp %v is in the future", timestamp)
+	}
+	if timestamp.After(cert.NotAfter) {
+		return log, fmt.Errorf("SCT timestamp %v is after certificate expiry %v", timestamp, cert.NotAfter)
+	}
+
+	var leaf *ct.MerkleTreeLeaf
+	if source == Embedded {
+		var err error
+		if leaf, err = ct.MerkleTreeLeafForEmbeddedSCT(chain, sct.Timestamp); err != nil {
+			return log, err
+		}
+	} else {
+		leaf = ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert.Raw}, sct.Timestamp)
+	}
+	leaf.TimestampedEntry.Extensions = sct.Extensions
+	if err := log.Verifier.VerifySCTSignature(sct, ct.LogEntry{Leaf: *leaf}); err != nil {
+		return log, fmt.Errorf("failed to verify SCT signature: %v", err)
+	}
+	return log, nil
+}
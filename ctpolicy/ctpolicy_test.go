@@ -0,0 +1,321 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctpolicy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+type fixedClock struct {
+	t time.Time
+}
+
+func (f fixedClock) Now() time.Time {
+	return f.t
+}
+
+var (
+	testNow       = time.Date(2017, 6, 1, 0, 0, 0, 0, time.UTC)
+	testNotBefore = testNow.AddDate(0, -1, 0)
+)
+
+type testLog struct {
+	key *ecdsa.PrivateKey
+	id  ct.SHA256Hash
+}
+
+func newTestLog(t *testing.T) testLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to marshal public key: %v", err)
+	}
+	return testLog{key: key, id: sha256.Sum256(der)}
+}
+
+func (l testLog) info(t *testing.T, operator string) LogInfo {
+	v, err := ct.NewSignatureVerifier(&l.key.PublicKey)
+	if err != nil {
+		t.Fatalf("Failed to create SignatureVerifier: %v", err)
+	}
+	return LogInfo{Operator: operator, Verifier: *v}
+}
+
+// sct returns an SCT from the log for leaf, at the given time.
+func (l testLog) sct(t *testing.T, leaf *ct.MerkleTreeLeaf, when time.Time) ct.SignedCertificateTimestamp {
+	sct := ct.SignedCertificateTimestamp{
+		SCTVersion: ct.V1,
+		LogID:      ct.LogID{KeyID: l.id},
+		Timestamp:  uint64(when.UnixNano() / int64(time.Millisecond)),
+		Extensions: ct.CTExtensions{},
+	}
+	data, err := ct.SerializeSCTSignatureInput(sct, ct.LogEntry{Leaf: *leaf})
+	if err != nil {
+		t.Fatalf("SerializeSCTSignatureInput() failed: %v", err)
+	}
+	sig, err := tls.CreateSignature(*l.key, tls.SHA256, data)
+	if err != nil {
+		t.Fatalf("CreateSignature() failed: %v", err)
+	}
+	sct.Signature = ct.DigitallySigned(sig)
+	return sct
+}
+
+func parseCert(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if _, ok := err.(x509.NonFatalErrors); err != nil && !ok {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+// testIssuer issues certificates with SCTs embedded from a set of logs.
+type testIssuer struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) testIssuer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             testNotBefore.AddDate(-1, 0, 0),
+		NotAfter:              testNow.AddDate(10, 0, 0),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	return testIssuer{cert: parseCert(t, der), key: key}
+}
+
+// issue creates a certificate valid for the given number of months, with
+// embedded SCTs from logs.
+func (i testIssuer) issue(t *testing.T, months int, logs ...testLog) *x509.Certificate {
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		NotBefore:    testNotBefore,
+		NotAfter:     testNotBefore.AddDate(0, months, 0).Add(-time.Second),
+	}
+	precertTemplate := template
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: []byte{0x05, 0x00}}}
+	der, err := x509.CreateCertificate(rand.Reader, &precertTemplate, i.cert, &i.key.PublicKey, i.key)
+	if err != nil {
+		t.Fatalf("Failed to create precertificate: %v", err)
+	}
+	precert := parseCert(t, der)
+
+	var scts []ct.SignedCertificateTimestamp
+	for _, l := range logs {
+		leaf, err := ct.MerkleTreeLeafFromChain([]*x509.Certificate{precert, i.cert}, ct.PrecertLogEntryType, 0)
+		if err != nil {
+			t.Fatalf("MerkleTreeLeafFromChain() failed: %v", err)
+		}
+		leaf.TimestampedEntry.Timestamp = uint64(testNotBefore.UnixNano() / int64(time.Millisecond))
+		scts = append(scts, l.sct(t, leaf, testNotBefore))
+	}
+	if len(scts) > 0 {
+		list, err := ct.X509SCTList(scts)
+		if err != nil {
+			t.Fatalf("X509SCTList() failed: %v", err)
+		}
+		template.SCTList = *list
+	}
+	der, err = x509.CreateCertificate(rand.Reader, &template, i.cert, &i.key.PublicKey, i.key)
+	if err != nil {
+		t.Fatalf("Failed to create certificate: %v", err)
+	}
+	return parseCert(t, der)
+}
+
+func TestEvaluate(t *testing.T) {
+	issuer := newTestIssuer(t)
+	logA1, logA2, logB, unknown := newTestLog(t), newTestLog(t), newTestLog(t), newTestLog(t)
+	logs := LogMap{
+		logA1.id: logA1.info(t, "A"),
+		logA2.id: logA2.info(t, "A"),
+		logB.id:  logB.info(t, "B"),
+	}
+	e := newEvaluatorWithClock(DefaultPolicy(), logs, fixedClock{testNow})
+
+	tlsSCT := func(l testLog, cert *x509.Certificate, when time.Time) ct.SignedCertificateTimestamp {
+		leaf := ct.CreateX509MerkleTreeLeaf(ct.ASN1Cert{Data: cert.Raw}, uint64(when.UnixNano()/int64(time.Millisecond)))
+		return l.sct(t, leaf, when)
+	}
+	twoA := issuer.issue(t, 12, logA1, logA2)
+	aAndB := issuer.issue(t, 12, logA1, logB)
+	long := issuer.issue(t, 20, logA1, logA2, logB)
+	plain := issuer.issue(t, 12)
+
+	tests := []struct {
+		desc             string
+		cert             *x509.Certificate
+		tlsSCTs          []ct.SignedCertificateTimestamp
+		ocspSCTs         []ct.SignedCertificateTimestamp
+		wantCompliant    bool
+		wantLogs, wantOp int
+		wantRejected     int
+	}{
+		{
+			desc:          "embedded-two-operators",
+			cert:          aAndB,
+			wantCompliant: true,
+			wantLogs:      2,
+			wantOp:        2,
+		},
+		{
+			desc:     "embedded-one-operator",
+			cert:     twoA,
+			wantLogs: 2,
+			wantOp:   1,
+		},
+		{
+			desc:          "embedded-plus-tls",
+			cert:          twoA,
+			tlsSCTs:       []ct.SignedCertificateTimestamp{tlsSCT(logB, twoA, testNow)},
+			wantCompliant: true,
+			wantLogs:      3,
+			wantOp:        2,
+		},
+		{
+			desc:          "ocsp-only",
+			cert:          plain,
+			ocspSCTs:      []ct.SignedCertificateTimestamp{tlsSCT(logA1, plain, testNow), tlsSCT(logB, plain, testNow)},
+			wantCompliant: true,
+			wantLogs:      2,
+			wantOp:        2,
+		},
+		{
+			desc:         "wrong-certificate",
+			cert:         plain,
+			ocspSCTs:     []ct.SignedCertificateTimestamp{tlsSCT(logA1, twoA, testNow), tlsSCT(logB, twoA, testNow)},
+			wantRejected: 2,
+		},
+		{
+			desc:          "duplicate-log",
+			cert:          twoA,
+			tlsSCTs:       []ct.SignedCertificateTimestamp{tlsSCT(logA1, twoA, testNow)},
+			wantLogs:      2,
+			wantOp:        1,
+			wantCompliant: false,
+		},
+		{
+			desc:         "unknown-log",
+			cert:         twoA,
+			tlsSCTs:      []ct.SignedCertificateTimestamp{tlsSCT(unknown, twoA, testNow)},
+			wantLogs:     2,
+			wantOp:       1,
+			wantRejected: 1,
+		},
+		{
+			desc:         "future-sct",
+			cert:         twoA,
+			tlsSCTs:      []ct.SignedCertificateTimestamp{tlsSCT(logB, twoA, testNow.Add(time.Hour))},
+			wantLogs:     2,
+			wantOp:       1,
+			wantRejected: 1,
+		},
+		{
+			desc:          "long-lifetime",
+			cert:          long,
+			wantLogs:      3,
+			wantOp:        2,
+			wantCompliant: true,
+		},
+		{
+			desc:          "long-lifetime-too-few",
+			cert:          issuer.issue(t, 20, logA1, logB),
+			wantLogs:      2,
+			wantOp:        2,
+			wantCompliant: false,
+		},
+	}
+	for _, test := range tests {
+		result, err := e.Evaluate([]*x509.Certificate{test.cert, issuer.cert}, test.tlsSCTs, test.ocspSCTs)
+		if err != nil {
+			t.Errorf("%s: Evaluate()=nil,%v; want result,nil", test.desc, err)
+			continue
+		}
+		if got := result.Compliant(); got != test.wantCompliant {
+			t.Errorf("%s: Compliant()=%v; want %v (failures: %v)", test.desc, got, test.wantCompliant, result.Failures)
+		}
+		if result.Logs != test.wantLogs || result.Operators != test.wantOp {
+			t.Errorf("%s: Evaluate() found %d logs, %d operators; want %d, %d", test.desc, result.Logs, result.Operators, test.wantLogs, test.wantOp)
+		}
+		if got := len(result.RejectedSCTs); got != test.wantRejected {
+			t.Errorf("%s: Evaluate() rejected %d SCTs (%+v); want %d", test.desc, got, result.RejectedSCTs, test.wantRejected)
+		}
+	}
+
+	// Embedded SCTs can't be checked without the issuer.
+	result, err := e.Evaluate([]*x509.Certificate{aAndB}, nil, nil)
+	if err != nil {
+		t.Fatalf("Evaluate(no issuer)=nil,%v; want result,nil", err)
+	}
+	if result.Compliant() || len(result.RejectedSCTs) != 2 {
+		t.Errorf("Evaluate(no issuer): Compliant()=%v with %d rejected SCTs; want false with 2", result.Compliant(), len(result.RejectedSCTs))
+	}
+	if _, err := e.Evaluate(nil, nil, nil); err == nil {
+		t.Error("Evaluate(no chain)=_,nil; want error")
+	}
+}
+
+func TestRequirementByLifetime(t *testing.T) {
+	e := NewEvaluator(DefaultPolicy(), nil)
+	for _, test := range []struct {
+		months int
+		want   int
+	}{
+		{1, 2}, {15, 2}, {16, 3}, {27, 3}, {28, 4}, {39, 4}, {40, 5}, {120, 5},
+	} {
+		// Certificates expire a second before the end of their lifetime.
+		cert := &x509.Certificate{NotBefore: testNotBefore, NotAfter: testNotBefore.AddDate(0, test.months, 0).Add(-time.Second)}
+		req := e.requirement(cert)
+		if req == nil {
+			t.Errorf("requirement(%d months)=nil; want MinLogs %d", test.months, test.want)
+		} else if req.MinLogs != test.want {
+			t.Errorf("requirement(%d months).MinLogs=%d; want %d", test.months, req.MinLogs, test.want)
+		}
+	}
+
+	e = NewEvaluator(Policy{Requirements: []LifetimeRequirement{{MaxLifetimeMonths: 12, MinLogs: 1}}}, nil)
+	cert := &x509.Certificate{NotBefore: testNotBefore, NotAfter: testNotBefore.AddDate(0, 13, 0)}
+	if req := e.requirement(cert); req != nil {
+		t.Errorf("requirement(13 months)=%+v; want nil", req)
+	}
+}
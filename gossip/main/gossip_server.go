@@ -25,16 +25,36 @@ import (
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/gossip"
+	"github.com/google/certificate-transparency-go/loglist"
+	"golang.org/x/net/context"
 )
 
 var dbPath = flag.String("database", "/tmp/gossip.sq3", "Path to database.")
 var listenAddress = flag.String("listen", ":8080", "Listen address:port for HTTP server.")
 var logKeys = flag.String("log_public_keys", "", "Comma separated list of files containing trusted Logs' public keys in PEM format")
+var logList = flag.String("log_list", "", "Location (URL or file) of a JSON list of trusted logs, used in addition to --log_public_keys")
 
 func createVerifiers() (*gossip.SignatureVerifierMap, error) {
 	m := make(gossip.SignatureVerifierMap)
+	if len(*logKeys) == 0 && len(*logList) == 0 {
+		return nil, errors.New("--log_public_keys and --log_list are both empty")
+	}
+	if len(*logList) > 0 {
+		ll, err := loglist.Read(context.Background(), nil, *logList)
+		if err != nil {
+			return nil, err
+		}
+		verifiers, err := ll.SignatureVerifiers()
+		if err != nil {
+			return nil, err
+		}
+		for id, sv := range verifiers {
+			m[id] = sv
+			log.Printf("Loaded key for LogID %v from log list", id)
+		}
+	}
 	if len(*logKeys) == 0 {
-		return nil, errors.New("--log_public_keys is empty")
+		return &m, nil
 	}
 	keys := strings.Split(*logKeys, ",")
 	for _, k := range keys {
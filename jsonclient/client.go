@@ -57,6 +57,20 @@ type Options struct {
 	PublicKey string
 }
 
+// RspError is returned when a request to the server gets a response with a
+// status other than 200 OK, so that callers can tell which requests might
+// succeed if retried.
+type RspError struct {
+	Err        error
+	StatusCode int
+	Body       []byte
+}
+
+// Error formats the RspError.
+func (e RspError) Error() string {
+	return e.Err.Error()
+}
+
 type basicLogger struct{}
 
 func (bl *basicLogger) Printf(msg string, args ...interface{}) {
@@ -122,7 +136,12 @@ func (c *JSONClient) GetAndParse(ctx context.Context, path string, params map[st
 	defer ioutil.ReadAll(httpRsp.Body)
 
 	if httpRsp.StatusCode != http.StatusOK {
-		return httpRsp, fmt.Errorf("got HTTP Status %q", httpRsp.Status)
+		body, _ := ioutil.ReadAll(httpRsp.Body)
+		return httpRsp, RspError{
+			Err:        fmt.Errorf("got HTTP Status %q", httpRsp.Status),
+			StatusCode: httpRsp.StatusCode,
+			Body:       body,
+		}
 	}
 
 	if err := json.NewDecoder(httpRsp.Body).Decode(rsp); err != nil {
@@ -179,6 +179,11 @@ func TestGetAndParse(t *testing.T) {
 		if httpRsp.StatusCode != test.status {
 			t.Errorf("GetAndParse('%s') got status %d; want %d", test.uri, httpRsp.StatusCode, test.status)
 		}
+		if test.status != http.StatusOK {
+			if rspErr, ok := err.(RspError); !ok || rspErr.StatusCode != test.status {
+				t.Errorf("GetAndParse(%q)=_,%v; want RspError with status %d", test.uri, err, test.status)
+			}
+		}
 		if test.status == http.StatusOK {
 			if err != nil {
 				t.Errorf("GetAndParse(%q)=nil,%q; want %+v", test.uri, err.Error(), result)
@@ -0,0 +1,332 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loglist parses the JSON lists of known CT logs published at
+// https://www.certificate-transparency.org/known-logs, and builds the objects
+// needed to talk to the logs in them.
+package loglist
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/net/context"
+	"golang.org/x/net/context/ctxhttp"
+)
+
+// AllLogListURL is the location of the list of all known logs.
+const AllLogListURL = "https://www.gstatic.com/ct/log_list/all_logs_list.json"
+
+// LogListURL is the location of the list of logs trusted by Chrome.
+const LogListURL = "https://www.gstatic.com/ct/log_list/log_list.json"
+
+// LogList holds a collection of logs and their operators.
+type LogList struct {
+	Operators []Operator `json:"operators"`
+	Logs      []Log      `json:"logs"`
+}
+
+// Operator describes an organization running one or more logs.
+type Operator struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+// Log describes a single log.
+type Log struct {
+	Description string `json:"description"`
+	// Key holds the log's DER-encoded SubjectPublicKeyInfo.
+	Key []byte `json:"key"`
+	// URL gives the log's base URL, without a scheme.
+	URL               string `json:"url"`
+	MaximumMergeDelay int    `json:"maximum_merge_delay"` // seconds
+	OperatedBy        []int  `json:"operated_by"`         // Operator IDs
+	// FinalSTH is set for a log which has been frozen.
+	FinalSTH *STH `json:"final_sth,omitempty"`
+	// DisqualifiedAt is the time (in seconds since the epoch) at which the
+	// log was disqualified, or zero.
+	DisqualifiedAt int    `json:"disqualified_at,omitempty"`
+	DNSAPIEndpoint string `json:"dns_api_endpoint,omitempty"`
+}
+
+// STH describes the final tree head of a frozen log.
+type STH struct {
+	TreeSize          int    `json:"tree_size"`
+	Timestamp         int    `json:"timestamp"`
+	SHA256RootHash    []byte `json:"sha256_root_hash"`
+	TreeHeadSignature []byte `json:"tree_head_signature"`
+}
+
+// LogState describes whether a log is still accepting submissions.
+type LogState int
+
+// States that a log can be in.
+const (
+	// Active logs are accepting submissions.
+	Active LogState = iota
+	// Frozen logs have a final STH and accept no further submissions, but
+	// SCTs they issued before freezing remain valid.
+	Frozen
+	// Disqualified logs are no longer trusted.
+	Disqualified
+)
+
+func (s LogState) String() string {
+	switch s {
+	case Active:
+		return "Active"
+	case Frozen:
+		return "Frozen"
+	case Disqualified:
+		return "Disqualified"
+	default:
+		return fmt.Sprintf("LogState(%d)", int(s))
+	}
+}
+
+// NewFromJSON creates a LogList from JSON encoded data.
+func NewFromJSON(data []byte) (*LogList, error) {
+	var ll LogList
+	if err := json.Unmarshal(data, &ll); err != nil {
+		return nil, fmt.Errorf("failed to parse log list: %v", err)
+	}
+	return &ll, nil
+}
+
+// Read fetches and parses the log list from location, which may be either
+// an http(s) URL or the name of a local file.
+func Read(ctx context.Context, hc *http.Client, location string) (*LogList, error) {
+	var data []byte
+	var err error
+	if strings.HasPrefix(location, "http://") || strings.HasPrefix(location, "https://") {
+		data, err = fetch(ctx, hc, location)
+	} else {
+		data, err = ioutil.ReadFile(location)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log list from %s: %v", location, err)
+	}
+	return NewFromJSON(data)
+}
+
+func fetch(ctx context.Context, hc *http.Client, uri string) ([]byte, error) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	rsp, err := ctxhttp.Get(ctx, hc, uri)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("got HTTP status %q", rsp.Status)
+	}
+	return ioutil.ReadAll(rsp.Body)
+}
+
+// FindLogByName returns the logs whose description contains name, ignoring
+// case.  If a log's description matches name exactly, only that log is
+// returned.
+func (ll *LogList) FindLogByName(name string) []*Log {
+	name = strings.ToLower(name)
+	var results []*Log
+	for i := range ll.Logs {
+		desc := strings.ToLower(ll.Logs[i].Description)
+		if desc == name {
+			return []*Log{&ll.Logs[i]}
+		}
+		if strings.Contains(desc, name) {
+			results = append(results, &ll.Logs[i])
+		}
+	}
+	return results
+}
+
+// FindLogByURL returns the log with the given URL, or nil.  The URL may
+// include a scheme and trailing slash.
+func (ll *LogList) FindLogByURL(url string) *Log {
+	url = trimURL(url)
+	for i := range ll.Logs {
+		if trimURL(ll.Logs[i].URL) == url {
+			return &ll.Logs[i]
+		}
+	}
+	return nil
+}
+
+// FindLogByKeyHash returns the log with the given LogID, or nil.
+func (ll *LogList) FindLogByKeyHash(keyHash ct.SHA256Hash) *Log {
+	for i := range ll.Logs {
+		if ll.Logs[i].LogID() == keyHash {
+			return &ll.Logs[i]
+		}
+	}
+	return nil
+}
+
+// LogByName returns the single log selected by name, as for FindLogByName.
+// It is an error if no log, or more than one log, matches.
+func (ll *LogList) LogByName(name string) (*Log, error) {
+	logs := ll.FindLogByName(name)
+	switch len(logs) {
+	case 0:
+		return nil, fmt.Errorf("no log matches name %q", name)
+	case 1:
+		return logs[0], nil
+	default:
+		var descs []string
+		for _, l := range logs {
+			descs = append(descs, fmt.Sprintf("%q", l.Description))
+		}
+		return nil, fmt.Errorf("log name %q is ambiguous; matches %s", name, strings.Join(descs, ", "))
+	}
+}
+
+// LogClientFor returns a LogClient for the log selected by name, as for
+// LogByName, or, if name is empty, for the log at uri.  This suits commands
+// that take the log as either a name from the list or a URI; in the latter
+// case the list is not needed, and ll may be nil.  A LogClient for a URI
+// doesn't check STH signatures, as the log's public key is unknown.
+func (ll *LogList) LogClientFor(name, uri string, hc *http.Client) (*client.LogClient, error) {
+	if name == "" {
+		return client.New(uri, hc, jsonclient.Options{})
+	}
+	if ll == nil {
+		return nil, fmt.Errorf("no log list to find log %q in", name)
+	}
+	l, err := ll.LogByName(name)
+	if err != nil {
+		return nil, err
+	}
+	return l.LogClient(hc)
+}
+
+// OperatorNames returns the names of the operators of log l.
+func (ll *LogList) OperatorNames(l *Log) []string {
+	var names []string
+	for _, id := range l.OperatedBy {
+		for _, op := range ll.Operators {
+			if op.ID == id {
+				names = append(names, op.Name)
+				break
+			}
+		}
+	}
+	return names
+}
+
+// SignatureVerifiers returns SignatureVerifiers for all of the logs, keyed
+// by LogID.
+func (ll *LogList) SignatureVerifiers() (map[ct.SHA256Hash]ct.SignatureVerifier, error) {
+	verifiers := make(map[ct.SHA256Hash]ct.SignatureVerifier)
+	for i := range ll.Logs {
+		sv, err := ll.Logs[i].SignatureVerifier()
+		if err != nil {
+			return nil, err
+		}
+		verifiers[ll.Logs[i].LogID()] = *sv
+	}
+	return verifiers, nil
+}
+
+// LogClients returns LogClients for all of the logs, keyed by LogID.
+func (ll *LogList) LogClients(hc *http.Client) (map[ct.SHA256Hash]*client.LogClient, error) {
+	clients := make(map[ct.SHA256Hash]*client.LogClient)
+	for i := range ll.Logs {
+		lc, err := ll.Logs[i].LogClient(hc)
+		if err != nil {
+			return nil, err
+		}
+		clients[ll.Logs[i].LogID()] = lc
+	}
+	return clients, nil
+}
+
+// LogID returns the log's ID, which is the SHA-256 hash of its public key.
+func (l *Log) LogID() ct.SHA256Hash {
+	return sha256.Sum256(l.Key)
+}
+
+// State returns the log's current state.
+func (l *Log) State() LogState {
+	if l.DisqualifiedAt > 0 {
+		return Disqualified
+	}
+	if l.FinalSTH != nil {
+		return Frozen
+	}
+	return Active
+}
+
+// URI returns the log's base URI, including an https scheme.
+func (l *Log) URI() string {
+	if strings.Contains(l.URL, "://") {
+		return l.URL
+	}
+	return "https://" + l.URL
+}
+
+// PublicKey returns the log's parsed public key.
+func (l *Log) PublicKey() (crypto.PublicKey, error) {
+	key, err := x509.ParsePKIXPublicKey(l.Key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key for log %q: %v", l.Description, err)
+	}
+	return key, nil
+}
+
+// PublicKeyPEM returns the log's public key in PEM format.
+func (l *Log) PublicKeyPEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: l.Key}))
+}
+
+// SignatureVerifier returns a SignatureVerifier for the log's signatures.
+func (l *Log) SignatureVerifier() (*ct.SignatureVerifier, error) {
+	key, err := l.PublicKey()
+	if err != nil {
+		return nil, err
+	}
+	sv, err := ct.NewSignatureVerifier(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SignatureVerifier for log %q: %v", l.Description, err)
+	}
+	return sv, nil
+}
+
+// LogClient returns a LogClient for the log, which checks STH signatures
+// using the log's public key.
+func (l *Log) LogClient(hc *http.Client) (*client.LogClient, error) {
+	lc, err := client.New(l.URI(), hc, jsonclient.Options{PublicKey: l.PublicKeyPEM()})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create LogClient for log %q: %v", l.Description, err)
+	}
+	return lc, nil
+}
+
+func trimURL(url string) string {
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	return strings.TrimSuffix(url, "/")
+}
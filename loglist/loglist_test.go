@@ -0,0 +1,285 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loglist
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/testdata"
+	"golang.org/x/net/context"
+)
+
+func keyDER(t *testing.T, keyPEM string) []byte {
+	block, _ := pem.Decode([]byte(keyPEM))
+	if block == nil {
+		t.Fatalf("Failed to decode PEM key")
+	}
+	return block.Bytes
+}
+
+func sampleJSON(t *testing.T) string {
+	return fmt.Sprintf(`{
+  "operators": [
+    {"name": "Google", "id": 0},
+    {"name": "Example Org", "id": 1}
+  ],
+  "logs": [
+    {
+      "description": "Google 'Aviator' log",
+      "key": %q,
+      "url": "ct.googleapis.com/aviator/",
+      "maximum_merge_delay": 86400,
+      "operated_by": [0],
+      "final_sth": {
+        "tree_size": 46466472,
+        "timestamp": 1480512258330,
+        "sha256_root_hash": "LcGcZRsm+LGYmrlyC5LXhV1T6OD8iH5dNlb0sEJl9bA=",
+        "tree_head_signature": "BAMARzBFAiEA/M0Nvt77aNe+9eYbKsv6rRpTzFTKa5CGqb56ea4hnt8CIGJ2PP8bIZu9HjVyoZ5BfiAWkyAXVx+qXH0QoHgv0jDV"
+      },
+      "dns_api_endpoint": "aviator.ct.googleapis.com"
+    },
+    {
+      "description": "Google 'Pilot' log",
+      "key": %q,
+      "url": "ct.googleapis.com/pilot/",
+      "maximum_merge_delay": 86400,
+      "operated_by": [0, 1]
+    },
+    {
+      "description": "Example Pilot log",
+      "key": %q,
+      "url": "https://log.example.com/ct",
+      "maximum_merge_delay": 3600,
+      "operated_by": [1],
+      "disqualified_at": 1475637842
+    }
+  ]
+}`,
+		base64.StdEncoding.EncodeToString(keyDER(t, testdata.LogPublicKeyPEM)),
+		base64.StdEncoding.EncodeToString(keyDER(t, testdata.EcdsaPublicKeyPEM)),
+		base64.StdEncoding.EncodeToString(keyDER(t, testdata.RsaPublicKeyPEM)))
+}
+
+func sampleList(t *testing.T) *LogList {
+	ll, err := NewFromJSON([]byte(sampleJSON(t)))
+	if err != nil {
+		t.Fatalf("NewFromJSON()=nil,%v; want list,nil", err)
+	}
+	return ll
+}
+
+func TestNewFromJSON(t *testing.T) {
+	ll := sampleList(t)
+	if got, want := len(ll.Logs), 3; got != want {
+		t.Fatalf("len(Logs)=%d; want %d", got, want)
+	}
+	aviator := ll.Logs[0]
+	if got, want := aviator.MaximumMergeDelay, 86400; got != want {
+		t.Errorf("MaximumMergeDelay=%d; want %d", got, want)
+	}
+	if aviator.FinalSTH == nil || aviator.FinalSTH.TreeSize != 46466472 || len(aviator.FinalSTH.SHA256RootHash) != sha256.Size {
+		t.Errorf("FinalSTH=%+v; want tree size 46466472 with %d byte root", aviator.FinalSTH, sha256.Size)
+	}
+	if got, want := ll.OperatorNames(&ll.Logs[1]), []string{"Google", "Example Org"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("OperatorNames()=%v; want %v", got, want)
+	}
+	for i, want := range []LogState{Frozen, Active, Disqualified} {
+		if got := ll.Logs[i].State(); got != want {
+			t.Errorf("Logs[%d].State()=%v; want %v", i, got, want)
+		}
+	}
+
+	if _, err := NewFromJSON([]byte(`{"logs": [{"key": "not base64!"}]}`)); err == nil {
+		t.Error("NewFromJSON(bad key)=_,nil; want error")
+	}
+}
+
+func TestFindLog(t *testing.T) {
+	ll := sampleList(t)
+	for _, test := range []struct {
+		name string
+		want []string
+	}{
+		{"aviator", []string{"Google 'Aviator' log"}},
+		{"PILOT", []string{"Google 'Pilot' log", "Example Pilot log"}},
+		{"example pilot log", []string{"Example Pilot log"}},
+		{"rocketeer", nil},
+	} {
+		var got []string
+		for _, l := range ll.FindLogByName(test.name) {
+			got = append(got, l.Description)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("FindLogByName(%q)=%v; want %v", test.name, got, test.want)
+		}
+		l, err := ll.LogByName(test.name)
+		if len(test.want) == 1 {
+			if err != nil || l.Description != test.want[0] {
+				t.Errorf("LogByName(%q)=%v,%v; want %q,nil", test.name, l, err, test.want[0])
+			}
+		} else if err == nil {
+			t.Errorf("LogByName(%q)=%v,nil; want error", test.name, l)
+		}
+	}
+
+	for _, test := range []struct {
+		url  string
+		want int
+	}{
+		{"ct.googleapis.com/pilot/", 1},
+		{"https://ct.googleapis.com/pilot", 1},
+		{"log.example.com/ct/", 2},
+		{"ct.googleapis.com/rocketeer", -1},
+	} {
+		got := ll.FindLogByURL(test.url)
+		if test.want < 0 {
+			if got != nil {
+				t.Errorf("FindLogByURL(%q)=%q; want nil", test.url, got.Description)
+			}
+		} else if got != &ll.Logs[test.want] {
+			t.Errorf("FindLogByURL(%q)=%v; want %q", test.url, got, ll.Logs[test.want].Description)
+		}
+	}
+
+	id := ct.SHA256Hash(sha256.Sum256(keyDER(t, testdata.EcdsaPublicKeyPEM)))
+	if got := ll.FindLogByKeyHash(id); got != &ll.Logs[1] {
+		t.Errorf("FindLogByKeyHash(%v)=%v; want Pilot", id, got)
+	}
+	if got := ll.FindLogByKeyHash(ct.SHA256Hash{}); got != nil {
+		t.Errorf("FindLogByKeyHash(zero)=%v; want nil", got)
+	}
+}
+
+func TestVerifiersAndClients(t *testing.T) {
+	ll := sampleList(t)
+	verifiers, err := ll.SignatureVerifiers()
+	if err != nil {
+		t.Fatalf("SignatureVerifiers()=nil,%v; want map,nil", err)
+	}
+	clients, err := ll.LogClients(http.DefaultClient)
+	if err != nil {
+		t.Fatalf("LogClients()=nil,%v; want map,nil", err)
+	}
+	for _, l := range ll.Logs {
+		if _, id, _, err := ct.PublicKeyFromPEM([]byte(l.PublicKeyPEM())); err != nil {
+			t.Errorf("PublicKeyFromPEM(%q)=%v", l.Description, err)
+		} else if id != l.LogID() {
+			t.Errorf("PublicKeyFromPEM(%q) gave LogID %v; want %v", l.Description, id, l.LogID())
+		}
+		if _, ok := verifiers[l.LogID()]; !ok {
+			t.Errorf("SignatureVerifiers() missing log %q", l.Description)
+		}
+		if _, ok := clients[l.LogID()]; !ok {
+			t.Errorf("LogClients() missing log %q", l.Description)
+		}
+	}
+
+	ll.Logs[0].Key = []byte("not a key")
+	if _, err := ll.SignatureVerifiers(); err == nil {
+		t.Error("SignatureVerifiers(bad key)=_,nil; want error")
+	}
+	if _, err := ll.LogClients(http.DefaultClient); err == nil {
+		t.Error("LogClients(bad key)=_,nil; want error")
+	}
+}
+
+func TestLogClientFor(t *testing.T) {
+	ll := sampleList(t)
+	for _, test := range []struct {
+		desc         string
+		ll           *LogList
+		name         string
+		wantVerifier bool
+		wantErr      bool
+	}{
+		{desc: "by-name", ll: ll, name: "aviator", wantVerifier: true},
+		{desc: "by-uri", ll: ll},
+		{desc: "by-uri-without-list"},
+		{desc: "unknown-name", ll: ll, name: "rocketeer", wantErr: true},
+		{desc: "ambiguous-name", ll: ll, name: "pilot", wantErr: true},
+		{desc: "name-without-list", name: "aviator", wantErr: true},
+	} {
+		lc, err := test.ll.LogClientFor(test.name, "https://log.example.com/ct", http.DefaultClient)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("LogClientFor(%s)=_,%v; want err? %v", test.desc, err, test.wantErr)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if gotVerifier := lc.Verifier != nil; gotVerifier != test.wantVerifier {
+			t.Errorf("LogClientFor(%s) has Verifier? %v; want %v", test.desc, gotVerifier, test.wantVerifier)
+		}
+	}
+}
+
+func TestRead(t *testing.T) {
+	data := sampleJSON(t)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/log_list.json" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, data)
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "loglist")
+	if err != nil {
+		t.Fatalf("TempDir()=%v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "log_list.json")
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatalf("WriteFile()=%v", err)
+	}
+
+	ctx := context.Background()
+	for _, test := range []struct {
+		location string
+		wantErr  bool
+	}{
+		{ts.URL + "/log_list.json", false},
+		{ts.URL + "/missing.json", true},
+		{path, false},
+		{filepath.Join(dir, "missing.json"), true},
+	} {
+		ll, err := Read(ctx, nil, test.location)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Read(%q)=_,nil; want error", test.location)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Read(%q)=nil,%v; want list,nil", test.location, err)
+			continue
+		}
+		if len(ll.Logs) != 3 {
+			t.Errorf("Read(%q) got %d logs; want 3", test.location, len(ll.Logs))
+		}
+	}
+}
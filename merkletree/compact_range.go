@@ -0,0 +1,154 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+)
+
+// CompactRange is a compact representation of the contiguous range of leaves
+// [Begin, End) of an RFC 6962 Merkle tree.  It holds only the hashes of the
+// O(log n) largest complete subtrees that the range decomposes into, so a log
+// of any size can be tracked in constant memory.
+//
+// A range which starts at zero covers a whole tree, and its root hash is
+// available via Root.  Ranges covering adjacent sections of a tree may be
+// merged together with AppendRange, which allows leaves to be hashed out of
+// order (e.g. by parallel fetchers) and combined afterwards.
+type CompactRange struct {
+	treeHasher *TreeHasher
+	begin, end uint64
+	// hashes holds the roots of the complete subtrees covering [begin, end),
+	// ordered from left to right.
+	hashes [][]byte
+}
+
+// NewCompactRange returns an empty CompactRange starting at leaf index begin.
+func NewCompactRange(h HasherFunc, begin uint64) *CompactRange {
+	return &CompactRange{
+		treeHasher: NewTreeHasher(h),
+		begin:      begin,
+		end:        begin,
+	}
+}
+
+// NewCompactRangeWithHashes returns a CompactRange covering [begin, end) with
+// the given subtree hashes, as previously returned by Hashes.
+func NewCompactRangeWithHashes(h HasherFunc, begin, end uint64, hashes [][]byte) (*CompactRange, error) {
+	if begin > end {
+		return nil, fmt.Errorf("begin (%d) > end (%d)", begin, end)
+	}
+	if got, want := len(hashes), len(rangeNodes(begin, end)); got != want {
+		return nil, fmt.Errorf("range [%d, %d) needs %d hashes, got %d", begin, end, want, got)
+	}
+	r := NewCompactRange(h, begin)
+	r.end = end
+	r.hashes = append(r.hashes, hashes...)
+	return r, nil
+}
+
+// Begin returns the index of the first leaf in the range.
+func (r *CompactRange) Begin() uint64 {
+	return r.begin
+}
+
+// End returns the index one past the last leaf in the range.
+func (r *CompactRange) End() uint64 {
+	return r.end
+}
+
+// Hashes returns the hashes of the complete subtrees making up the range,
+// ordered from left to right.
+func (r *CompactRange) Hashes() [][]byte {
+	return r.hashes
+}
+
+// AppendLeaf hashes the passed in leaf data and appends it to the range.
+func (r *CompactRange) AppendLeaf(leaf []byte) {
+	r.AppendLeafHash(r.treeHasher.HashLeaf(leaf))
+}
+
+// AppendLeafHash appends an already-hashed leaf to the range.
+func (r *CompactRange) AppendLeafHash(leafHash []byte) {
+	r.appendNode(0, r.end, leafHash)
+	r.end++
+}
+
+// AppendRange extends the range with the contents of other, which must begin
+// where this range ends.
+func (r *CompactRange) AppendRange(other *CompactRange) error {
+	if other.begin != r.end {
+		return fmt.Errorf("ranges are not adjacent: [%d, %d) followed by [%d, %d)", r.begin, r.end, other.begin, other.end)
+	}
+	for i, node := range rangeNodes(other.begin, other.end) {
+		r.appendNode(node.level, node.index, other.hashes[i])
+	}
+	r.end = other.end
+	return nil
+}
+
+// Root returns the root hash of the tree of size End covered by this range.
+// Only ranges which begin at the start of the tree have a root.
+func (r *CompactRange) Root() ([]byte, error) {
+	if r.begin != 0 {
+		return nil, fmt.Errorf("range [%d, %d) does not start at 0", r.begin, r.end)
+	}
+	if len(r.hashes) == 0 {
+		return r.treeHasher.HashEmpty(), nil
+	}
+	root := r.hashes[len(r.hashes)-1]
+	for i := len(r.hashes) - 2; i >= 0; i-- {
+		root = r.treeHasher.HashChildren(r.hashes[i], root)
+	}
+	return root, nil
+}
+
+// appendNode adds the hash of the complete subtree at the given level and
+// index to the right of the range, merging it with its left siblings for as
+// long as they are also inside the range.
+func (r *CompactRange) appendNode(level uint, index uint64, hash []byte) {
+	for index&1 == 1 && (index-1)<<level >= r.begin {
+		left := r.hashes[len(r.hashes)-1]
+		r.hashes = r.hashes[:len(r.hashes)-1]
+		hash = r.treeHasher.HashChildren(left, hash)
+		level++
+		index >>= 1
+	}
+	r.hashes = append(r.hashes, hash)
+}
+
+type nodeID struct {
+	level uint
+	index uint64
+}
+
+// rangeNodes returns the complete subtrees that the range [begin, end)
+// decomposes into, ordered from left to right.
+func rangeNodes(begin, end uint64) []nodeID {
+	var nodes []nodeID
+	for begin < end {
+		level := uint(0)
+		for {
+			size := uint64(2) << level
+			if begin&(size-1) != 0 || begin+size > end {
+				break
+			}
+			level++
+		}
+		nodes = append(nodes, nodeID{level: level, index: begin >> level})
+		begin += uint64(1) << level
+	}
+	return nodes
+}
@@ -0,0 +1,158 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+func sha256Hasher(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+func TestCompactRangeRoots(t *testing.T) {
+	r := NewCompactRange(sha256Hasher, 0)
+	root, err := r.Root()
+	if err != nil {
+		t.Fatalf("Root() on empty range failed: %v", err)
+	}
+	if want := dh(sha256EmptyTreeHash); !bytes.Equal(root, want) {
+		t.Errorf("Root() on empty range=%x; want %x", root, want)
+	}
+
+	for i, input := range getInputs() {
+		r.AppendLeaf(input.h)
+		if got, want := r.End(), uint64(i+1); got != want {
+			t.Errorf("End()=%d; want %d", got, want)
+		}
+		root, err := r.Root()
+		if err != nil {
+			t.Fatalf("Root() at size %d failed: %v", i+1, err)
+		}
+		if want := getRoots()[i].h; !bytes.Equal(root, want) {
+			t.Errorf("Root() at size %d=%x; want %x", i+1, root, want)
+		}
+	}
+}
+
+func TestCompactRangeHashCount(t *testing.T) {
+	for _, test := range []struct {
+		begin, end uint64
+		want       int
+	}{
+		{0, 0, 0},
+		{0, 1, 1},
+		{0, 7, 3},
+		{0, 8, 1},
+		{1, 8, 3},
+		{3, 13, 4},
+		{5, 6, 1},
+		{16, 32, 1},
+	} {
+		r := NewCompactRange(sha256Hasher, test.begin)
+		for i := test.begin; i < test.end; i++ {
+			r.AppendLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+		}
+		if got := len(r.Hashes()); got != test.want {
+			t.Errorf("[%d, %d): len(Hashes())=%d; want %d", test.begin, test.end, got, test.want)
+		}
+	}
+}
+
+func TestCompactRangeAppendRange(t *testing.T) {
+	const size = 41
+	tree := NewInMemoryMerkleTree(sha256Hasher)
+	for i := 0; i < size; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	// Split [0, end) at every possible pair of points, build the pieces
+	// separately and check that the merged range has the right root.
+	for end := uint64(0); end <= size; end++ {
+		want, err := tree.RootAtSnapshot(end)
+		if err != nil {
+			t.Fatalf("RootAtSnapshot(%d) failed: %v", end, err)
+		}
+		for mid1 := uint64(0); mid1 <= end; mid1++ {
+			for mid2 := mid1; mid2 <= end; mid2++ {
+				r := newTestRange(0, mid1)
+				for _, piece := range []*CompactRange{newTestRange(mid1, mid2), newTestRange(mid2, end)} {
+					if err := r.AppendRange(piece); err != nil {
+						t.Fatalf("AppendRange([%d, %d)) failed: %v", piece.Begin(), piece.End(), err)
+					}
+				}
+				got, err := r.Root()
+				if err != nil {
+					t.Fatalf("Root() failed: %v", err)
+				}
+				if !bytes.Equal(got, want) {
+					t.Errorf("Root() for [0, %d) split at %d, %d=%x; want %x", end, mid1, mid2, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestCompactRangeErrors(t *testing.T) {
+	r := newTestRange(3, 10)
+	if _, err := r.Root(); err == nil {
+		t.Error("Root() on range not starting at 0 succeeded; want error")
+	}
+	if err := r.AppendRange(newTestRange(11, 12)); err == nil {
+		t.Error("AppendRange() of non-adjacent range succeeded; want error")
+	}
+	if _, err := NewCompactRangeWithHashes(sha256Hasher, 3, 10, r.Hashes()[1:]); err == nil {
+		t.Error("NewCompactRangeWithHashes() with too few hashes succeeded; want error")
+	}
+	if _, err := NewCompactRangeWithHashes(sha256Hasher, 10, 3, nil); err == nil {
+		t.Error("NewCompactRangeWithHashes() with begin > end succeeded; want error")
+	}
+}
+
+func TestCompactRangeWithHashes(t *testing.T) {
+	orig := newTestRange(0, 21)
+	r, err := NewCompactRangeWithHashes(sha256Hasher, orig.Begin(), orig.End(), orig.Hashes())
+	if err != nil {
+		t.Fatalf("NewCompactRangeWithHashes() failed: %v", err)
+	}
+	for i := uint64(21); i < 30; i++ {
+		leaf := []byte(fmt.Sprintf("leaf %d", i))
+		orig.AppendLeaf(leaf)
+		r.AppendLeaf(leaf)
+	}
+	want, err := orig.Root()
+	if err != nil {
+		t.Fatalf("Root() failed: %v", err)
+	}
+	got, err := r.Root()
+	if err != nil {
+		t.Fatalf("Root() failed: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("Root() of restored range=%x; want %x", got, want)
+	}
+}
+
+func newTestRange(begin, end uint64) *CompactRange {
+	r := NewCompactRange(sha256Hasher, begin)
+	for i := begin; i < end; i++ {
+		r.AppendLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+	return r
+}
@@ -0,0 +1,208 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"fmt"
+)
+
+// InMemoryMerkleTree is an RFC 6962 Merkle tree which holds all of its leaf
+// hashes (and the hashes of its complete subtrees) in memory, and so can
+// produce roots, inclusion proofs and consistency proofs for any tree size up
+// to the current one.
+//
+// Leaf indices are zero-based, matching MerkleVerifier.
+type InMemoryMerkleTree struct {
+	treeHasher *TreeHasher
+	// levels[0] holds the leaf hashes, levels[i][j] holds the hash of the
+	// complete subtree of 2^i leaves starting at leaf j*2^i.
+	levels [][][]byte
+}
+
+// NewInMemoryMerkleTree returns a new empty InMemoryMerkleTree using the passed in hasher.
+func NewInMemoryMerkleTree(h HasherFunc) *InMemoryMerkleTree {
+	return &InMemoryMerkleTree{
+		treeHasher: NewTreeHasher(h),
+	}
+}
+
+// LeafCount returns the number of leaves in the tree.
+func (t *InMemoryMerkleTree) LeafCount() uint64 {
+	if len(t.levels) == 0 {
+		return 0
+	}
+	return uint64(len(t.levels[0]))
+}
+
+// LevelCount returns the number of levels in the current tree, including the
+// leaf level; an empty tree has no levels.
+func (t *InMemoryMerkleTree) LevelCount() uint64 {
+	n := t.LeafCount()
+	if n == 0 {
+		return 0
+	}
+	levels := uint64(1)
+	for size := uint64(1); size < n; size <<= 1 {
+		levels++
+	}
+	return levels
+}
+
+// AddLeaf hashes the passed in leaf data and appends it to the tree,
+// returning the (zero-based) index of the new leaf.
+func (t *InMemoryMerkleTree) AddLeaf(leaf []byte) uint64 {
+	return t.AddLeafHash(t.treeHasher.HashLeaf(leaf))
+}
+
+// AddLeafHash appends an already-hashed leaf to the tree, returning the
+// (zero-based) index of the new leaf.
+func (t *InMemoryMerkleTree) AddLeafHash(leafHash []byte) uint64 {
+	if len(t.levels) == 0 {
+		t.levels = append(t.levels, nil)
+	}
+	index := uint64(len(t.levels[0]))
+	t.levels[0] = append(t.levels[0], leafHash)
+
+	// Complete any subtrees that the new leaf finishes off.
+	for level := 0; len(t.levels[level])%2 == 0; level++ {
+		if level+1 == len(t.levels) {
+			t.levels = append(t.levels, nil)
+		}
+		n := len(t.levels[level])
+		t.levels[level+1] = append(t.levels[level+1], t.treeHasher.HashChildren(t.levels[level][n-2], t.levels[level][n-1]))
+	}
+	return index
+}
+
+// LeafHash returns the hash of the leaf at (zero-based) index leaf.
+func (t *InMemoryMerkleTree) LeafHash(leaf uint64) ([]byte, error) {
+	if leaf >= t.LeafCount() {
+		return nil, fmt.Errorf("leaf index %d out of range for tree of size %d", leaf, t.LeafCount())
+	}
+	return t.levels[0][leaf], nil
+}
+
+// CurrentRoot returns the root hash of the tree at its current size.
+func (t *InMemoryMerkleTree) CurrentRoot() ([]byte, error) {
+	return t.RootAtSnapshot(t.LeafCount())
+}
+
+// RootAtSnapshot returns the root hash of the tree as it was when it held
+// snapshot leaves.
+func (t *InMemoryMerkleTree) RootAtSnapshot(snapshot uint64) ([]byte, error) {
+	if snapshot > t.LeafCount() {
+		return nil, fmt.Errorf("snapshot %d > tree size %d", snapshot, t.LeafCount())
+	}
+	if snapshot == 0 {
+		return t.treeHasher.HashEmpty(), nil
+	}
+	return t.subtreeHash(0, snapshot), nil
+}
+
+// PathToCurrentRoot returns the audit path (inclusion proof) for the leaf at
+// (zero-based) index leaf to the current root.
+func (t *InMemoryMerkleTree) PathToCurrentRoot(leaf uint64) ([][]byte, error) {
+	return t.PathToRootAtSnapshot(leaf, t.LeafCount())
+}
+
+// PathToRootAtSnapshot returns the audit path (inclusion proof) for the leaf at
+// (zero-based) index leaf to the root of the tree at size snapshot.
+func (t *InMemoryMerkleTree) PathToRootAtSnapshot(leaf, snapshot uint64) ([][]byte, error) {
+	if snapshot > t.LeafCount() {
+		return nil, fmt.Errorf("snapshot %d > tree size %d", snapshot, t.LeafCount())
+	}
+	if leaf >= snapshot {
+		return nil, fmt.Errorf("leaf index %d out of range for snapshot %d", leaf, snapshot)
+	}
+	return t.path(leaf, 0, snapshot), nil
+}
+
+// SnapshotConsistency returns a consistency proof between the tree sizes
+// snapshot1 and snapshot2.
+func (t *InMemoryMerkleTree) SnapshotConsistency(snapshot1, snapshot2 uint64) ([][]byte, error) {
+	if snapshot1 > snapshot2 {
+		return nil, fmt.Errorf("snapshot1 (%d) > snapshot2 (%d)", snapshot1, snapshot2)
+	}
+	if snapshot2 > t.LeafCount() {
+		return nil, fmt.Errorf("snapshot2 %d > tree size %d", snapshot2, t.LeafCount())
+	}
+	if snapshot1 == 0 || snapshot1 == snapshot2 {
+		return [][]byte{}, nil
+	}
+	return t.subproof(snapshot1, 0, snapshot2, true), nil
+}
+
+// subtreeHash returns MTH(D[start:start+size]) as defined in RFC 6962 s2.1.
+// size must be > 0.
+func (t *InMemoryMerkleTree) subtreeHash(start, size uint64) []byte {
+	// A complete, aligned subtree will already have been calculated.
+	if level, ok := completeSubtreeLevel(start, size); ok {
+		return t.levels[level][start>>level]
+	}
+	k := largestPowerOfTwoLessThan(size)
+	return t.treeHasher.HashChildren(t.subtreeHash(start, k), t.subtreeHash(start+k, size-k))
+}
+
+// path returns PATH(m, D[start:start+size]) as defined in RFC 6962 s2.1.1,
+// where m is relative to the start of the whole tree.
+func (t *InMemoryMerkleTree) path(m, start, size uint64) [][]byte {
+	if size <= 1 {
+		return [][]byte{}
+	}
+	k := largestPowerOfTwoLessThan(size)
+	if m < start+k {
+		return append(t.path(m, start, k), t.subtreeHash(start+k, size-k))
+	}
+	return append(t.path(m, start+k, size-k), t.subtreeHash(start, k))
+}
+
+// subproof returns SUBPROOF(m, D[start:start+size], b) as defined in RFC 6962
+// s2.1.2, where m is relative to start.
+func (t *InMemoryMerkleTree) subproof(m, start, size uint64, b bool) [][]byte {
+	if m == size {
+		if b {
+			return [][]byte{}
+		}
+		return [][]byte{t.subtreeHash(start, size)}
+	}
+	k := largestPowerOfTwoLessThan(size)
+	if m <= k {
+		return append(t.subproof(m, start, k, b), t.subtreeHash(start+k, size-k))
+	}
+	return append(t.subproof(m-k, start+k, size-k, false), t.subtreeHash(start, k))
+}
+
+// completeSubtreeLevel returns the level of the subtree holding size leaves
+// from start, if that subtree is complete and aligned to its own size.
+func completeSubtreeLevel(start, size uint64) (uint, bool) {
+	if size == 0 || size&(size-1) != 0 {
+		return 0, false
+	}
+	level := uint(0)
+	for uint64(1)<<level < size {
+		level++
+	}
+	return level, start&(size-1) == 0
+}
+
+// largestPowerOfTwoLessThan returns the largest power of two strictly less
+// than n, which must be > 1.
+func largestPowerOfTwoLessThan(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
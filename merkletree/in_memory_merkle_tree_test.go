@@ -0,0 +1,210 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merkletree
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+)
+
+// Check that InMemoryMerkleTree satisfies the full tree interface.
+var _ FullMerkleTreeInterface = &InMemoryMerkleTree{}
+
+func getInMemoryTree(leafCount int) *InMemoryMerkleTree {
+	tree := NewInMemoryMerkleTree(func(b []byte) []byte {
+		h := sha256.Sum256(b)
+		return h[:]
+	})
+	inputs := getInputs()
+	for i := 0; i < leafCount; i++ {
+		tree.AddLeaf(inputs[i].h)
+	}
+	return tree
+}
+
+func TestInMemoryMerkleTreeEmpty(t *testing.T) {
+	tree := getInMemoryTree(0)
+	if got := tree.LeafCount(); got != 0 {
+		t.Errorf("LeafCount()=%d; want 0", got)
+	}
+	if got := tree.LevelCount(); got != 0 {
+		t.Errorf("LevelCount()=%d; want 0", got)
+	}
+	root, err := tree.CurrentRoot()
+	if err != nil {
+		t.Fatalf("CurrentRoot()=nil,%v; want root", err)
+	}
+	if want := dh(sha256EmptyTreeHash); !bytes.Equal(root, want) {
+		t.Errorf("CurrentRoot()=%x; want %x", root, want)
+	}
+	if _, err := tree.LeafHash(0); err == nil {
+		t.Error("LeafHash(0)=_,nil; want error")
+	}
+	if _, err := tree.PathToCurrentRoot(0); err == nil {
+		t.Error("PathToCurrentRoot(0)=_,nil; want error")
+	}
+}
+
+func TestInMemoryMerkleTreeAddLeaf(t *testing.T) {
+	tree := getInMemoryTree(0)
+	inputs := getInputs()
+	levels := []uint64{1, 2, 3, 3, 4, 4, 4, 4}
+	for i, input := range inputs {
+		if got, want := tree.AddLeaf(input.h), uint64(i); got != want {
+			t.Errorf("AddLeaf(%d)=%d; want %d", i, got, want)
+		}
+		if got, want := tree.LeafCount(), uint64(i+1); got != want {
+			t.Errorf("LeafCount()=%d; want %d", got, want)
+		}
+		if got, want := tree.LevelCount(), levels[i]; got != want {
+			t.Errorf("LevelCount()=%d at size %d; want %d", got, i+1, want)
+		}
+		leafHash, err := tree.LeafHash(uint64(i))
+		if err != nil {
+			t.Fatalf("LeafHash(%d)=nil,%v; want hash", i, err)
+		}
+		if want := tree.treeHasher.HashLeaf(input.h); !bytes.Equal(leafHash, want) {
+			t.Errorf("LeafHash(%d)=%x; want %x", i, leafHash, want)
+		}
+	}
+}
+
+func TestInMemoryMerkleTreeRoots(t *testing.T) {
+	roots := getRoots()
+	// Roots should match whether calculated incrementally or as a snapshot.
+	tree := getInMemoryTree(len(roots))
+	for i, want := range roots {
+		incremental, err := getInMemoryTree(i + 1).CurrentRoot()
+		if err != nil {
+			t.Fatalf("CurrentRoot() at size %d failed: %v", i+1, err)
+		}
+		if !bytes.Equal(incremental, want.h) {
+			t.Errorf("CurrentRoot() at size %d=%x; want %x", i+1, incremental, want.h)
+		}
+		snapshot, err := tree.RootAtSnapshot(uint64(i + 1))
+		if err != nil {
+			t.Fatalf("RootAtSnapshot(%d) failed: %v", i+1, err)
+		}
+		if !bytes.Equal(snapshot, want.h) {
+			t.Errorf("RootAtSnapshot(%d)=%x; want %x", i+1, snapshot, want.h)
+		}
+	}
+	if _, err := tree.RootAtSnapshot(uint64(len(roots) + 1)); err == nil {
+		t.Errorf("RootAtSnapshot(%d)=_,nil; want error", len(roots)+1)
+	}
+}
+
+func TestInMemoryMerkleTreeInclusionProofs(t *testing.T) {
+	tree := getInMemoryTree(len(getInputs()))
+	// i = 0 is an invalid path.
+	for i, test := range getInclusionTestVector()[1:] {
+		want := [][]byte{}
+		for j := int64(0); j < test.proofLength; j++ {
+			want = append(want, test.proof[j].h)
+		}
+		got, err := tree.PathToRootAtSnapshot(uint64(test.leaf-1), uint64(test.snapshot))
+		if err != nil {
+			t.Fatalf("%d: PathToRootAtSnapshot(%d, %d) failed: %v", i, test.leaf-1, test.snapshot, err)
+		}
+		if err := proofsEqual(got, want); err != nil {
+			t.Errorf("%d: PathToRootAtSnapshot(%d, %d): %v", i, test.leaf-1, test.snapshot, err)
+		}
+	}
+
+	if _, err := tree.PathToRootAtSnapshot(3, 3); err == nil {
+		t.Error("PathToRootAtSnapshot(3, 3)=_,nil; want error")
+	}
+	if _, err := tree.PathToRootAtSnapshot(0, 9); err == nil {
+		t.Error("PathToRootAtSnapshot(0, 9)=_,nil; want error")
+	}
+}
+
+func TestInMemoryMerkleTreeConsistencyProofs(t *testing.T) {
+	tree := getInMemoryTree(len(getInputs()))
+	for i, test := range getConsistencyProofs() {
+		want := [][]byte{}
+		for j := int64(0); j < test.proofLen; j++ {
+			want = append(want, test.proof[j].h)
+		}
+		got, err := tree.SnapshotConsistency(uint64(test.snapshot1), uint64(test.snapshot2))
+		if err != nil {
+			t.Fatalf("%d: SnapshotConsistency(%d, %d) failed: %v", i, test.snapshot1, test.snapshot2, err)
+		}
+		if err := proofsEqual(got, want); err != nil {
+			t.Errorf("%d: SnapshotConsistency(%d, %d): %v", i, test.snapshot1, test.snapshot2, err)
+		}
+	}
+
+	if _, err := tree.SnapshotConsistency(2, 1); err == nil {
+		t.Error("SnapshotConsistency(2, 1)=_,nil; want error")
+	}
+	if _, err := tree.SnapshotConsistency(1, 9); err == nil {
+		t.Error("SnapshotConsistency(1, 9)=_,nil; want error")
+	}
+}
+
+// TestInMemoryMerkleTreeVerifiable checks that every proof generated by the
+// tree is accepted by MerkleVerifier.
+func TestInMemoryMerkleTreeVerifiable(t *testing.T) {
+	v := getVerifier()
+	const size = 37
+	tree := getInMemoryTree(0)
+	for i := 0; i < size; i++ {
+		tree.AddLeaf([]byte(fmt.Sprintf("leaf %d", i)))
+	}
+
+	for snapshot := uint64(1); snapshot <= size; snapshot++ {
+		root, err := tree.RootAtSnapshot(snapshot)
+		if err != nil {
+			t.Fatalf("RootAtSnapshot(%d) failed: %v", snapshot, err)
+		}
+		for leaf := uint64(0); leaf < snapshot; leaf++ {
+			proof, err := tree.PathToRootAtSnapshot(leaf, snapshot)
+			if err != nil {
+				t.Fatalf("PathToRootAtSnapshot(%d, %d) failed: %v", leaf, snapshot, err)
+			}
+			if err := v.VerifyInclusionProof(int64(leaf), int64(snapshot), proof, root, []byte(fmt.Sprintf("leaf %d", leaf))); err != nil {
+				t.Errorf("VerifyInclusionProof(%d, %d) failed: %v", leaf, snapshot, err)
+			}
+		}
+		for snapshot1 := uint64(0); snapshot1 <= snapshot; snapshot1++ {
+			root1, err := tree.RootAtSnapshot(snapshot1)
+			if err != nil {
+				t.Fatalf("RootAtSnapshot(%d) failed: %v", snapshot1, err)
+			}
+			proof, err := tree.SnapshotConsistency(snapshot1, snapshot)
+			if err != nil {
+				t.Fatalf("SnapshotConsistency(%d, %d) failed: %v", snapshot1, snapshot, err)
+			}
+			if err := v.VerifyConsistencyProof(int64(snapshot1), int64(snapshot), root1, root, proof); err != nil {
+				t.Errorf("VerifyConsistencyProof(%d, %d) failed: %v", snapshot1, snapshot, err)
+			}
+		}
+	}
+}
+
+func proofsEqual(got, want [][]byte) error {
+	if len(got) != len(want) {
+		return fmt.Errorf("got proof of %d components, want %d", len(got), len(want))
+	}
+	for i := range got {
+		if !bytes.Equal(got[i], want[i]) {
+			return fmt.Errorf("proof component %d: got %x, want %x", i, got[i], want[i])
+		}
+	}
+	return nil
+}
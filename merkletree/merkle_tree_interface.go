@@ -45,9 +45,9 @@ type FullMerkleTreeInterface interface {
 
 	// PathToCurrentRoot returns the Merkle path (or inclusion proof) from the
 	// leaf hash at index |leaf| to the current root.
-	PathToCurrentRoot(leaf uint64) ([]byte, error)
+	PathToCurrentRoot(leaf uint64) ([][]byte, error)
 
 	// SnapshotConsistency returns a consistency proof between the two tree
 	// sizes specified in |snapshot1| and |snapshot2|.
-	SnapshotConsistency(snapshot1, snapshot2 uint64) ([]byte, error)
+	SnapshotConsistency(snapshot1, snapshot2 uint64) ([][]byte, error)
 }
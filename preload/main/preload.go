@@ -29,7 +29,7 @@ import (
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/client"
-	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/loglist"
 	"github.com/google/certificate-transparency-go/preload"
 	"github.com/google/certificate-transparency-go/scanner"
 	"golang.org/x/net/context"
@@ -42,6 +42,9 @@ const (
 
 var sourceLogURI = flag.String("source_log_uri", "http://ct.googleapis.com/aviator", "CT log base URI to fetch entries from")
 var targetLogURI = flag.String("target_log_uri", "http://example.com/ct", "CT log base URI to add entries to")
+var logList = flag.String("log_list", loglist.AllLogListURL, "Location (URL or file) of the JSON list of known logs")
+var sourceLogName = flag.String("source_log_name", "", "Name of the log to fetch entries from, from --log_list; overrides --source_log_uri")
+var targetLogName = flag.String("target_log_name", "", "Name of the log to add entries to, from --log_list; overrides --target_log_uri")
 var batchSize = flag.Int("batch_size", 1000, "Max number of entries to request at per call to get-entries")
 var numWorkers = flag.Int("num_workers", 2, "Number of concurrent matchers")
 var parallelFetch = flag.Int("parallel_fetch", 2, "Number of concurrent GetEntries fetches")
@@ -175,10 +178,18 @@ func main() {
 		ExpectContinueTimeout: 1 * time.Second,
 	}
 
-	fetchLogClient, err := client.New(*sourceLogURI, &http.Client{
+	var ll *loglist.LogList
+	if *sourceLogName != "" || *targetLogName != "" {
+		ll, err = loglist.Read(context.Background(), &http.Client{Transport: transport}, *logList)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	fetchLogClient, err := ll.LogClientFor(*sourceLogName, *sourceLogURI, &http.Client{
 		Timeout:   10 * time.Second,
 		Transport: transport,
-	}, jsonclient.Options{})
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -206,9 +217,9 @@ func main() {
 	sctWriterWG.Add(1)
 	go sctWriterJob(addedCerts, sctWriter, &sctWriterWG)
 
-	submitLogClient, err := client.New(*targetLogURI, &http.Client{
+	submitLogClient, err := ll.LogClientFor(*targetLogName, *targetLogURI, &http.Client{
 		Transport: transport,
-	}, jsonclient.Options{})
+	})
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -0,0 +1,249 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/merkletree"
+	"golang.org/x/net/context"
+)
+
+// ScanCounts holds counters describing the entries processed by a scan.
+type ScanCounts struct {
+	CertsProcessed            int64 `json:"certs_processed"`
+	PrecertsSeen              int64 `json:"precerts_seen"`
+	UnparsableEntries         int64 `json:"unparsable_entries"`
+	EntriesWithNonFatalErrors int64 `json:"entries_with_non_fatal_errors"`
+}
+
+// add atomically adds the counts in other to c.
+func (c *ScanCounts) add(other *ScanCounts) {
+	atomic.AddInt64(&c.CertsProcessed, atomic.LoadInt64(&other.CertsProcessed))
+	atomic.AddInt64(&c.PrecertsSeen, atomic.LoadInt64(&other.PrecertsSeen))
+	atomic.AddInt64(&c.UnparsableEntries, atomic.LoadInt64(&other.UnparsableEntries))
+	atomic.AddInt64(&c.EntriesWithNonFatalErrors, atomic.LoadInt64(&other.EntriesWithNonFatalErrors))
+}
+
+// Checkpoint records how far a scan has got, so that it can be resumed.
+type Checkpoint struct {
+	// NextIndex is the index of the first entry which has not been
+	// processed; every entry before it (from where the scan started) has
+	// been passed through the Matcher.
+	NextIndex int64 `json:"next_index"`
+	// STH is the tree head which the scan is running against.
+	STH ct.SignedTreeHead `json:"sth"`
+	// Counts covers all of the entries before NextIndex.
+	Counts ScanCounts `json:"counts"`
+	// TreeHashes holds the hashes of the complete subtrees covering the
+	// entries [0, NextIndex), when ScannerOptions.VerifyEntries is set;
+	// see merkletree.CompactRange.
+	TreeHashes [][]byte `json:"tree_hashes,omitempty"`
+}
+
+// Checkpointer persists Checkpoints for a scan.
+type Checkpointer interface {
+	// LoadCheckpoint returns the most recently saved Checkpoint, or nil if
+	// there is none.
+	LoadCheckpoint() (*Checkpoint, error)
+	// SaveCheckpoint stores cp, replacing any previous Checkpoint.
+	SaveCheckpoint(cp *Checkpoint) error
+}
+
+// FileCheckpointer is a Checkpointer which stores a Checkpoint as JSON in a
+// file.
+type FileCheckpointer struct {
+	path string
+}
+
+// NewFileCheckpointer creates a FileCheckpointer which stores its Checkpoint
+// in the file at path.
+func NewFileCheckpointer(path string) *FileCheckpointer {
+	return &FileCheckpointer{path: path}
+}
+
+// LoadCheckpoint reads the Checkpoint from the file, returning nil if the file
+// does not exist.
+func (f *FileCheckpointer) LoadCheckpoint() (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(f.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, err
+	}
+	return &cp, nil
+}
+
+// SaveCheckpoint writes cp to the file.  The previous contents are replaced
+// atomically, so a crash part way through leaves the previous Checkpoint
+// intact.
+func (f *FileCheckpointer) SaveCheckpoint(cp *Checkpoint) error {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(f.path), filepath.Base(f.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), f.path)
+}
+
+// rangeProgress tracks the processing of the entries in a single fetchRange.
+type rangeProgress struct {
+	start, end int64 // Inclusive, as for fetchRange.
+	// Number of entries still to be processed; accessed atomically.
+	remaining int64
+	// Counts for the entries processed so far; accessed atomically.
+	counts ScanCounts
+	// Hashes of the range's leaves, if entries are being verified.  Only
+	// written by the fetcher, which completes before remaining reaches 0.
+	leaves *merkletree.CompactRange
+}
+
+// progressTracker determines the contiguous run of entries which have been
+// processed, given that fetchers and matchers work on them out of order.
+type progressTracker struct {
+	mu sync.Mutex
+	// next is the index of the first entry which isn't yet known to be processed.
+	next int64
+	// counts covers all entries before next.
+	counts ScanCounts
+	// pending holds completed ranges which start after next, keyed by start.
+	pending map[int64]*rangeProgress
+	// verifier is fed the leaves of each range in order, if non-nil.
+	verifier *entryVerifier
+}
+
+func newProgressTracker(next int64, counts ScanCounts, verifier *entryVerifier) *progressTracker {
+	return &progressTracker{
+		next:     next,
+		counts:   counts,
+		pending:  make(map[int64]*rangeProgress),
+		verifier: verifier,
+	}
+}
+
+// newRange returns a rangeProgress for the entries [start, end].
+func (p *progressTracker) newRange(start, end int64) *rangeProgress {
+	r := &rangeProgress{
+		start:     start,
+		end:       end,
+		remaining: end - start + 1,
+	}
+	if p.verifier != nil {
+		r.leaves = p.verifier.newRange(start)
+	}
+	return r
+}
+
+// entryDone records that an entry in r has been processed, with counts
+// describing the outcome.  It returns an error if verification of the
+// entries processed so far fails.
+func (p *progressTracker) entryDone(ctx context.Context, r *rangeProgress, counts *ScanCounts) error {
+	r.counts.add(counts)
+	if atomic.AddInt64(&r.remaining, -1) == 0 {
+		return p.rangeDone(ctx, r)
+	}
+	return nil
+}
+
+func (p *progressTracker) rangeDone(ctx context.Context, r *rangeProgress) error {
+	checks, err := p.addPending(r)
+	// Consistency proofs are fetched without holding any locks, so that
+	// other ranges can complete meanwhile.
+	for _, c := range checks {
+		if cerr := p.verifier.checkConsistency(ctx, c); cerr != nil && err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// addPending records r as complete, and advances over any contiguous
+// completed ranges, returning the consistency checks which are then due.
+func (p *progressTracker) addPending(r *rangeProgress) ([]*consistencyCheck, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.pending[r.start] = r
+	var checks []*consistencyCheck
+	var err error
+	for {
+		next, ok := p.pending[p.next]
+		if !ok {
+			return checks, err
+		}
+		delete(p.pending, next.start)
+		p.counts.add(&next.counts)
+		if p.verifier != nil {
+			c, verr := p.verifier.addRange(next.leaves)
+			if verr != nil && err == nil {
+				err = verr
+			}
+			if c != nil {
+				checks = append(checks, c)
+			}
+		}
+		p.next = next.end + 1
+	}
+}
+
+// nextIndex returns the index of the first entry not known to be processed.
+func (p *progressTracker) nextIndex() int64 {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.next
+}
+
+// checkpoint returns a Checkpoint describing the contiguous progress made
+// in scanning against sth.
+func (p *progressTracker) checkpoint(sth ct.SignedTreeHead) *Checkpoint {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cp := &Checkpoint{
+		NextIndex: p.next,
+		STH:       sth,
+		Counts:    p.counts,
+	}
+	if p.verifier != nil {
+		cp.TreeHashes = p.verifier.treeHashes()
+	}
+	return cp
+}
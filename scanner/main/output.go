@@ -0,0 +1,196 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+)
+
+// entryRecord holds the details of a matching entry which are written out
+// by an entryWriter.
+type entryRecord struct {
+	Index     int64    `json:"index"`
+	Timestamp string   `json:"timestamp"`
+	EntryType string   `json:"entry_type"`
+	Subject   string   `json:"subject"`
+	SANs      []string `json:"sans"`
+	Issuer    string   `json:"issuer"`
+	Serial    string   `json:"serial"`
+	NotBefore string   `json:"not_before"`
+	NotAfter  string   `json:"not_after"`
+	// SHA-256 fingerprint of the (pre)certificate, in hex.
+	SHA256 string `json:"sha256"`
+	// The (pre)certificate followed by the rest of its chain.
+	ChainPEM string `json:"chain_pem"`
+}
+
+var csvHeader = []string{"index", "timestamp", "entry_type", "subject", "sans", "issuer", "serial", "not_before", "not_after", "sha256", "chain_pem"}
+
+func (r *entryRecord) csvFields() []string {
+	return []string{
+		fmt.Sprintf("%d", r.Index), r.Timestamp, r.EntryType, r.Subject, strings.Join(r.SANs, " "),
+		r.Issuer, r.Serial, r.NotBefore, r.NotAfter, r.SHA256, r.ChainPEM,
+	}
+}
+
+// newEntryRecord extracts the details of a matching entry, whose X509Cert or
+// Precert will have been filled in by the scanner.
+func newEntryRecord(entry *ct.LogEntry) *entryRecord {
+	var cert *x509.Certificate
+	var der []byte
+	chain := entry.Chain
+	switch {
+	case entry.X509Cert != nil:
+		cert = entry.X509Cert
+		der = entry.Leaf.TimestampedEntry.X509Entry.Data
+	case entry.Precert != nil:
+		cert = &entry.Precert.TBSCertificate
+		der = entry.Precert.Raw
+		// The precertificate is the first entry in the chain.
+		if len(chain) > 0 {
+			chain = chain[1:]
+		}
+	default:
+		return nil
+	}
+
+	var sans []string
+	sans = append(sans, cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	var chainPEM bytes.Buffer
+	pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: der})
+	for _, c := range chain {
+		pem.Encode(&chainPEM, &pem.Block{Type: "CERTIFICATE", Bytes: c.Data})
+	}
+	fingerprint := sha256.Sum256(der)
+	var serial string
+	if cert.SerialNumber != nil {
+		serial = cert.SerialNumber.String()
+	}
+	timestamp := entry.Leaf.TimestampedEntry.Timestamp
+	return &entryRecord{
+		Index:     entry.Index,
+		Timestamp: time.Unix(0, int64(timestamp)*int64(time.Millisecond)).UTC().Format(time.RFC3339Nano),
+		EntryType: entry.Leaf.TimestampedEntry.EntryType.String(),
+		Subject:   nameString(cert.Subject),
+		SANs:      sans,
+		Issuer:    nameString(cert.Issuer),
+		Serial:    serial,
+		NotBefore: cert.NotBefore.UTC().Format(time.RFC3339),
+		NotAfter:  cert.NotAfter.UTC().Format(time.RFC3339),
+		SHA256:    hex.EncodeToString(fingerprint[:]),
+		ChainPEM:  chainPEM.String(),
+	}
+}
+
+// nameString formats the commonly used attributes of a distinguished name.
+func nameString(name pkix.Name) string {
+	var parts []string
+	add := func(attr string, values ...string) {
+		for _, v := range values {
+			parts = append(parts, attr+"="+v)
+		}
+	}
+	add("CN", name.CommonName)
+	add("OU", name.OrganizationalUnit...)
+	add("O", name.Organization...)
+	add("L", name.Locality...)
+	add("ST", name.Province...)
+	add("C", name.Country...)
+	if name.CommonName == "" {
+		parts = parts[1:]
+	}
+	return strings.Join(parts, ", ")
+}
+
+// entryWriter writes matching entries in a structured format.  It is safe
+// for concurrent use by the scanner's matchers.
+type entryWriter struct {
+	mu sync.Mutex
+	// Exactly one of these is set, depending on the format.
+	json *json.Encoder
+	csv  *csv.Writer
+	err  error
+}
+
+// newEntryWriter returns an entryWriter for format "jsonl" (JSON Lines) or
+// "csv".  For csv, a header line is written first if header is set; it
+// should not be when appending to earlier output.
+func newEntryWriter(w io.Writer, format string, header bool) (*entryWriter, error) {
+	switch format {
+	case "jsonl":
+		return &entryWriter{json: json.NewEncoder(w)}, nil
+	case "csv":
+		cw := csv.NewWriter(w)
+		if header {
+			cw.Write(csvHeader)
+			cw.Flush()
+			if err := cw.Error(); err != nil {
+				return nil, err
+			}
+		}
+		return &entryWriter{csv: cw}, nil
+	}
+	return nil, fmt.Errorf("unknown output format %q", format)
+}
+
+// writeEntry is suitable for use as the scanner's foundCert and foundPrecert
+// callbacks.  Once a write fails, later entries are dropped and the error is
+// returned by error.
+func (w *entryWriter) writeEntry(entry *ct.LogEntry) {
+	r := newEntryRecord(entry)
+	if r == nil {
+		return
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return
+	}
+	if w.json != nil {
+		w.err = w.json.Encode(r)
+		return
+	}
+	// Flush each record, so that output appears promptly when following a
+	// log.
+	if w.err = w.csv.Write(r.csvFields()); w.err == nil {
+		w.csv.Flush()
+		w.err = w.csv.Error()
+	}
+}
+
+// error returns the first error encountered while writing.
+func (w *entryWriter) error() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.err
+}
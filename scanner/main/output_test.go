@@ -0,0 +1,257 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/testdata"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+func pemToDER(t *testing.T, data string) []byte {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		t.Fatal("failed to decode PEM certificate")
+	}
+	return block.Bytes
+}
+
+func fingerprint(der []byte) string {
+	hash := sha256.Sum256(der)
+	return hex.EncodeToString(hash[:])
+}
+
+// testEntries returns a certificate entry and a precertificate entry, as
+// they would be passed to the scanner's callbacks.
+func testEntries(t *testing.T) (certEntry, precertEntry *ct.LogEntry) {
+	caDER := pemToDER(t, testdata.CACertPEM)
+	certDER := pemToDER(t, testdata.TestCertPEM)
+	precertDER := pemToDER(t, testdata.TestPreCertPEM)
+
+	cert, err := x509.ParseCertificate(certDER)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	cert.DNSNames = []string{"www.example.com", "example.com"}
+	precert, err := x509.ParseCertificate(precertDER)
+	if err != nil {
+		t.Fatalf("failed to parse precertificate: %v", err)
+	}
+
+	certEntry = &ct.LogEntry{
+		Index: 10,
+		Leaf: ct.MerkleTreeLeaf{
+			TimestampedEntry: &ct.TimestampedEntry{
+				Timestamp: 1500000000123,
+				EntryType: ct.X509LogEntryType,
+				X509Entry: &ct.ASN1Cert{Data: certDER},
+			},
+		},
+		X509Cert: cert,
+		Chain:    []ct.ASN1Cert{{Data: caDER}},
+	}
+	precertEntry = &ct.LogEntry{
+		Index: 11,
+		Leaf: ct.MerkleTreeLeaf{
+			TimestampedEntry: &ct.TimestampedEntry{
+				Timestamp: 1500000000456,
+				EntryType: ct.PrecertLogEntryType,
+			},
+		},
+		Precert: &ct.Precertificate{Raw: precertDER, TBSCertificate: *precert},
+		Chain:   []ct.ASN1Cert{{Data: precertDER}, {Data: caDER}},
+	}
+	return certEntry, precertEntry
+}
+
+func TestWriteEntryCSV(t *testing.T) {
+	certEntry, precertEntry := testEntries(t)
+	var buf bytes.Buffer
+	w, err := newEntryWriter(&buf, "csv", true)
+	if err != nil {
+		t.Fatalf("newEntryWriter()=_,%v; want _,nil", err)
+	}
+	w.writeEntry(certEntry)
+	w.writeEntry(precertEntry)
+	w.writeEntry(&ct.LogEntry{Index: 12}) // Neither cert nor precert: skipped.
+	if err := w.error(); err != nil {
+		t.Fatalf("error()=%v; want nil", err)
+	}
+
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("failed to read CSV output: %v", err)
+	}
+	if len(rows) != 3 {
+		t.Fatalf("got %d CSV rows; want 3", len(rows))
+	}
+	if got := rows[0]; !reflect.DeepEqual(got, csvHeader) {
+		t.Errorf("header=%v; want %v", got, csvHeader)
+	}
+	subject := "O=Certificate Transparency, L=Erw Wen, ST=Wales, C=GB"
+	issuer := "O=Certificate Transparency CA, L=Erw Wen, ST=Wales, C=GB"
+	want := [][]string{
+		{"10", "2017-07-14T02:40:00.123Z", "X509LogEntryType", subject, "www.example.com example.com", issuer, "6",
+			"2012-06-01T00:00:00Z", "2022-06-01T00:00:00Z", fingerprint(pemToDER(t, testdata.TestCertPEM))},
+		{"11", "2017-07-14T02:40:00.456Z", "PrecertLogEntryType", subject, "", issuer, "7",
+			"2012-06-01T00:00:00Z", "2022-06-01T00:00:00Z", fingerprint(pemToDER(t, testdata.TestPreCertPEM))},
+	}
+	for i, row := range rows[1:] {
+		if len(row) != len(csvHeader) {
+			t.Errorf("row %d has %d fields; want %d", i, len(row), len(csvHeader))
+			continue
+		}
+		// Everything but the chain, which is checked for JSON Lines output.
+		if got := row[:len(row)-1]; !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("row %d=%q; want %q", i, got, want[i])
+		}
+	}
+}
+
+func TestNewEntryWriterNoHeader(t *testing.T) {
+	var buf bytes.Buffer
+	if _, err := newEntryWriter(&buf, "csv", false); err != nil {
+		t.Fatalf("newEntryWriter()=_,%v; want _,nil", err)
+	}
+	if buf.Len() != 0 {
+		t.Errorf("newEntryWriter(header=false) wrote %q; want nothing", buf.String())
+	}
+}
+
+func TestWriteEntryJSONL(t *testing.T) {
+	certEntry, precertEntry := testEntries(t)
+	var buf bytes.Buffer
+	w, err := newEntryWriter(&buf, "jsonl", true)
+	if err != nil {
+		t.Fatalf("newEntryWriter()=_,%v; want _,nil", err)
+	}
+	w.writeEntry(certEntry)
+	w.writeEntry(precertEntry)
+	if err := w.error(); err != nil {
+		t.Fatalf("error()=%v; want nil", err)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d JSON lines; want 2", len(lines))
+	}
+	for i, test := range []struct {
+		entryType string
+		sha256    string
+		// The PEM blocks expected in the chain, in order.
+		chain []string
+	}{
+		{
+			entryType: "X509LogEntryType",
+			sha256:    fingerprint(pemToDER(t, testdata.TestCertPEM)),
+			chain:     []string{testdata.TestCertPEM, testdata.CACertPEM},
+		},
+		{
+			// The precertificate appears once, even though the log entry's
+			// chain starts with it.
+			entryType: "PrecertLogEntryType",
+			sha256:    fingerprint(pemToDER(t, testdata.TestPreCertPEM)),
+			chain:     []string{testdata.TestPreCertPEM, testdata.CACertPEM},
+		},
+	} {
+		var r entryRecord
+		if err := json.Unmarshal([]byte(lines[i]), &r); err != nil {
+			t.Errorf("line %d: failed to parse %q: %v", i, lines[i], err)
+			continue
+		}
+		if r.EntryType != test.entryType {
+			t.Errorf("line %d: entry_type=%q; want %q", i, r.EntryType, test.entryType)
+		}
+		if r.SHA256 != test.sha256 {
+			t.Errorf("line %d: sha256=%q; want %q", i, r.SHA256, test.sha256)
+		}
+		rest := []byte(r.ChainPEM)
+		for j, want := range test.chain {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				t.Errorf("line %d: chain_pem has %d certificates; want %d", i, j, len(test.chain))
+				break
+			}
+			if !bytes.Equal(block.Bytes, pemToDER(t, want)) {
+				t.Errorf("line %d: chain_pem certificate %d differs from expected", i, j)
+			}
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			t.Errorf("line %d: chain_pem has unexpected trailing data %q", i, rest)
+		}
+	}
+}
+
+// failingWriter fails all writes after the first n bytes.
+type failingWriter struct {
+	n int
+}
+
+var errWriteFailed = errors.New("write failed")
+
+func (w *failingWriter) Write(p []byte) (int, error) {
+	if len(p) > w.n {
+		n := w.n
+		w.n = 0
+		return n, errWriteFailed
+	}
+	w.n -= len(p)
+	return len(p), nil
+}
+
+func TestWriteEntryError(t *testing.T) {
+	certEntry, precertEntry := testEntries(t)
+
+	if _, err := newEntryWriter(&failingWriter{}, "csv", true); err != errWriteFailed {
+		t.Errorf("newEntryWriter(csv, failing)=_,%v; want _,%v", err, errWriteFailed)
+	}
+	if _, err := newEntryWriter(&failingWriter{}, "xml", true); err == nil {
+		t.Error("newEntryWriter(xml)=_,nil; want _,non-nil")
+	}
+
+	for _, format := range []string{"csv", "jsonl"} {
+		// The CSV header fits, but no entries do.
+		fw := &failingWriter{n: 1000}
+		w, err := newEntryWriter(fw, format, true)
+		if err != nil {
+			t.Fatalf("newEntryWriter(%s)=_,%v; want _,nil", format, err)
+		}
+		w.writeEntry(certEntry)
+		if err := w.error(); err != errWriteFailed {
+			t.Errorf("%s: error()=%v; want %v", format, err, errWriteFailed)
+		}
+		// Later entries are dropped, and the first error is kept.
+		fw.n = 100000
+		w.writeEntry(precertEntry)
+		if fw.n != 100000 {
+			t.Errorf("%s: writeEntry() wrote after an error", format)
+		}
+		if err := w.error(); err != errWriteFailed {
+			t.Errorf("%s: error()=%v after further writes; want %v", format, err, errWriteFailed)
+		}
+	}
+}
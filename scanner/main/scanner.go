@@ -16,18 +16,23 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"log"
 	"math/big"
 	"net/http"
+	"os"
+	"path/filepath"
 	"regexp"
 	"time"
 
 	ct "github.com/google/certificate-transparency-go"
-	"github.com/google/certificate-transparency-go/client"
-	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/loglist"
 	"github.com/google/certificate-transparency-go/scanner"
+	"golang.org/x/net/context"
 )
 
 const (
@@ -36,8 +41,11 @@ const (
 )
 
 var logURI = flag.String("log_uri", "http://ct.googleapis.com/aviator", "CT log base URI")
+var logList = flag.String("log_list", loglist.AllLogListURL, "Location (URL or file) of the JSON list of known logs")
+var logName = flag.String("log_name", "", "Name of the log to scan, from --log_list; overrides --log_uri")
 var matchSubjectRegex = flag.String("match_subject_regex", ".*", "Regex to match CN/SAN")
 var matchIssuerRegex = flag.String("match_issuer_regex", "", "Regex to match in issuer CN")
+var matchQuery = flag.String("match_query", "", "Query describing the entries to match, e.g. 'dns_suffix:example.com and not key_size:>=2048'; overrides the other --match_* and --serial_number flags")
 var precertsOnly = flag.Bool("precerts_only", false, "Only match precerts")
 var serialNumber = flag.String("serial_number", "", "Serial number of certificate of interest")
 var batchSize = flag.Int("batch_size", 1000, "Max number of entries to request at per call to get-entries")
@@ -46,6 +54,16 @@ var parallelFetch = flag.Int("parallel_fetch", 2, "Number of concurrent GetEntri
 var startIndex = flag.Int64("start_index", 0, "Log index to start scanning at")
 var quiet = flag.Bool("quiet", false, "Don't print out extra logging messages, only matches.")
 var printChains = flag.Bool("print_chains", false, "If true prints the whole chain rather than a summary")
+var verifyEntries = flag.Bool("verify_entries", false, "Verify that the scanned entries match the log's STH (requires --start_index=0)")
+var verifyCheckpointInterval = flag.Int64("verify_checkpoint_interval", 0, "With --verify_entries, also check the entries against the STH every this many entries")
+var checkpointFile = flag.String("checkpoint_file", "", "File in which to record scan progress, and from which to resume an interrupted scan")
+var follow = flag.Bool("follow", false, "Rather than stopping at the end of the log, keep polling for and scanning new entries")
+var pollInterval = flag.Duration("poll_interval", time.Minute, "With --follow, how often to check the log for a new STH")
+var ordered = flag.Bool("ordered", false, "Print matches in ascending order of log index")
+var unparsableDir = flag.String("unparsable_dir", "", "If set, write a JSON file describing each entry which fails to parse to this directory")
+var output = flag.String("output", "text", "Format in which to output matches: text (log messages), jsonl (JSON Lines) or csv")
+var outputFile = flag.String("output_file", "", "File to write jsonl or csv output to, rather than stdout; appended to when resuming from --checkpoint_file, in which case matches found after the last checkpoint was saved may be repeated")
+var maxRetries = flag.Int("max_retries", 10, "Number of times to retry a failed request to the log before giving up")
 
 // Prints out a short bit of info about |cert|, found at |index| in the
 // specified log
@@ -60,6 +78,35 @@ func logPrecertInfo(entry *ct.LogEntry) {
 		entry.Precert.TBSCertificate.Subject.CommonName, entry.Precert.TBSCertificate.Issuer.CommonName)
 }
 
+// unparsableRecord is the JSON form of an entry written to --unparsable_dir.
+type unparsableRecord struct {
+	Index     int64  `json:"index"`
+	Error     string `json:"error"`
+	NonFatal  bool   `json:"non_fatal"`
+	LeafInput []byte `json:"leaf_input"`
+	ExtraData []byte `json:"extra_data"`
+}
+
+// Writes out the raw contents of an entry which failed to parse, along with
+// the parse error.
+func dumpUnparsable(u *scanner.UnparsableEntry) {
+	data, err := json.MarshalIndent(unparsableRecord{
+		Index:     u.Index,
+		Error:     u.Err.Error(),
+		NonFatal:  u.NonFatal(),
+		LeafInput: u.Leaf.LeafInput,
+		ExtraData: u.Leaf.ExtraData,
+	}, "", "  ")
+	if err != nil {
+		log.Printf("Failed to marshal unparsable entry at index %d: %v", u.Index, err)
+		return
+	}
+	path := filepath.Join(*unparsableDir, fmt.Sprintf("%d.json", u.Index))
+	if err := ioutil.WriteFile(path, data, 0644); err != nil {
+		log.Printf("Failed to write unparsable entry at index %d: %v", u.Index, err)
+	}
+}
+
 func chainToString(certs []ct.ASN1Cert) string {
 	var output []byte
 
@@ -89,6 +136,13 @@ func createRegexes(regexValue string) (*regexp.Regexp, *regexp.Regexp) {
 }
 
 func createMatcherFromFlags() (scanner.Matcher, error) {
+	if *matchQuery != "" {
+		m, err := scanner.ParseMatcher(*matchQuery)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --match_query: %v", err)
+		}
+		return m, nil
+	}
 	if *matchIssuerRegex != "" {
 		certRegex, precertRegex := createRegexes(*matchIssuerRegex)
 		return scanner.MatchIssuerRegex{
@@ -112,7 +166,7 @@ func createMatcherFromFlags() (scanner.Matcher, error) {
 
 func main() {
 	flag.Parse()
-	logClient, err := client.New(*logURI, &http.Client{
+	httpClient := &http.Client{
 		Timeout: 10 * time.Second,
 		Transport: &http.Transport{
 			TLSHandshakeTimeout:   30 * time.Second,
@@ -123,7 +177,16 @@ func main() {
 			IdleConnTimeout:       90 * time.Second,
 			ExpectContinueTimeout: 1 * time.Second,
 		},
-	}, jsonclient.Options{})
+	}
+	var ll *loglist.LogList
+	var err error
+	if *logName != "" {
+		ll, err = loglist.Read(context.Background(), httpClient, *logList)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	logClient, err := ll.LogClientFor(*logName, *logURI, httpClient)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -134,17 +197,91 @@ func main() {
 
 	opts := scanner.ScannerOptions{
 		Matcher:       matcher,
+		PrecertOnly:   *precertsOnly,
 		BatchSize:     *batchSize,
 		NumWorkers:    *numWorkers,
 		ParallelFetch: *parallelFetch,
 		StartIndex:    *startIndex,
 		Quiet:         *quiet,
+
+		VerifyEntries:            *verifyEntries,
+		VerifyCheckpointInterval: *verifyCheckpointInterval,
+
+		CheckpointPeriod: 10 * time.Second,
+		PollInterval:     *pollInterval,
+		MaxRetries:       *maxRetries,
+		OrderedDelivery:  *ordered,
+	}
+	if *unparsableDir != "" {
+		if err := os.MkdirAll(*unparsableDir, 0755); err != nil {
+			log.Fatal(err)
+		}
+		opts.FoundUnparsable = dumpUnparsable
+	}
+	resuming := false
+	if *checkpointFile != "" {
+		opts.Checkpointer = scanner.NewFileCheckpointer(*checkpointFile)
+		cp, err := opts.Checkpointer.LoadCheckpoint()
+		if err != nil {
+			log.Fatal(err)
+		}
+		resuming = cp != nil
 	}
 	scanner := scanner.NewScanner(logClient, opts)
 
+	foundCert, foundPrecert := logCertInfo, logPrecertInfo
 	if *printChains {
-		scanner.Scan(logFullChain, logFullChain)
+		foundCert, foundPrecert = logFullChain, logFullChain
+	}
+	var writer *entryWriter
+	var outFile *os.File
+	if *output != "text" {
+		var out io.Writer = os.Stdout
+		header := true
+		if *outputFile != "" {
+			// When resuming from a checkpoint, keep the matches that were
+			// written before the scan was interrupted.  Those found after
+			// the checkpoint was saved are found, and written, again.
+			flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+			if resuming {
+				flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+			}
+			outFile, err = os.OpenFile(*outputFile, flags, 0644)
+			if err != nil {
+				log.Fatal(err)
+			}
+			info, err := outFile.Stat()
+			if err != nil {
+				outFile.Close()
+				log.Fatal(err)
+			}
+			header = info.Size() == 0
+			out = outFile
+		}
+		writer, err = newEntryWriter(out, *output, header)
+		if err != nil {
+			if outFile != nil {
+				outFile.Close()
+			}
+			log.Fatal(err)
+		}
+		foundCert, foundPrecert = writer.writeEntry, writer.writeEntry
+	}
+	if *follow {
+		err = scanner.Follow(context.Background(), foundCert, foundPrecert)
 	} else {
-		scanner.Scan(logCertInfo, logPrecertInfo)
+		err = scanner.Scan(foundCert, foundPrecert)
+	}
+	if err == nil && writer != nil {
+		err = writer.error()
+	}
+	// Close the output explicitly, as log.Fatal doesn't run deferred calls.
+	if outFile != nil {
+		if cerr := outFile.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		log.Fatal(err)
 	}
 }
@@ -0,0 +1,322 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"crypto/dsa"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"strings"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/crypto/ed25519"
+)
+
+// LogEntryMatcher is an optional interface which a Matcher may implement if
+// it needs more of a log entry than the certificate or precertificate, such
+// as the issuing certificate.  The Scanner calls LogEntryMatches in place of
+// CertificateMatches or PrecertificateMatches for such Matchers; the entry's
+// X509Cert or Precert field is already filled in.
+type LogEntryMatcher interface {
+	Matcher
+	LogEntryMatches(*ct.LogEntry) bool
+}
+
+// entryMatches applies m to the (already parsed) entry.
+func entryMatches(m Matcher, entry *ct.LogEntry) bool {
+	if em, ok := m.(LogEntryMatcher); ok {
+		return em.LogEntryMatches(entry)
+	}
+	switch {
+	case entry.X509Cert != nil:
+		return m.CertificateMatches(entry.X509Cert)
+	case entry.Precert != nil:
+		return m.PrecertificateMatches(entry.Precert)
+	}
+	return false
+}
+
+// MatchAnd is a Matcher which matches if all of its Matchers do.
+type MatchAnd []Matcher
+
+// CertificateMatches returns true if every Matcher matches c.
+func (m MatchAnd) CertificateMatches(c *x509.Certificate) bool {
+	for _, sub := range m {
+		if !sub.CertificateMatches(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// PrecertificateMatches returns true if every Matcher matches p.
+func (m MatchAnd) PrecertificateMatches(p *ct.Precertificate) bool {
+	for _, sub := range m {
+		if !sub.PrecertificateMatches(p) {
+			return false
+		}
+	}
+	return true
+}
+
+// LogEntryMatches returns true if every Matcher matches entry.
+func (m MatchAnd) LogEntryMatches(entry *ct.LogEntry) bool {
+	for _, sub := range m {
+		if !entryMatches(sub, entry) {
+			return false
+		}
+	}
+	return true
+}
+
+// MatchOr is a Matcher which matches if any of its Matchers do.
+type MatchOr []Matcher
+
+// CertificateMatches returns true if any Matcher matches c.
+func (m MatchOr) CertificateMatches(c *x509.Certificate) bool {
+	for _, sub := range m {
+		if sub.CertificateMatches(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// PrecertificateMatches returns true if any Matcher matches p.
+func (m MatchOr) PrecertificateMatches(p *ct.Precertificate) bool {
+	for _, sub := range m {
+		if sub.PrecertificateMatches(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// LogEntryMatches returns true if any Matcher matches entry.
+func (m MatchOr) LogEntryMatches(entry *ct.LogEntry) bool {
+	for _, sub := range m {
+		if entryMatches(sub, entry) {
+			return true
+		}
+	}
+	return false
+}
+
+// MatchNot is a Matcher which inverts the result of another Matcher.
+type MatchNot struct {
+	Matcher Matcher
+}
+
+// CertificateMatches returns true if m.Matcher doesn't match c.
+func (m MatchNot) CertificateMatches(c *x509.Certificate) bool {
+	return !m.Matcher.CertificateMatches(c)
+}
+
+// PrecertificateMatches returns true if m.Matcher doesn't match p.
+func (m MatchNot) PrecertificateMatches(p *ct.Precertificate) bool {
+	return !m.Matcher.PrecertificateMatches(p)
+}
+
+// LogEntryMatches returns true if m.Matcher doesn't match entry.
+func (m MatchNot) LogEntryMatches(entry *ct.LogEntry) bool {
+	return !entryMatches(m.Matcher, entry)
+}
+
+// MatchDNSSuffix matches certificates with a DNS Subject Alternative Name
+// which is equal to Suffix or is a subdomain of it, ignoring case.
+type MatchDNSSuffix struct {
+	Suffix string
+}
+
+func (m MatchDNSSuffix) matches(c *x509.Certificate) bool {
+	suffix := strings.ToLower(strings.TrimPrefix(m.Suffix, "."))
+	for _, name := range c.DNSNames {
+		name = strings.ToLower(name)
+		if name == suffix || strings.HasSuffix(name, "."+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateMatches returns true if any DNS SAN of c is within m.Suffix.
+func (m MatchDNSSuffix) CertificateMatches(c *x509.Certificate) bool {
+	return m.matches(c)
+}
+
+// PrecertificateMatches returns true if any DNS SAN of p is within m.Suffix.
+func (m MatchDNSSuffix) PrecertificateMatches(p *ct.Precertificate) bool {
+	return m.matches(&p.TBSCertificate)
+}
+
+// MatchPublicKey matches certificates by the algorithm and size (in bits) of
+// their public key.  An UnknownPublicKeyAlgorithm Algorithm matches any
+// algorithm, and a zero MinSize or MaxSize leaves the size unbounded in that
+// direction.
+type MatchPublicKey struct {
+	Algorithm        x509.PublicKeyAlgorithm
+	MinSize, MaxSize int
+}
+
+// publicKeySize returns the size in bits of a public key, or 0 if unknown.
+func publicKeySize(key interface{}) int {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		return k.N.BitLen()
+	case *dsa.PublicKey:
+		return k.P.BitLen()
+	case *ecdsa.PublicKey:
+		return k.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	}
+	return 0
+}
+
+func (m MatchPublicKey) matches(c *x509.Certificate) bool {
+	if m.Algorithm != x509.UnknownPublicKeyAlgorithm && c.PublicKeyAlgorithm != m.Algorithm {
+		return false
+	}
+	if m.MinSize == 0 && m.MaxSize == 0 {
+		return true
+	}
+	size := publicKeySize(c.PublicKey)
+	if size == 0 {
+		return false
+	}
+	return (m.MinSize == 0 || size >= m.MinSize) && (m.MaxSize == 0 || size <= m.MaxSize)
+}
+
+// CertificateMatches returns true if c's public key has the required
+// algorithm and size.
+func (m MatchPublicKey) CertificateMatches(c *x509.Certificate) bool {
+	return m.matches(c)
+}
+
+// PrecertificateMatches returns true if p's public key has the required
+// algorithm and size.
+func (m MatchPublicKey) PrecertificateMatches(p *ct.Precertificate) bool {
+	return m.matches(&p.TBSCertificate)
+}
+
+// MatchValidity matches certificates whose validity period overlaps the
+// window [Start, End].  A zero Start or End leaves the window unbounded in
+// that direction.
+type MatchValidity struct {
+	Start, End time.Time
+}
+
+func (m MatchValidity) matches(c *x509.Certificate) bool {
+	if !m.Start.IsZero() && c.NotAfter.Before(m.Start) {
+		return false
+	}
+	if !m.End.IsZero() && c.NotBefore.After(m.End) {
+		return false
+	}
+	return true
+}
+
+// CertificateMatches returns true if c is valid at some point in the window.
+func (m MatchValidity) CertificateMatches(c *x509.Certificate) bool {
+	return m.matches(c)
+}
+
+// PrecertificateMatches returns true if p is valid at some point in the
+// window.
+func (m MatchValidity) PrecertificateMatches(p *ct.Precertificate) bool {
+	return m.matches(&p.TBSCertificate)
+}
+
+// MatchExtension matches certificates which include an extension with the
+// given OID.
+type MatchExtension struct {
+	OID asn1.ObjectIdentifier
+}
+
+func (m MatchExtension) matches(c *x509.Certificate) bool {
+	for _, ext := range c.Extensions {
+		if ext.Id.Equal(m.OID) {
+			return true
+		}
+	}
+	return false
+}
+
+// CertificateMatches returns true if c has the extension.
+func (m MatchExtension) CertificateMatches(c *x509.Certificate) bool {
+	return m.matches(c)
+}
+
+// PrecertificateMatches returns true if p has the extension.
+func (m MatchExtension) PrecertificateMatches(p *ct.Precertificate) bool {
+	return m.matches(&p.TBSCertificate)
+}
+
+// MatchSPKIHash matches certificates whose DER-encoded SubjectPublicKeyInfo
+// has the given SHA-256 hash.
+type MatchSPKIHash struct {
+	Hash [sha256.Size]byte
+}
+
+// CertificateMatches returns true if c's public key has the hash.
+func (m MatchSPKIHash) CertificateMatches(c *x509.Certificate) bool {
+	return sha256.Sum256(c.RawSubjectPublicKeyInfo) == m.Hash
+}
+
+// PrecertificateMatches returns true if p's public key has the hash.
+func (m MatchSPKIHash) PrecertificateMatches(p *ct.Precertificate) bool {
+	return sha256.Sum256(p.TBSCertificate.RawSubjectPublicKeyInfo) == m.Hash
+}
+
+// MatchIssuerSPKIHash matches entries whose issuer's DER-encoded
+// SubjectPublicKeyInfo has the given SHA-256 hash.  For precertificates this
+// is the IssuerKeyHash; for certificates the issuer is taken from the log
+// entry's chain, so only LogEntryMatches can match them.
+type MatchIssuerSPKIHash struct {
+	Hash [sha256.Size]byte
+}
+
+// CertificateMatches always returns false, as the issuer's key isn't known
+// from the certificate alone.
+func (m MatchIssuerSPKIHash) CertificateMatches(c *x509.Certificate) bool {
+	return false
+}
+
+// PrecertificateMatches returns true if p's IssuerKeyHash is the hash.
+func (m MatchIssuerSPKIHash) PrecertificateMatches(p *ct.Precertificate) bool {
+	return p.IssuerKeyHash == m.Hash
+}
+
+// LogEntryMatches returns true if the issuer of the entry's (pre)certificate
+// has a public key with the hash.
+func (m MatchIssuerSPKIHash) LogEntryMatches(entry *ct.LogEntry) bool {
+	if entry.Precert != nil {
+		return m.PrecertificateMatches(entry.Precert)
+	}
+	if len(entry.Chain) == 0 {
+		return false
+	}
+	issuer, err := x509.ParseCertificate(entry.Chain[0].Data)
+	if err != nil {
+		if _, ok := err.(x509.NonFatalErrors); !ok {
+			return false
+		}
+	}
+	return sha256.Sum256(issuer.RawSubjectPublicKeyInfo) == m.Hash
+}
@@ -0,0 +1,187 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"crypto/sha256"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/testdata"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+func certFromPEM(t *testing.T, data string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		t.Fatal("failed to decode PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestMatchCombinators(t *testing.T) {
+	var cert x509.Certificate
+	var precert ct.Precertificate
+	for _, test := range []struct {
+		desc string
+		m    Matcher
+		want bool
+	}{
+		{"empty and", MatchAnd{}, true},
+		{"empty or", MatchOr{}, false},
+		{"and all", MatchAnd{MatchAll{}, MatchAll{}}, true},
+		{"and some", MatchAnd{MatchAll{}, MatchNone{}}, false},
+		{"or some", MatchOr{MatchNone{}, MatchAll{}}, true},
+		{"or none", MatchOr{MatchNone{}, MatchNone{}}, false},
+		{"not all", MatchNot{MatchAll{}}, false},
+		{"not none", MatchNot{MatchNone{}}, true},
+		{"nested", MatchOr{MatchNone{}, MatchAnd{MatchAll{}, MatchNot{MatchNone{}}}}, true},
+	} {
+		if got := test.m.CertificateMatches(&cert); got != test.want {
+			t.Errorf("%s: CertificateMatches()=%v; want %v", test.desc, got, test.want)
+		}
+		if got := test.m.PrecertificateMatches(&precert); got != test.want {
+			t.Errorf("%s: PrecertificateMatches()=%v; want %v", test.desc, got, test.want)
+		}
+		entry := ct.LogEntry{X509Cert: &cert}
+		if got := entryMatches(test.m, &entry); got != test.want {
+			t.Errorf("%s: entryMatches()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestMatchPrecertificates(t *testing.T) {
+	hash := sha256.Sum256([]byte("issuer key"))
+	precert := ct.Precertificate{
+		IssuerKeyHash: hash,
+		TBSCertificate: x509.Certificate{
+			DNSNames:  []string{"www.example.com"},
+			NotBefore: time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC),
+			NotAfter:  time.Date(2017, 4, 1, 0, 0, 0, 0, time.UTC),
+		},
+	}
+	for _, test := range []struct {
+		desc string
+		m    Matcher
+		want bool
+	}{
+		{"issuer key hash", MatchIssuerSPKIHash{Hash: hash}, true},
+		{"other issuer key hash", MatchIssuerSPKIHash{}, false},
+		{"dns suffix", MatchDNSSuffix{Suffix: "example.com"}, true},
+		{"other dns suffix", MatchDNSSuffix{Suffix: "example.org"}, false},
+		{"valid in window", MatchValidity{Start: time.Date(2017, 3, 1, 0, 0, 0, 0, time.UTC)}, true},
+		{"expired before window", MatchValidity{Start: time.Date(2017, 5, 1, 0, 0, 0, 0, time.UTC)}, false},
+		{"unknown key size", MatchPublicKey{MinSize: 1}, false},
+	} {
+		if got := test.m.PrecertificateMatches(&precert); got != test.want {
+			t.Errorf("%s: PrecertificateMatches()=%v; want %v", test.desc, got, test.want)
+		}
+		entry := ct.LogEntry{Precert: &precert}
+		if got := entryMatches(test.m, &entry); got != test.want {
+			t.Errorf("%s: entryMatches()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestMatchCertificates(t *testing.T) {
+	// The test certificate has a 1024-bit RSA key, a Basic Constraints
+	// extension and is valid from 2012-06-01 to 2022-06-01.
+	cert := certFromPEM(t, testdata.TestCertPEM)
+	// It has no DNS SANs, so give a copy of it some.
+	named := *cert
+	named.DNSNames = []string{"Www.Example.COM", "other.org"}
+
+	for _, test := range []struct {
+		desc string
+		m    Matcher
+		cert *x509.Certificate
+		want bool
+	}{
+		{"dns suffix", MatchDNSSuffix{Suffix: "example.com"}, &named, true},
+		{"dns suffix with dot", MatchDNSSuffix{Suffix: ".EXAMPLE.com"}, &named, true},
+		{"dns exact name", MatchDNSSuffix{Suffix: "other.org"}, &named, true},
+		{"dns partial label", MatchDNSSuffix{Suffix: "ample.com"}, &named, false},
+		{"dns longer name", MatchDNSSuffix{Suffix: "sub.other.org"}, &named, false},
+		{"dns no names", MatchDNSSuffix{Suffix: "example.com"}, cert, false},
+		{"key any", MatchPublicKey{}, cert, true},
+		{"key rsa", MatchPublicKey{Algorithm: x509.RSA}, cert, true},
+		{"key ecdsa", MatchPublicKey{Algorithm: x509.ECDSA}, cert, false},
+		{"key min size", MatchPublicKey{Algorithm: x509.RSA, MinSize: 1024}, cert, true},
+		{"key too small", MatchPublicKey{Algorithm: x509.RSA, MinSize: 2048}, cert, false},
+		{"key max size", MatchPublicKey{MaxSize: 1024}, cert, true},
+		{"key too big", MatchPublicKey{MaxSize: 512}, cert, false},
+		{"valid unbounded", MatchValidity{}, cert, true},
+		{"valid in window", MatchValidity{
+			Start: time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC),
+			End:   time.Date(2016, 1, 1, 0, 0, 0, 0, time.UTC),
+		}, cert, true},
+		{"valid overlapping start", MatchValidity{Start: time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)}, cert, true},
+		{"valid overlapping end", MatchValidity{End: time.Date(2012, 7, 1, 0, 0, 0, 0, time.UTC)}, cert, true},
+		{"expired before window", MatchValidity{Start: time.Date(2022, 7, 1, 0, 0, 0, 0, time.UTC)}, cert, false},
+		{"not yet valid in window", MatchValidity{End: time.Date(2012, 5, 1, 0, 0, 0, 0, time.UTC)}, cert, false},
+		{"basic constraints", MatchExtension{OID: asn1.ObjectIdentifier{2, 5, 29, 19}}, cert, true},
+		{"ct poison", MatchExtension{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}}, cert, false},
+		{"spki hash", MatchSPKIHash{Hash: sha256.Sum256(cert.RawSubjectPublicKeyInfo)}, cert, true},
+		{"other spki hash", MatchSPKIHash{Hash: sha256.Sum256(cert.Raw)}, cert, false},
+		{"issuer spki hash", MatchIssuerSPKIHash{Hash: sha256.Sum256(cert.RawSubjectPublicKeyInfo)}, cert, false},
+	} {
+		if got := test.m.CertificateMatches(test.cert); got != test.want {
+			t.Errorf("%s: CertificateMatches()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestMatchPrecertificateExtension(t *testing.T) {
+	precert := ct.Precertificate{TBSCertificate: *certFromPEM(t, testdata.TestPreCertPEM)}
+	m := MatchExtension{OID: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}}
+	if !m.PrecertificateMatches(&precert) {
+		t.Error("PrecertificateMatches()=false for CT poison extension; want true")
+	}
+}
+
+func TestMatchIssuerSPKIHashLogEntry(t *testing.T) {
+	ca := certFromPEM(t, testdata.CACertPEM)
+	cert := certFromPEM(t, testdata.TestCertPEM)
+	caHash := sha256.Sum256(ca.RawSubjectPublicKeyInfo)
+	certHash := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	for _, test := range []struct {
+		desc  string
+		hash  [sha256.Size]byte
+		chain []ct.ASN1Cert
+		want  bool
+	}{
+		{"issuer key", caHash, []ct.ASN1Cert{{Data: ca.Raw}}, true},
+		{"leaf key", certHash, []ct.ASN1Cert{{Data: ca.Raw}}, false},
+		{"no chain", caHash, nil, false},
+		{"unparsable issuer", caHash, []ct.ASN1Cert{{Data: []byte("not a certificate")}}, false},
+	} {
+		m := MatchIssuerSPKIHash{Hash: test.hash}
+		entry := ct.LogEntry{X509Cert: cert, Chain: test.chain}
+		if got := m.LogEntryMatches(&entry); got != test.want {
+			t.Errorf("%s: LogEntryMatches()=%v; want %v", test.desc, got, test.want)
+		}
+		if got := entryMatches(m, &entry); got != test.want {
+			t.Errorf("%s: entryMatches()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+}
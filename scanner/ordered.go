@@ -0,0 +1,92 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"sync"
+
+	"golang.org/x/net/context"
+)
+
+// orderedDelivery reorders the results of matching entries, which finish
+// out of order across the matchers, so that they are delivered in ascending
+// order of index.  To bound the number of results held back, fetchers wait
+// before fetching entries too far beyond the next one to be delivered.
+type orderedDelivery struct {
+	// Maximum distance beyond next that a fetch may start at.
+	window int64
+
+	mu sync.Mutex
+	// next is the index of the next entry to be delivered.
+	next int64
+	// pending holds the deliveries for entries after next, keyed by index;
+	// a nil func means the entry had nothing to deliver.
+	pending map[int64]func()
+	// advanced is closed (and replaced) whenever next increases.
+	advanced chan struct{}
+}
+
+func newOrderedDelivery(next, window int64) *orderedDelivery {
+	return &orderedDelivery{
+		window:   window,
+		next:     next,
+		pending:  make(map[int64]func()),
+		advanced: make(chan struct{}),
+	}
+}
+
+// done records that the entry at index has been matched, with deliver (if
+// non-nil) passing the result to the caller's callback.  Deliveries are
+// made one at a time, in order, as soon as all earlier entries are done.
+func (o *orderedDelivery) done(index int64, deliver func()) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.pending[index] = deliver
+	start := o.next
+	for {
+		deliver, ok := o.pending[o.next]
+		if !ok {
+			break
+		}
+		delete(o.pending, o.next)
+		if deliver != nil {
+			deliver()
+		}
+		o.next++
+	}
+	if o.next != start {
+		close(o.advanced)
+		o.advanced = make(chan struct{})
+	}
+}
+
+// wait blocks until entries starting at index may be fetched without
+// exceeding the buffering window, or until ctx is done.
+func (o *orderedDelivery) wait(ctx context.Context, index int64) error {
+	for {
+		o.mu.Lock()
+		if index < o.next+o.window {
+			o.mu.Unlock()
+			return nil
+		}
+		advanced := o.advanced
+		o.mu.Unlock()
+		select {
+		case <-advanced:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,99 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/net/context"
+)
+
+func TestOrderedDeliveryReorders(t *testing.T) {
+	o := newOrderedDelivery(10, 100)
+	var got []int64
+	for _, index := range []int64{12, 11, 14, 10, 13} {
+		index := index
+		deliver := func() { got = append(got, index) }
+		if index == 11 {
+			// Entries with nothing to deliver must still advance the order.
+			deliver = nil
+		}
+		o.done(index, deliver)
+	}
+	if diff := pretty.Compare(got, []int64{10, 12, 13, 14}); diff != "" {
+		t.Errorf("delivered entries diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestOrderedDeliveryWait(t *testing.T) {
+	o := newOrderedDelivery(0, 2)
+	ctx := context.Background()
+	if err := o.wait(ctx, 1); err != nil {
+		t.Fatalf("wait(1)=%v; want nil", err)
+	}
+
+	waited := make(chan error)
+	go func() { waited <- o.wait(ctx, 2) }()
+	select {
+	case err := <-waited:
+		t.Fatalf("wait(2) returned %v before window advanced", err)
+	case <-time.After(10 * time.Millisecond):
+	}
+	o.done(0, nil)
+	if err := <-waited; err != nil {
+		t.Errorf("wait(2)=%v; want nil", err)
+	}
+
+	cctx, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := o.wait(cctx, 10); err != context.Canceled {
+		t.Errorf("wait(10) with cancelled context=%v; want %v", err, context.Canceled)
+	}
+}
+
+func TestScannerOrderedDelivery(t *testing.T) {
+	ts := newVerifiableLogServer(t, nil)
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(logClient, ScannerOptions{
+		Matcher:            &MatchAll{},
+		BatchSize:          1,
+		NumWorkers:         4,
+		ParallelFetch:      4,
+		Quiet:              true,
+		OrderedDelivery:    true,
+		MaxBufferedEntries: 1,
+	})
+	// Callbacks are serialized, so no locking is needed.
+	var indices []int64
+	found := func(e *ct.LogEntry) {
+		indices = append(indices, e.Index)
+	}
+	if err := scanner.Scan(found, found); err != nil {
+		t.Fatalf("Scan()=%v", err)
+	}
+	if diff := pretty.Compare(indices, []int64{0, 1, 2, 3}); diff != "" {
+		t.Errorf("Scan() found entries diff (-got +want):\n%s", diff)
+	}
+}
@@ -0,0 +1,399 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// ParseMatcher builds a Matcher from a textual query, such as:
+//
+//	dns_suffix:example.com and not (issuer:"Let's Encrypt" or key_size:>=2048)
+//
+// Terms may be combined with "and" (or "&&"), "or" (or "||") and "not" (or
+// "!"), in increasing order of precedence, and grouped with parentheses.
+// Each term is either "all", "none", or a name:value pair from:
+//
+//	subject:REGEX            CN or any SAN matches REGEX (MatchSubjectRegex)
+//	issuer:REGEX             issuer CN matches REGEX (MatchIssuerRegex)
+//	serial:NUMBER            serial number, decimal or 0x-prefixed hex
+//	dns_suffix:DOMAIN        a DNS SAN is DOMAIN or a subdomain of it
+//	key_algorithm:ALG        public key algorithm: rsa, dsa, ecdsa or ed25519
+//	key_size:[OP]BITS        public key size, where OP is <, <=, >, >= or =
+//	valid_at:TIME            certificate is valid at TIME
+//	valid_during:START..END  certificate is valid at some point in the window;
+//	                         either end may be omitted
+//	extension:OID            certificate has the extension, e.g. 2.5.29.17
+//	spki_sha256:HEX          SHA-256 hash of the SubjectPublicKeyInfo
+//	issuer_spki_sha256:HEX   SHA-256 hash of the issuer's SubjectPublicKeyInfo
+//
+// TIMEs are in RFC 3339 format, or just a date (YYYY-MM-DD, meaning midnight
+// UTC).  Values containing spaces or parentheses must be double-quoted, with
+// Go string escapes.
+func ParseMatcher(query string) (Matcher, error) {
+	tokens, err := tokenizeQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	p := queryParser{tokens: tokens}
+	m, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != nil {
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.offset)
+	}
+	return m, nil
+}
+
+type queryToken struct {
+	// text is the token itself; for name:value terms, it's the name.
+	text   string
+	value  string
+	isTerm bool
+	offset int
+}
+
+func tokenizeQuery(query string) ([]queryToken, error) {
+	var tokens []queryToken
+	for i := 0; i < len(query); {
+		switch c := query[i]; {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case c == '(' || c == ')' || c == '!':
+			tokens = append(tokens, queryToken{text: query[i : i+1], offset: i})
+			i++
+		case strings.HasPrefix(query[i:], "&&") || strings.HasPrefix(query[i:], "||"):
+			tokens = append(tokens, queryToken{text: query[i : i+2], offset: i})
+			i += 2
+		default:
+			start := i
+			for i < len(query) && isQueryNameChar(query[i]) {
+				i++
+			}
+			if i == start {
+				return nil, fmt.Errorf("unexpected character %q at offset %d", c, i)
+			}
+			tok := queryToken{text: query[start:i], offset: start}
+			if i < len(query) && query[i] == ':' {
+				i++
+				tok.isTerm = true
+				var err error
+				tok.value, i, err = scanQueryValue(query, i)
+				if err != nil {
+					return nil, err
+				}
+			}
+			tokens = append(tokens, tok)
+		}
+	}
+	return tokens, nil
+}
+
+func isQueryNameChar(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+// scanQueryValue reads the value starting at query[i], returning it along
+// with the offset just past its end.
+func scanQueryValue(query string, i int) (string, int, error) {
+	start := i
+	if i < len(query) && query[i] == '"' {
+		for i++; i < len(query) && query[i] != '"'; i++ {
+			if query[i] == '\\' {
+				i++
+			}
+		}
+		if i >= len(query) {
+			return "", 0, fmt.Errorf("unterminated string at offset %d", start)
+		}
+		i++
+		value, err := strconv.Unquote(query[start:i])
+		if err != nil {
+			return "", 0, fmt.Errorf("invalid string at offset %d: %v", start, err)
+		}
+		return value, i, nil
+	}
+	for i < len(query) && !strings.ContainsRune(" \t\r\n()", rune(query[i])) {
+		i++
+	}
+	return query[start:i], i, nil
+}
+
+type queryParser struct {
+	tokens []queryToken
+	pos    int
+}
+
+func (p *queryParser) peek() *queryToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// accept consumes the next token if it is one of the given operators
+// (compared case-insensitively).
+func (p *queryParser) accept(ops ...string) bool {
+	tok := p.peek()
+	if tok == nil || tok.isTerm {
+		return false
+	}
+	for _, op := range ops {
+		if strings.EqualFold(tok.text, op) {
+			p.pos++
+			return true
+		}
+	}
+	return false
+}
+
+func (p *queryParser) parseOr() (Matcher, error) {
+	m, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	or := MatchOr{m}
+	for p.accept("or", "||") {
+		m, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		or = append(or, m)
+	}
+	if len(or) == 1 {
+		return or[0], nil
+	}
+	return or, nil
+}
+
+func (p *queryParser) parseAnd() (Matcher, error) {
+	m, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	and := MatchAnd{m}
+	for p.accept("and", "&&") {
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		and = append(and, m)
+	}
+	if len(and) == 1 {
+		return and[0], nil
+	}
+	return and, nil
+}
+
+func (p *queryParser) parseUnary() (Matcher, error) {
+	if p.accept("not", "!") {
+		m, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return MatchNot{Matcher: m}, nil
+	}
+	if p.accept("(") {
+		m, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.accept(")") {
+			return nil, p.errorf("expected ')'")
+		}
+		return m, nil
+	}
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.errorf("expected term")
+	}
+	p.pos++
+	if !tok.isTerm {
+		switch strings.ToLower(tok.text) {
+		case "all":
+			return MatchAll{}, nil
+		case "none":
+			return MatchNone{}, nil
+		}
+		return nil, fmt.Errorf("unexpected %q at offset %d", tok.text, tok.offset)
+	}
+	m, err := parseQueryTerm(strings.ToLower(tok.text), tok.value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid term %s:%q at offset %d: %v", tok.text, tok.value, tok.offset, err)
+	}
+	return m, nil
+}
+
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	msg := fmt.Sprintf(format, args...)
+	if tok := p.peek(); tok != nil {
+		return fmt.Errorf("%s at offset %d, found %q", msg, tok.offset, tok.text)
+	}
+	return fmt.Errorf("%s at end of query", msg)
+}
+
+func parseQueryTerm(name, value string) (Matcher, error) {
+	switch name {
+	case "subject":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return MatchSubjectRegex{CertificateSubjectRegex: re, PrecertificateSubjectRegex: re}, nil
+	case "issuer":
+		re, err := regexp.Compile(value)
+		if err != nil {
+			return nil, err
+		}
+		return MatchIssuerRegex{CertificateIssuerRegex: re, PrecertificateIssuerRegex: re}, nil
+	case "serial":
+		var sn big.Int
+		if _, ok := sn.SetString(value, 0); !ok {
+			return nil, fmt.Errorf("invalid serial number")
+		}
+		return MatchSerialNumber{SerialNumber: sn}, nil
+	case "dns_suffix":
+		if value == "" {
+			return nil, fmt.Errorf("empty domain")
+		}
+		return MatchDNSSuffix{Suffix: value}, nil
+	case "key_algorithm":
+		switch strings.ToLower(value) {
+		case "rsa":
+			return MatchPublicKey{Algorithm: x509.RSA}, nil
+		case "dsa":
+			return MatchPublicKey{Algorithm: x509.DSA}, nil
+		case "ecdsa":
+			return MatchPublicKey{Algorithm: x509.ECDSA}, nil
+		case "ed25519":
+			return MatchPublicKey{Algorithm: x509.Ed25519}, nil
+		}
+		return nil, fmt.Errorf("unknown key algorithm")
+	case "key_size":
+		return parseKeySize(value)
+	case "valid_at":
+		t, err := parseQueryTime(value)
+		if err != nil {
+			return nil, err
+		}
+		return MatchValidity{Start: t, End: t}, nil
+	case "valid_during":
+		parts := strings.SplitN(value, "..", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("expected START..END")
+		}
+		var m MatchValidity
+		var err error
+		if parts[0] != "" {
+			if m.Start, err = parseQueryTime(parts[0]); err != nil {
+				return nil, err
+			}
+		}
+		if parts[1] != "" {
+			if m.End, err = parseQueryTime(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case "extension":
+		oid, err := parseOID(value)
+		if err != nil {
+			return nil, err
+		}
+		return MatchExtension{OID: oid}, nil
+	case "spki_sha256":
+		var m MatchSPKIHash
+		if err := parseSHA256(value, m.Hash[:]); err != nil {
+			return nil, err
+		}
+		return m, nil
+	case "issuer_spki_sha256":
+		var m MatchIssuerSPKIHash
+		if err := parseSHA256(value, m.Hash[:]); err != nil {
+			return nil, err
+		}
+		return m, nil
+	}
+	return nil, fmt.Errorf("unknown term")
+}
+
+func parseKeySize(value string) (Matcher, error) {
+	op := strings.TrimRight(value, "0123456789")
+	bits, err := strconv.Atoi(value[len(op):])
+	if err != nil || bits <= 0 {
+		return nil, fmt.Errorf("invalid key size")
+	}
+	switch op {
+	case "", "=":
+		return MatchPublicKey{MinSize: bits, MaxSize: bits}, nil
+	case ">=":
+		return MatchPublicKey{MinSize: bits}, nil
+	case ">":
+		return MatchPublicKey{MinSize: bits + 1}, nil
+	case "<=":
+		return MatchPublicKey{MaxSize: bits}, nil
+	case "<":
+		if bits == 1 {
+			return MatchNone{}, nil
+		}
+		return MatchPublicKey{MaxSize: bits - 1}, nil
+	}
+	return nil, fmt.Errorf("unknown comparison %q", op)
+}
+
+func parseQueryTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+func parseOID(value string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("OID must have at least two components")
+	}
+	oid := make(asn1.ObjectIdentifier, len(parts))
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID component %q", part)
+		}
+		oid[i] = n
+	}
+	return oid, nil
+}
+
+func parseSHA256(value string, hash []byte) error {
+	data, err := hex.DecodeString(value)
+	if err != nil {
+		return err
+	}
+	if len(data) != len(hash) {
+		return fmt.Errorf("hash must be %d bytes, got %d", len(hash), len(data))
+	}
+	copy(hash, data)
+	return nil
+}
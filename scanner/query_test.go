@@ -0,0 +1,179 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"encoding/json"
+	"testing"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/crypto/ed25519"
+)
+
+// fourEntries returns the entries in FourEntries, parsed as the Scanner
+// would before matching.
+func fourEntries(t *testing.T) []*ct.LogEntry {
+	var rsp ct.GetEntriesResponse
+	if err := json.Unmarshal([]byte(FourEntries), &rsp); err != nil {
+		t.Fatalf("Failed to parse test entries: %v", err)
+	}
+	var entries []*ct.LogEntry
+	for i := range rsp.Entries {
+		entry, err := ct.LogEntryFromLeaf(int64(i), &rsp.Entries[i])
+		if err != nil {
+			t.Fatalf("LogEntryFromLeaf(%d)=%v", i, err)
+		}
+		entry.X509Cert, err = x509.ParseCertificate(entry.Leaf.TimestampedEntry.X509Entry.Data)
+		if err != nil {
+			t.Fatalf("ParseCertificate(%d)=%v", i, err)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestParseMatcher(t *testing.T) {
+	entries := fourEntries(t)
+	for _, test := range []struct {
+		query string
+		want  []int64
+	}{
+		{query: "all", want: []int64{0, 1, 2, 3}},
+		{query: "none"},
+		{query: `subject:google\.com$`, want: []int64{0}},
+		{query: `issuer:"GlobalSign Extended"`, want: []int64{3}},
+		{query: "serial:63034", want: []int64{1}},
+		{query: "dns_suffix:oxfordplayhouse.com", want: []int64{3}},
+		{query: "dns_suffix:.GOOGLE.com", want: []int64{0}},
+		{query: "dns_suffix:playhouse.com"},
+		{query: "key_algorithm:RSA", want: []int64{0, 1, 2, 3}},
+		{query: "key_algorithm:ecdsa"},
+		{query: "key_algorithm:Ed25519"},
+		{query: "key_size:<2048", want: []int64{0}},
+		{query: "key_size:>=2048", want: []int64{1, 2, 3}},
+		{query: "key_size:1024", want: []int64{0}},
+		{query: "valid_at:2014-01-01", want: []int64{2}},
+		{query: "valid_during:2013-10-01..2013-11-01", want: []int64{1, 2, 3}},
+		{query: "valid_during:..2011-10-15", want: []int64{3}},
+		{query: "valid_during:2015-03-31T14:58:00Z..", want: []int64{2}},
+		{query: "extension:2.5.29.32", want: []int64{2, 3}},
+		{query: "spki_sha256:6cb6a6b121d3fcbd63ca059a5d778f8eb0ff5ba87f059526e1e5b7b56b23bc8e", want: []int64{0}},
+		{query: "issuer_spki_sha256:c36c41370fa3eab517977286dff5a4ce703f020f81d570c460a5b385df7597f8", want: []int64{2}},
+		{query: "key_size:2048 and extension:2.5.29.32", want: []int64{2, 3}},
+		{query: "key_size:2048 && !extension:2.5.29.32", want: []int64{1}},
+		{query: "serial:63034 or subject:netkeiba || dns_suffix:google.com", want: []int64{0, 1, 2}},
+		{query: "not (serial:63034 OR subject:netkeiba) and key_size:2048", want: []int64{3}},
+		{query: "subject:www or serial:63034 and not subject:struleart", want: []int64{1, 2, 3}},
+		{query: `NOT NOT subject:"(mail|www)\\.google"`, want: []int64{0}},
+	} {
+		m, err := ParseMatcher(test.query)
+		if err != nil {
+			t.Errorf("ParseMatcher(%q)=%v", test.query, err)
+			continue
+		}
+		var got []int64
+		for _, entry := range entries {
+			if entryMatches(m, entry) {
+				got = append(got, entry.Index)
+			}
+		}
+		if diff := pretty.Compare(got, test.want); diff != "" {
+			t.Errorf("ParseMatcher(%q) matched entries diff (-got +want):\n%s", test.query, diff)
+		}
+	}
+}
+
+func TestParseMatcherKeyAlgorithm(t *testing.T) {
+	for _, test := range []struct {
+		value string
+		want  x509.PublicKeyAlgorithm
+	}{
+		{"rsa", x509.RSA},
+		{"DSA", x509.DSA},
+		{"ecdsa", x509.ECDSA},
+		{"ed25519", x509.Ed25519},
+		{"Ed25519", x509.Ed25519},
+	} {
+		query := "key_algorithm:" + test.value
+		m, err := ParseMatcher(query)
+		if err != nil {
+			t.Errorf("ParseMatcher(%q)=%v", query, err)
+			continue
+		}
+		if want := (MatchPublicKey{Algorithm: test.want}); m != want {
+			t.Errorf("ParseMatcher(%q)=%+v; want %+v", query, m, want)
+		}
+		cert := x509.Certificate{PublicKeyAlgorithm: test.want}
+		if !m.CertificateMatches(&cert) {
+			t.Errorf("ParseMatcher(%q) didn't match a certificate with algorithm %v", query, test.want)
+		}
+	}
+}
+
+func TestParseMatcherEd25519KeySize(t *testing.T) {
+	cert := x509.Certificate{
+		PublicKeyAlgorithm: x509.Ed25519,
+		PublicKey:          ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)),
+	}
+	for _, test := range []struct {
+		query string
+		want  bool
+	}{
+		{query: "key_algorithm:ed25519 and key_size:256", want: true},
+		{query: "key_size:<=256", want: true},
+		{query: "key_size:>256", want: false},
+		{query: "not key_size:>=2048", want: true},
+	} {
+		m, err := ParseMatcher(test.query)
+		if err != nil {
+			t.Errorf("ParseMatcher(%q)=%v", test.query, err)
+			continue
+		}
+		if got := m.CertificateMatches(&cert); got != test.want {
+			t.Errorf("ParseMatcher(%q).CertificateMatches(Ed25519 cert)=%v; want %v", test.query, got, test.want)
+		}
+	}
+}
+
+func TestParseMatcherErrors(t *testing.T) {
+	for _, query := range []string{
+		"",
+		"bogus",
+		"bogus:1",
+		"all and",
+		"(all",
+		"all)",
+		"all none",
+		`subject:"unterminated`,
+		"subject:[",
+		"serial:abc",
+		"key_algorithm:rot13",
+		"key_size:~2048",
+		"key_size:>",
+		"valid_at:yesterday",
+		"valid_during:2017-01-01",
+		"extension:2",
+		"extension:2.x",
+		"spki_sha256:abcd",
+		"issuer_spki_sha256:zz",
+		"all & none",
+	} {
+		if m, err := ParseMatcher(query); err == nil {
+			t.Errorf("ParseMatcher(%q)=%+v,nil; want error", query, m)
+		}
+	}
+}
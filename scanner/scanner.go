@@ -17,9 +17,11 @@ package scanner
 
 import (
 	"container/list"
+	"errors"
 	"fmt"
 	"log"
 	"math/big"
+	"net/http"
 	"regexp"
 	"sync"
 	"sync/atomic"
@@ -27,6 +29,8 @@ import (
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/merkletree"
 	"github.com/google/certificate-transparency-go/x509"
 	"golang.org/x/net/context"
 )
@@ -160,8 +164,75 @@ type ScannerOptions struct {
 
 	// Don't print any status messages to stdout
 	Quiet bool
+
+	// Verify that the fetched entries are those committed to by the STH
+	// which the scan runs against, by recalculating the tree root from
+	// every entry's LeafInput.  The scan must start at index 0, unless it
+	// resumes from a Checkpoint that holds the TreeHashes of the entries
+	// before its NextIndex.  A mismatch is reported as an
+	// InconsistentEntriesError.
+	VerifyEntries bool
+
+	// When VerifyEntries is set, also check the partial tree against the
+	// STH (using a consistency proof from the log) each time this many
+	// more contiguous entries have been fetched.  Zero means the tree is
+	// only checked once the scan completes.
+	VerifyCheckpointInterval int64
+
+	// Checkpointer, if non-nil, is used to record the scan's progress and
+	// to resume from the last saved Checkpoint (in preference to
+	// StartIndex).  A scan which is resumed runs against the same STH as
+	// before, unless the previous scan had finished, in which case it
+	// continues from there to the log's current STH.
+	Checkpointer Checkpointer
+
+	// How often to save a Checkpoint while scanning; one is always saved
+	// when the scan completes.
+	CheckpointPeriod time.Duration
+
+	// How long Follow waits between checks for a new STH; if zero, a
+	// default of one minute is used.
+	PollInterval time.Duration
+
+	// Number of times a failed request to the log is retried before the
+	// scan gives up and returns the error; if zero, a default of 10 is used.
+	// Requests that the log rejects with a 4xx status (other than 429 Too
+	// Many Requests) are not retried.
+	MaxRetries int
+
+	// How long to wait before the first retry of a failed request; the
+	// wait doubles for each consecutive failure, up to MaxRetryBackoff.  If
+	// zero, defaults of one second and two minutes respectively are used.
+	RetryBackoff    time.Duration
+	MaxRetryBackoff time.Duration
+
+	// Pass matching entries to the foundCert and foundPrecert callbacks one
+	// at a time, in strictly ascending order of index, rather than from each
+	// matcher as soon as they're found.  Entries are still fetched and
+	// matched in parallel.
+	OrderedDelivery bool
+
+	// If non-nil, called for each entry which can't be fully parsed,
+	// including those whose certificate was parsed with only non-fatal
+	// errors (which are also matched as usual).  With OrderedDelivery, calls
+	// are ordered along with those for matching entries.
+	FoundUnparsable func(*UnparsableEntry)
+
+	// With OrderedDelivery, the maximum number of entries beyond the next
+	// one to be delivered which may be fetched ahead of it (plus up to a
+	// further BatchSize); if zero, a default of 100000 is used.
+	MaxBufferedEntries int64
 }
 
+const (
+	defaultPollInterval    = time.Minute
+	defaultMaxRetries      = 10
+	defaultRetryBackoff    = time.Second
+	defaultMaxRetryBackoff = 2 * time.Minute
+
+	defaultMaxBufferedEntries = 100000
+)
+
 // DefaultScannerOptions creates a new ScannerOptions struct with sensible defaults.
 func DefaultScannerOptions() *ScannerOptions {
 	return &ScannerOptions{
@@ -172,6 +243,13 @@ func DefaultScannerOptions() *ScannerOptions {
 		ParallelFetch: 1,
 		StartIndex:    0,
 		Quiet:         false,
+
+		CheckpointPeriod: 10 * time.Second,
+		PollInterval:     defaultPollInterval,
+
+		MaxRetries:      defaultMaxRetries,
+		RetryBackoff:    defaultRetryBackoff,
+		MaxRetryBackoff: defaultMaxRetryBackoff,
 	}
 }
 
@@ -183,30 +261,71 @@ type Scanner struct {
 	// Configuration options for this Scanner instance
 	opts ScannerOptions
 
-	// Counter of the number of certificates scanned
-	certsProcessed int64
+	// Counters of the entries processed during the scan.
+	counts ScanCounts
 
-	// Counter of the number of precertificates encountered during the scan.
-	precertsSeen int64
+	// Tracks which entries have been completely processed.
+	progress *progressTracker
 
-	unparsableEntries         int64
-	entriesWithNonFatalErrors int64
+	// Reorders matches, if OrderedDelivery is set.
+	ordered *orderedDelivery
 
 	Log func(msg string)
 }
 
 // matcherJob represents the context for an individual matcher job.
 type matcherJob struct {
-	// The log entry returned by the log server
-	entry ct.LogEntry
+	// The raw log entry returned by the log server
+	leaf ct.LeafEntry
 	// The index of the entry containing the LeafInput in the log
 	index int64
+	// The progress of the fetchRange that the entry came from
+	progress *rangeProgress
+}
+
+// UnparsableEntry describes a log entry which could not be fully parsed.
+type UnparsableEntry struct {
+	// The index of the entry in the log.
+	Index int64
+	// The raw entry, as returned by the log.
+	Leaf ct.LeafEntry
+	// As much of the entry as could be parsed.  This is nil if the
+	// MerkleTreeLeaf itself couldn't be parsed; otherwise X509Cert or
+	// Precert is set if the certificate was parsed with non-fatal errors.
+	Entry *ct.LogEntry
+	// The error encountered, which is an x509.NonFatalErrors if the
+	// certificate was parsed despite problems.
+	Err error
+}
+
+// NonFatal returns true if the entry's certificate was parsed despite its
+// errors.
+func (u *UnparsableEntry) NonFatal() bool {
+	_, ok := u.Err.(x509.NonFatalErrors)
+	return ok
 }
 
 // fetchRange represents a range of certs to fetch from a CT log
 type fetchRange struct {
 	start int64
 	end   int64
+	// Tracks processing of the range's entries
+	progress *rangeProgress
+}
+
+// scanError records the first fatal error encountered by the workers of a
+// scan, and cancels the scan's context so that the other workers stop.
+type scanError struct {
+	once   sync.Once
+	err    error
+	cancel context.CancelFunc
+}
+
+func (e *scanError) set(err error) {
+	e.once.Do(func() {
+		e.err = err
+		e.cancel()
+	})
 }
 
 // Takes the error returned by either x509.ParseCertificate() or
@@ -217,102 +336,242 @@ type fetchRange struct {
 // Fatal errors will be logged, unparsableEntires will be incremented, and the
 // fatal error itself will be returned.
 // When err is nil, this method does nothing.
-func (s *Scanner) handleParseEntryError(err error, entryType ct.LogEntryType, index int64) error {
+func (s *Scanner) handleParseEntryError(err error, entryType ct.LogEntryType, index int64, counts *ScanCounts) error {
 	if err == nil {
 		// No error to handle
 		return nil
 	}
 	switch err.(type) {
 	case x509.NonFatalErrors:
-		atomic.AddInt64(&s.entriesWithNonFatalErrors, 1)
+		counts.EntriesWithNonFatalErrors++
 		// We'll make a note, but continue.
 		s.Log(fmt.Sprintf("Non-fatal error in %+v at index %d: %s", entryType, index, err.Error()))
 	default:
-		atomic.AddInt64(&s.unparsableEntries, 1)
+		counts.UnparsableEntries++
 		s.Log(fmt.Sprintf("Failed to parse in %+v at index %d : %s", entryType, index, err.Error()))
 		return err
 	}
 	return nil
 }
 
-// Processes the given entry in the specified log.
-func (s *Scanner) processEntry(entry ct.LogEntry, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry)) {
-	atomic.AddInt64(&s.certsProcessed, 1)
+// Processes the given entry in the specified log, updating counts to reflect
+// the outcome.  foundUnparsable may be nil.
+func (s *Scanner) processEntry(leaf *ct.LeafEntry, index int64, counts *ScanCounts, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry), foundUnparsable func(*UnparsableEntry)) {
+	counts.CertsProcessed++
+	entry, err := ct.LogEntryFromLeaf(index, leaf)
+	if err != nil {
+		counts.UnparsableEntries++
+		s.Log(fmt.Sprintf("Failed to parse entry at index %d: %s", index, err.Error()))
+		if foundUnparsable != nil {
+			foundUnparsable(&UnparsableEntry{Index: index, Leaf: *leaf, Err: err})
+		}
+		return
+	}
+	// Reports a (possibly non-fatal) parse error, once any partially parsed
+	// certificate has been filled in.
+	reportUnparsable := func(err error) {
+		if err != nil && foundUnparsable != nil {
+			foundUnparsable(&UnparsableEntry{Index: index, Leaf: *leaf, Entry: entry, Err: err})
+		}
+	}
 	switch entry.Leaf.TimestampedEntry.EntryType {
 	case ct.X509LogEntryType:
 		if s.opts.PrecertOnly {
 			// Only interested in precerts and this is an X.509 cert, early-out.
 			return
 		}
-		cert, err := x509.ParseCertificate(entry.Leaf.TimestampedEntry.X509Entry.Data)
-		if err = s.handleParseEntryError(err, entry.Leaf.TimestampedEntry.EntryType, entry.Index); err != nil {
+		cert, parseErr := x509.ParseCertificate(entry.Leaf.TimestampedEntry.X509Entry.Data)
+		if err = s.handleParseEntryError(parseErr, entry.Leaf.TimestampedEntry.EntryType, entry.Index, counts); err != nil {
 			// We hit an unparseable entry, already logged inside handleParseEntryError()
+			reportUnparsable(err)
 			return
 		}
-		if s.opts.Matcher.CertificateMatches(cert) {
-			entry.X509Cert = cert
-			foundCert(&entry)
+		entry.X509Cert = cert
+		reportUnparsable(parseErr)
+		if entryMatches(s.opts.Matcher, entry) {
+			foundCert(entry)
 		}
 	case ct.PrecertLogEntryType:
-		c, err := x509.ParseTBSCertificate(entry.Leaf.TimestampedEntry.PrecertEntry.TBSCertificate)
-		if err = s.handleParseEntryError(err, entry.Leaf.TimestampedEntry.EntryType, entry.Index); err != nil {
+		c, parseErr := x509.ParseTBSCertificate(entry.Leaf.TimestampedEntry.PrecertEntry.TBSCertificate)
+		if err = s.handleParseEntryError(parseErr, entry.Leaf.TimestampedEntry.EntryType, entry.Index, counts); err != nil {
 			// We hit an unparseable entry, already logged inside handleParseEntryError()
+			reportUnparsable(err)
 			return
 		}
 		precert := &ct.Precertificate{
 			Raw:            entry.Chain[0].Data,
 			TBSCertificate: *c,
 			IssuerKeyHash:  entry.Leaf.TimestampedEntry.PrecertEntry.IssuerKeyHash}
-		if s.opts.Matcher.PrecertificateMatches(precert) {
-			entry.Precert = precert
-			foundPrecert(&entry)
+		entry.Precert = precert
+		reportUnparsable(parseErr)
+		if entryMatches(s.opts.Matcher, entry) {
+			foundPrecert(entry)
 		}
-		atomic.AddInt64(&s.precertsSeen, 1)
+		counts.PrecertsSeen++
 	}
 }
 
 // Worker function to match certs.
-// Accepts MatcherJobs over the entries channel, and processes them.
+// Accepts MatcherJobs over the entries channel, and processes them.  Once ctx
+// is done, any remaining jobs are discarded unprocessed.
 // Returns true over the done channel when the entries channel is closed.
-func (s *Scanner) matcherJob(id int, entries <-chan matcherJob, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry), wg *sync.WaitGroup) {
+func (s *Scanner) matcherJob(ctx context.Context, id int, entries <-chan matcherJob, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry), scanErr *scanError, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for e := range entries {
-		s.processEntry(e.entry, foundCert, foundPrecert)
+		if ctx.Err() != nil {
+			continue
+		}
+		var counts ScanCounts
+		if s.ordered != nil {
+			var deliveries []func()
+			var foundUnparsable func(*UnparsableEntry)
+			if s.opts.FoundUnparsable != nil {
+				foundUnparsable = func(u *UnparsableEntry) {
+					deliveries = append(deliveries, func() { s.opts.FoundUnparsable(u) })
+				}
+			}
+			s.processEntry(&e.leaf, e.index, &counts, func(entry *ct.LogEntry) {
+				deliveries = append(deliveries, func() { foundCert(entry) })
+			}, func(entry *ct.LogEntry) {
+				deliveries = append(deliveries, func() { foundPrecert(entry) })
+			}, foundUnparsable)
+			var deliver func()
+			if len(deliveries) > 0 {
+				deliver = func() {
+					for _, d := range deliveries {
+						d()
+					}
+				}
+			}
+			s.ordered.done(e.index, deliver)
+		} else {
+			s.processEntry(&e.leaf, e.index, &counts, foundCert, foundPrecert, s.opts.FoundUnparsable)
+		}
+		s.counts.add(&counts)
+		if err := s.progress.entryDone(ctx, e.progress, &counts); err != nil {
+			scanErr.set(err)
+		}
 	}
 	s.Log(fmt.Sprintf("Matcher %d finished", id))
-	wg.Done()
 }
 
 // Worker function for fetcher jobs.
 // Accepts cert ranges to fetch over the ranges channel, and if the fetch is
 // successful sends the individual LeafInputs out (as MatcherJobs) into the
 // entries channel for the matchers to chew on.
-// Will retry failed attempts to retrieve ranges indefinitely.
-// Sends true over the done channel when the ranges channel is closed.
-func (s *Scanner) fetcherJob(id int, ranges <-chan fetchRange, entries chan<- matcherJob, wg *sync.WaitGroup) {
+// If a range can't be fetched, the error is recorded in scanErr (which
+// stops the scan), and the fetcher exits.
+func (s *Scanner) fetcherJob(ctx context.Context, id int, ranges <-chan fetchRange, entries chan<- matcherJob, scanErr *scanError, wg *sync.WaitGroup) {
+	defer wg.Done()
 	for r := range ranges {
-		success := false
-		// TODO(alcutter): give up after a while:
-		for !success {
-			logEntries, err := s.logClient.GetEntries(context.TODO(), r.start, r.end)
-			if err != nil {
-				s.Log(fmt.Sprintf("Problem fetching from log: %s", err.Error()))
-				continue
+		if s.ordered != nil {
+			if err := s.ordered.wait(ctx, r.start); err != nil {
+				s.Log(fmt.Sprintf("Fetcher %d stopped: %v", id, err))
+				return
+			}
+		}
+		if err := s.fetch(ctx, r, entries); err != nil {
+			if ctx.Err() == nil {
+				scanErr.set(err)
+			}
+			s.Log(fmt.Sprintf("Fetcher %d stopped: %v", id, err))
+			return
+		}
+	}
+	s.Log(fmt.Sprintf("Fetcher %d finished", id))
+}
+
+// fetch retrieves the entries in r from the log and passes them to the
+// matchers, retrying failed requests according to the Scanner's options.
+func (s *Scanner) fetch(ctx context.Context, r fetchRange, entries chan<- matcherJob) error {
+	leaves := r.progress.leaves
+	failures := 0
+	for r.start <= r.end {
+		resp, err := s.logClient.GetRawEntries(ctx, r.start, r.end)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err == nil {
+			if len(resp.Entries) > int(r.end-r.start+1) {
+				resp.Entries = resp.Entries[:r.end-r.start+1]
 			}
-			for _, logEntry := range logEntries {
-				logEntry.Index = r.start
-				entries <- matcherJob{logEntry, r.start}
+			for _, leafEntry := range resp.Entries {
+				if leaves != nil {
+					leaves.AppendLeaf(leafEntry.LeafInput)
+				}
+				entries <- matcherJob{leafEntry, r.start, r.progress}
 				r.start++
 			}
-			if r.start > r.end {
-				// Only complete if we actually got all the leaves we were
-				// expecting -- Logs MAY return fewer than the number of
-				// leaves requested.
-				success = true
+			// Logs MAY return fewer than the number of leaves requested, in
+			// which case the rest are requested again, but they must return
+			// at least one.
+			if len(resp.Entries) == 0 {
+				err = errors.New("log returned no entries")
 			}
 		}
+		if err == nil {
+			failures = 0
+			continue
+		}
+		if rspErr, ok := err.(jsonclient.RspError); ok && isFatalStatus(rspErr.StatusCode) {
+			return fmt.Errorf("failed to fetch entries [%d, %d]: %v", r.start, r.end, err)
+		}
+		failures++
+		if failures > s.maxRetries() {
+			return fmt.Errorf("failed to fetch entries [%d, %d] after %d attempts: %v", r.start, r.end, failures, err)
+		}
+		backoff := s.retryBackoff(failures)
+		s.Log(fmt.Sprintf("Problem fetching entries [%d, %d] from log, retrying in %s: %v", r.start, r.end, backoff, err))
+		if err := sleep(ctx, backoff); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isFatalStatus reports whether a request that got an HTTP response with the
+// given status should not be retried: the log rejected the request itself,
+// rather than being unavailable or overloaded.
+func isFatalStatus(status int) bool {
+	return status >= 400 && status < 500 && status != http.StatusTooManyRequests
+}
+
+func (s *Scanner) maxRetries() int {
+	if s.opts.MaxRetries <= 0 {
+		return defaultMaxRetries
+	}
+	return s.opts.MaxRetries
+}
+
+// retryBackoff returns how long to wait before retrying a request which has
+// failed the given number of consecutive times.
+func (s *Scanner) retryBackoff(failures int) time.Duration {
+	backoff, maxBackoff := s.opts.RetryBackoff, s.opts.MaxRetryBackoff
+	if backoff <= 0 {
+		backoff = defaultRetryBackoff
+	}
+	if maxBackoff <= 0 {
+		maxBackoff = defaultMaxRetryBackoff
+	}
+	for i := 1; i < failures && backoff < maxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > maxBackoff {
+		backoff = maxBackoff
+	}
+	return backoff
+}
+
+// sleep waits for d, or until ctx is done in which case its error is returned.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
 	}
-	s.Log(fmt.Sprintf("Fetcher %d finished", id))
-	wg.Done()
 }
 
 func min(a int64, b int64) int64 {
@@ -360,38 +619,221 @@ func humanTime(seconds int) string {
 // This method blocks until the scan is complete.
 func (s *Scanner) Scan(foundCert func(*ct.LogEntry),
 	foundPrecert func(*ct.LogEntry)) error {
-	s.Log("Starting up...\n")
-	s.certsProcessed = 0
-	s.precertsSeen = 0
-	s.unparsableEntries = 0
-	s.entriesWithNonFatalErrors = 0
+	return s.ScanWithContext(context.Background(), foundCert, foundPrecert)
+}
 
-	latestSth, err := s.logClient.GetSTH(context.Background())
+// ScanWithContext performs a scan against the Log in the same way as Scan,
+// but stops early if ctx is done, returning ctx.Err().  If the scan fails
+// (for example because entries can't be fetched from the Log after the
+// configured number of retries, or they don't match the STH), the first
+// such error is returned; the Checkpointer, if any, still records whatever
+// progress was made.
+func (s *Scanner) ScanWithContext(ctx context.Context, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry)) error {
+	sth, err := s.startScan(ctx)
 	if err != nil {
 		return err
 	}
-	s.Log(fmt.Sprintf("Got STH with %d certs", latestSth.TreeSize))
+	return s.scanTo(ctx, sth, foundCert, foundPrecert)
+}
+
+// Follow scans the Log in the same way as Scan, but rather than stopping at
+// the end of the tree it polls the Log for new STHs every PollInterval, and
+// scans any newly appended entries as they appear.  Each new STH is checked
+// for consistency with the previous one, and an STHConsistencyError returned
+// if they differ.
+//
+// Failures to fetch or check a new STH are retried at the next poll, up to
+// MaxRetries consecutive times.
+//
+// This method blocks until ctx is done (or an error occurs).
+func (s *Scanner) Follow(ctx context.Context, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry)) error {
+	sth, err := s.startScan(ctx)
+	if err != nil {
+		return err
+	}
+	pollInterval := s.opts.PollInterval
+	if pollInterval <= 0 {
+		pollInterval = defaultPollInterval
+	}
+	for {
+		if err := s.scanTo(ctx, sth, foundCert, foundPrecert); err != nil {
+			return err
+		}
+		failures := 0
+		for {
+			if err := sleep(ctx, pollInterval); err != nil {
+				return err
+			}
+			newSTH, err := s.logClient.GetSTH(ctx)
+			if err == nil {
+				err = s.checkSTHConsistency(ctx, sth, newSTH)
+				if _, ok := err.(STHConsistencyError); ok {
+					return err
+				}
+			}
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if err != nil {
+				failures++
+				if failures > s.maxRetries() {
+					return fmt.Errorf("failed to get new STH after %d attempts: %v", failures, err)
+				}
+				s.Log(fmt.Sprintf("Failed to get new STH: %v", err))
+				continue
+			}
+			failures = 0
+			if newSTH.TreeSize > sth.TreeSize {
+				s.Log(fmt.Sprintf("Got STH with %d certs", newSTH.TreeSize))
+				sth = newSTH
+				break
+			}
+		}
+	}
+}
+
+// STHConsistencyError indicates that a Log produced two STHs which are not
+// consistent with each other.
+type STHConsistencyError struct {
+	Old, New ct.SignedTreeHead
+	Err      error
+}
+
+func (e STHConsistencyError) Error() string {
+	return fmt.Sprintf("STH for tree size %d inconsistent with earlier STH for tree size %d: %v", e.New.TreeSize, e.Old.TreeSize, e.Err)
+}
+
+// checkSTHConsistency checks that the tree in newSTH is an extension of the
+// one in oldSTH, returning an STHConsistencyError if not.  Other errors
+// indicate that consistency could not be checked.
+func (s *Scanner) checkSTHConsistency(ctx context.Context, oldSTH, newSTH *ct.SignedTreeHead) error {
+	fail := func(err error) error {
+		return STHConsistencyError{Old: *oldSTH, New: *newSTH, Err: err}
+	}
+	if newSTH.TreeSize < oldSTH.TreeSize {
+		return fail(errors.New("tree size decreased"))
+	}
+	_, err := s.logClient.VerifySTHConsistency(ctx, oldSTH, newSTH)
+	switch err.(type) {
+	case client.ConsistencyProofError, client.LogForkError:
+		return fail(err)
+	}
+	return err
+}
+
+// startScan prepares the Scanner for a new scan, resuming from a Checkpoint
+// if there is one, and returns the STH to scan up to.
+func (s *Scanner) startScan(ctx context.Context) (*ct.SignedTreeHead, error) {
+	s.Log("Starting up...\n")
+	s.counts = ScanCounts{}
+
+	startIndex := s.opts.StartIndex
+	var checkpoint *Checkpoint
+	if s.opts.Checkpointer != nil {
+		var err error
+		checkpoint, err = s.opts.Checkpointer.LoadCheckpoint()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load checkpoint: %v", err)
+		}
+	}
+	var latestSth *ct.SignedTreeHead
+	if checkpoint != nil {
+		startIndex = checkpoint.NextIndex
+		s.counts = checkpoint.Counts
+		if uint64(checkpoint.NextIndex) < checkpoint.STH.TreeSize {
+			latestSth = &checkpoint.STH
+			s.Log(fmt.Sprintf("Resuming scan at index %d against STH with %d certs", startIndex, latestSth.TreeSize))
+		}
+	}
+	if latestSth == nil {
+		var err error
+		latestSth, err = s.logClient.GetSTH(ctx)
+		if err != nil {
+			return nil, err
+		}
+		s.Log(fmt.Sprintf("Got STH with %d certs", latestSth.TreeSize))
+	}
+
+	var verifier *entryVerifier
+	if s.opts.VerifyEntries {
+		tree := merkletree.NewCompactRange(sha256Hash, 0)
+		if startIndex != 0 {
+			if checkpoint == nil || checkpoint.TreeHashes == nil {
+				return nil, fmt.Errorf("cannot verify entries when starting at index %d", startIndex)
+			}
+			var err error
+			tree, err = merkletree.NewCompactRangeWithHashes(sha256Hash, 0, uint64(startIndex), checkpoint.TreeHashes)
+			if err != nil {
+				return nil, fmt.Errorf("invalid checkpoint tree hashes: %v", err)
+			}
+		}
+		if s.logClient.Verifier == nil {
+			s.Log("No log public key provided, so STH signature has not been verified")
+		}
+		verifier = newEntryVerifier(s.logClient, *latestSth, tree, s.opts.VerifyCheckpointInterval, s.Log)
+	}
+	s.progress = newProgressTracker(startIndex, s.counts, verifier)
+	s.ordered = nil
+	if s.opts.OrderedDelivery {
+		window := s.opts.MaxBufferedEntries
+		if window <= 0 {
+			window = defaultMaxBufferedEntries
+		}
+		s.ordered = newOrderedDelivery(startIndex, window)
+	}
+	return latestSth, nil
+}
+
+// scanTo fetches and matches all of the entries from where the scan has got
+// to up to the tree size of sth, blocking until this is complete or ctx is
+// done.
+func (s *Scanner) scanTo(parentCtx context.Context, latestSth *ct.SignedTreeHead, foundCert func(*ct.LogEntry), foundPrecert func(*ct.LogEntry)) error {
+	ctx, cancel := context.WithCancel(parentCtx)
+	defer cancel()
+	scanErr := &scanError{cancel: cancel}
+	startIndex := s.progress.nextIndex()
+	verifier := s.progress.verifier
+	if verifier != nil {
+		verifier.setSTH(*latestSth)
+	}
 
-	ticker := time.NewTicker(time.Second)
 	startTime := time.Now()
+	initialProcessed := atomic.LoadInt64(&s.counts.CertsProcessed)
 	fetches := make(chan fetchRange, 1000)
 	jobs := make(chan matcherJob, 100000)
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	var checkpoints <-chan time.Time
+	if s.opts.Checkpointer != nil && s.opts.CheckpointPeriod > 0 {
+		checkpointTicker := time.NewTicker(s.opts.CheckpointPeriod)
+		defer checkpointTicker.Stop()
+		checkpoints = checkpointTicker.C
+	}
+	done := make(chan struct{})
 	go func() {
-		for range ticker.C {
-			certsProcessed := atomic.LoadInt64(&s.certsProcessed)
-			throughput := float64(certsProcessed) / time.Since(startTime).Seconds()
-			remainingCerts := int64(latestSth.TreeSize) - int64(s.opts.StartIndex) - certsProcessed
-			remainingSeconds := int(float64(remainingCerts) / throughput)
-			remainingString := humanTime(remainingSeconds)
-			s.Log(fmt.Sprintf("Processed: %d certs (to index %d). Throughput: %3.2f ETA: %s\n", certsProcessed,
-				s.opts.StartIndex+int64(certsProcessed), throughput, remainingString))
+		for {
+			select {
+			case <-ticker.C:
+				certsProcessed := atomic.LoadInt64(&s.counts.CertsProcessed) - initialProcessed
+				throughput := float64(certsProcessed) / time.Since(startTime).Seconds()
+				remainingCerts := int64(latestSth.TreeSize) - startIndex - certsProcessed
+				remainingSeconds := int(float64(remainingCerts) / throughput)
+				remainingString := humanTime(remainingSeconds)
+				s.Log(fmt.Sprintf("Processed: %d certs (to index %d). Throughput: %3.2f ETA: %s\n", certsProcessed,
+					startIndex+int64(certsProcessed), throughput, remainingString))
+			case <-checkpoints:
+				s.saveCheckpoint(*latestSth)
+			case <-done:
+				return
+			}
 		}
 	}()
 
 	var ranges list.List
-	for start := s.opts.StartIndex; start < int64(latestSth.TreeSize); {
+	for start := startIndex; start < int64(latestSth.TreeSize); {
 		end := min(start+int64(s.opts.BatchSize), int64(latestSth.TreeSize)) - 1
-		ranges.PushBack(fetchRange{start, end})
+		ranges.PushBack(fetchRange{start, end, s.progress.newRange(start, end)})
 		start = end + 1
 	}
 	var fetcherWG sync.WaitGroup
@@ -399,27 +841,57 @@ func (s *Scanner) Scan(foundCert func(*ct.LogEntry),
 	// Start matcher workers
 	for w := 0; w < s.opts.NumWorkers; w++ {
 		matcherWG.Add(1)
-		go s.matcherJob(w, jobs, foundCert, foundPrecert, &matcherWG)
+		go s.matcherJob(ctx, w, jobs, foundCert, foundPrecert, scanErr, &matcherWG)
 	}
 	// Start fetcher workers
 	for w := 0; w < s.opts.ParallelFetch; w++ {
 		fetcherWG.Add(1)
-		go s.fetcherJob(w, fetches, jobs, &fetcherWG)
+		go s.fetcherJob(ctx, w, fetches, jobs, scanErr, &fetcherWG)
 	}
+feed:
 	for r := ranges.Front(); r != nil; r = r.Next() {
-		fetches <- r.Value.(fetchRange)
+		select {
+		case fetches <- r.Value.(fetchRange):
+		case <-ctx.Done():
+			break feed
+		}
 	}
 	close(fetches)
 	fetcherWG.Wait()
 	close(jobs)
 	matcherWG.Wait()
+	close(done)
+	if s.opts.Checkpointer != nil {
+		s.saveCheckpoint(*latestSth)
+	}
 
-	s.Log(fmt.Sprintf("Completed %d certs in %s", atomic.LoadInt64(&s.certsProcessed), humanTime(int(time.Since(startTime).Seconds()))))
-	s.Log(fmt.Sprintf("Saw %d precerts", atomic.LoadInt64(&s.precertsSeen)))
-	s.Log(fmt.Sprintf("%d unparsable entries, %d non-fatal errors", atomic.LoadInt64(&s.unparsableEntries), atomic.LoadInt64(&s.entriesWithNonFatalErrors)))
+	s.Log(fmt.Sprintf("Completed %d certs in %s", atomic.LoadInt64(&s.counts.CertsProcessed)-initialProcessed, humanTime(int(time.Since(startTime).Seconds()))))
+	s.Log(fmt.Sprintf("Saw %d precerts", atomic.LoadInt64(&s.counts.PrecertsSeen)))
+	s.Log(fmt.Sprintf("%d unparsable entries, %d non-fatal errors", atomic.LoadInt64(&s.counts.UnparsableEntries), atomic.LoadInt64(&s.counts.EntriesWithNonFatalErrors)))
+	if scanErr.err != nil {
+		s.Log(fmt.Sprintf("Scan failed: %v", scanErr.err))
+		return scanErr.err
+	}
+	if err := parentCtx.Err(); err != nil {
+		return err
+	}
+	if verifier != nil {
+		if err := verifier.finish(); err != nil {
+			s.Log(fmt.Sprintf("Entry verification failed: %v", err))
+			return err
+		}
+	}
 	return nil
 }
 
+// saveCheckpoint records the scan's current progress against sth.
+func (s *Scanner) saveCheckpoint(sth ct.SignedTreeHead) {
+	cp := s.progress.checkpoint(sth)
+	if err := s.opts.Checkpointer.SaveCheckpoint(cp); err != nil {
+		s.Log(fmt.Sprintf("Failed to save checkpoint at index %d: %v", cp.NextIndex, err))
+	}
+}
+
 // NewScanner creates a new Scanner instance using client to talk to the log,
 // taking configuration options from opts.
 func NewScanner(client *client.LogClient, opts ScannerOptions) *Scanner {
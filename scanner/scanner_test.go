@@ -16,16 +16,31 @@ package scanner
 
 import (
 	"container/list"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
 	"regexp"
+	"sort"
+	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	ct "github.com/google/certificate-transparency-go"
 	"github.com/google/certificate-transparency-go/client"
 	"github.com/google/certificate-transparency-go/jsonclient"
+	"github.com/google/certificate-transparency-go/merkletree"
+	"github.com/google/certificate-transparency-go/tls"
 	"github.com/google/certificate-transparency-go/x509"
+	"github.com/kylelemons/godebug/pretty"
+	"golang.org/x/net/context"
 )
 
 func CertMatchesRegex(r *regexp.Regexp, cert *x509.Certificate) bool {
@@ -257,3 +272,540 @@ func TestDefaultScannerOptions(t *testing.T) {
 		t.Fatal("Expected Quiet to be false.")
 	}
 }
+
+// newVerifiableLogServer returns a test server which serves the entries held
+// in FourEntries, along with an STH and consistency proofs which match them
+// (unless rootOverride is set, in which case that is served as the STH root).
+func newVerifiableLogServer(t *testing.T, rootOverride []byte) *httptest.Server {
+	return newGrowingLogServer(t, rootOverride, nil)
+}
+
+// newGrowingLogServer is like newVerifiableLogServer, but successive calls to
+// get-sth return STHs for the tree sizes in sthSizes (repeating the last one
+// once they run out), to simulate a log which grows over time.  If sthSizes
+// is empty, all STHs cover the whole tree.
+func newGrowingLogServer(t *testing.T, rootOverride []byte, sthSizes []uint64) *httptest.Server {
+	var entries ct.GetEntriesResponse
+	if err := json.Unmarshal([]byte(FourEntries), &entries); err != nil {
+		t.Fatalf("Failed to parse test entries: %v", err)
+	}
+	tree := merkletree.NewInMemoryMerkleTree(sha256Hash)
+	for _, entry := range entries.Entries {
+		tree.AddLeaf(entry.LeafInput)
+	}
+	if len(sthSizes) == 0 {
+		sthSizes = []uint64{tree.LeafCount()}
+	}
+	sigBytes, err := base64.StdEncoding.DecodeString("AAAACXNpZ25hdHVyZQ==")
+	if err != nil {
+		t.Fatalf("Failed to decode signature: %v", err)
+	}
+	var mu sync.Mutex
+	sthCalls := 0
+	getSTH := func() (*ct.GetSTHResponse, error) {
+		mu.Lock()
+		size := sthSizes[len(sthSizes)-1]
+		if sthCalls < len(sthSizes) {
+			size = sthSizes[sthCalls]
+		}
+		sthCalls++
+		mu.Unlock()
+		root, err := tree.RootAtSnapshot(size)
+		if err != nil {
+			return nil, err
+		}
+		if rootOverride != nil {
+			root = rootOverride
+		}
+		return &ct.GetSTHResponse{
+			TreeSize:          size,
+			Timestamp:         1396877652123,
+			SHA256RootHash:    root,
+			TreeHeadSignature: sigBytes,
+		}, nil
+	}
+
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rsp interface{}
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			sth, err := getSTH()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			rsp = sth
+		case "/ct/v1/get-entries":
+			start, _ := strconv.Atoi(r.FormValue("start"))
+			end, _ := strconv.Atoi(r.FormValue("end"))
+			rsp = ct.GetEntriesResponse{Entries: entries.Entries[start : end+1]}
+		case "/ct/v1/get-sth-consistency":
+			first, _ := strconv.ParseUint(r.FormValue("first"), 10, 64)
+			second, _ := strconv.ParseUint(r.FormValue("second"), 10, 64)
+			proof, err := tree.SnapshotConsistency(first, second)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			rsp = ct.GetSTHConsistencyResponse{Consistency: proof}
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewEncoder(w).Encode(rsp); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	}))
+}
+
+func TestScannerVerifyEntries(t *testing.T) {
+	tests := []struct {
+		desc               string
+		rootOverride       []byte
+		batchSize          int
+		checkpointInterval int64
+		wantInconsistent   bool
+	}{
+		{desc: "single batch", batchSize: 10},
+		{desc: "multiple batches", batchSize: 1},
+		{desc: "with checkpoints", batchSize: 1, checkpointInterval: 1},
+		{desc: "wrong root", rootOverride: make([]byte, sha256.Size), batchSize: 10, wantInconsistent: true},
+		{desc: "wrong root at checkpoint", rootOverride: make([]byte, sha256.Size), batchSize: 1, checkpointInterval: 2, wantInconsistent: true},
+	}
+	for _, test := range tests {
+		ts := newVerifiableLogServer(t, test.rootOverride)
+		defer ts.Close()
+		logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		scanner := NewScanner(logClient, ScannerOptions{
+			Matcher:                  &MatchAll{},
+			BatchSize:                test.batchSize,
+			NumWorkers:               2,
+			ParallelFetch:            3,
+			Quiet:                    true,
+			VerifyEntries:            true,
+			VerifyCheckpointInterval: test.checkpointInterval,
+		})
+
+		var mu sync.Mutex
+		count := 0
+		found := func(*ct.LogEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			count++
+		}
+		err = scanner.Scan(found, found)
+		if test.wantInconsistent {
+			inconsistent, ok := err.(InconsistentEntriesError)
+			if !ok {
+				t.Errorf("%s: Scan()=%v; want InconsistentEntriesError", test.desc, err)
+				continue
+			}
+			if test.checkpointInterval > 0 && inconsistent.TreeSize >= inconsistent.STH.TreeSize {
+				t.Errorf("%s: Scan() found inconsistency at size %d; want at a checkpoint", test.desc, inconsistent.TreeSize)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: Scan()=%v; want nil", test.desc, err)
+		}
+		if count != 4 {
+			t.Errorf("%s: Scan() found %d entries; want 4", test.desc, count)
+		}
+	}
+}
+
+func TestScannerVerifyEntriesRequiresStartAtZero(t *testing.T) {
+	ts := newVerifiableLogServer(t, nil)
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(logClient, ScannerOptions{
+		BatchSize:     10,
+		NumWorkers:    1,
+		ParallelFetch: 1,
+		StartIndex:    1,
+		Quiet:         true,
+		VerifyEntries: true,
+	})
+	if err := scanner.Scan(func(*ct.LogEntry) {}, func(*ct.LogEntry) {}); err == nil {
+		t.Error("Scan() with StartIndex=1 succeeded; want error")
+	}
+}
+
+func TestFileCheckpointer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "checkpoint_test")
+	if err != nil {
+		t.Fatalf("Failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	checkpointer := NewFileCheckpointer(filepath.Join(dir, "checkpoint.json"))
+
+	if cp, err := checkpointer.LoadCheckpoint(); cp != nil || err != nil {
+		t.Errorf("LoadCheckpoint() with no file=%v,%v; want nil,nil", cp, err)
+	}
+	want := &Checkpoint{
+		NextIndex:  1234,
+		STH:        ct.SignedTreeHead{TreeSize: 5678, Timestamp: 1396877652123},
+		Counts:     ScanCounts{CertsProcessed: 1234, PrecertsSeen: 12, UnparsableEntries: 1},
+		TreeHashes: [][]byte{make([]byte, sha256.Size), make([]byte, sha256.Size)},
+	}
+	for i := 0; i < 2; i++ {
+		want.NextIndex += int64(i)
+		if err := checkpointer.SaveCheckpoint(want); err != nil {
+			t.Fatalf("SaveCheckpoint()=%v", err)
+		}
+		got, err := checkpointer.LoadCheckpoint()
+		if err != nil {
+			t.Fatalf("LoadCheckpoint()=nil,%v", err)
+		}
+		if diff := pretty.Compare(got, want); diff != "" {
+			t.Errorf("LoadCheckpoint() diff (-got +want):\n%s", diff)
+		}
+	}
+}
+
+type memCheckpointer struct {
+	cp *Checkpoint
+}
+
+func (m *memCheckpointer) LoadCheckpoint() (*Checkpoint, error) {
+	return m.cp, nil
+}
+
+func (m *memCheckpointer) SaveCheckpoint(cp *Checkpoint) error {
+	m.cp = cp
+	return nil
+}
+
+func TestScannerResumesFromCheckpoint(t *testing.T) {
+	ts := newVerifiableLogServer(t, nil)
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sth, err := logClient.GetSTH(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries ct.GetEntriesResponse
+	if err := json.Unmarshal([]byte(FourEntries), &entries); err != nil {
+		t.Fatal(err)
+	}
+	tree := merkletree.NewCompactRange(sha256Hash, 0)
+	for _, entry := range entries.Entries[:2] {
+		tree.AppendLeaf(entry.LeafInput)
+	}
+
+	// Pretend that an earlier scan got through the first two entries.
+	checkpointer := &memCheckpointer{cp: &Checkpoint{
+		NextIndex:  2,
+		STH:        *sth,
+		Counts:     ScanCounts{CertsProcessed: 2},
+		TreeHashes: tree.Hashes(),
+	}}
+	scanner := NewScanner(logClient, ScannerOptions{
+		BatchSize:     1,
+		NumWorkers:    2,
+		ParallelFetch: 2,
+		Quiet:         true,
+		VerifyEntries: true,
+		Checkpointer:  checkpointer,
+	})
+	var mu sync.Mutex
+	var indices []int64
+	found := func(e *ct.LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		indices = append(indices, e.Index)
+	}
+	if err := scanner.Scan(found, found); err != nil {
+		t.Fatalf("Scan()=%v", err)
+	}
+	if len(indices) != 2 {
+		t.Errorf("Scan() found entries %v; want only indices 2 and 3", indices)
+	}
+	for _, index := range indices {
+		if index < 2 {
+			t.Errorf("Scan() found entry at index %d; want only indices >= 2", index)
+		}
+	}
+	cp := checkpointer.cp
+	if got, want := cp.NextIndex, int64(4); got != want {
+		t.Errorf("Checkpoint.NextIndex=%d; want %d", got, want)
+	}
+	if got, want := cp.Counts.CertsProcessed, int64(4); got != want {
+		t.Errorf("Checkpoint.Counts.CertsProcessed=%d; want %d", got, want)
+	}
+	if got, want := len(cp.TreeHashes), 1; got != want {
+		t.Errorf("len(Checkpoint.TreeHashes)=%d; want %d", got, want)
+	}
+}
+
+func TestScannerFollow(t *testing.T) {
+	// The STH is fetched once when Follow starts, then once per poll.
+	ts := newGrowingLogServer(t, nil, []uint64{1, 1, 2, 4})
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(logClient, ScannerOptions{
+		Matcher:       &MatchAll{},
+		BatchSize:     10,
+		NumWorkers:    1,
+		ParallelFetch: 1,
+		Quiet:         true,
+		VerifyEntries: true,
+		PollInterval:  time.Millisecond,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	var indices []int64
+	found := func(e *ct.LogEntry) {
+		mu.Lock()
+		defer mu.Unlock()
+		indices = append(indices, e.Index)
+		if len(indices) == 4 {
+			cancel()
+		}
+	}
+	if err := scanner.Follow(ctx, found, found); err != context.Canceled {
+		t.Fatalf("Follow()=%v; want %v", err, context.Canceled)
+	}
+	sort.Slice(indices, func(i, j int) bool { return indices[i] < indices[j] })
+	if diff := pretty.Compare(indices, []int64{0, 1, 2, 3}); diff != "" {
+		t.Errorf("Follow() found entries diff (-got +want):\n%s", diff)
+	}
+}
+
+func TestScannerFollowDetectsInconsistentSTH(t *testing.T) {
+	// The log claims to shrink after the first poll.
+	ts := newGrowingLogServer(t, nil, []uint64{4, 3})
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(logClient, ScannerOptions{
+		Matcher:       &MatchAll{},
+		BatchSize:     10,
+		NumWorkers:    1,
+		ParallelFetch: 1,
+		Quiet:         true,
+		PollInterval:  time.Millisecond,
+	})
+	found := func(e *ct.LogEntry) {}
+	err = scanner.Follow(context.Background(), found, found)
+	if _, ok := err.(STHConsistencyError); !ok {
+		t.Fatalf("Follow()=%v; want STHConsistencyError", err)
+	}
+}
+
+// scanFailingLog scans a log which answers every get-entries request with
+// the given HTTP status, returning the number of get-entries requests made
+// and the scan's error.
+func scanFailingLog(t *testing.T, status int) (int, error) {
+	var mu sync.Mutex
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			w.Write([]byte(FourEntrySTH))
+		case "/ct/v1/get-entries":
+			mu.Lock()
+			fetches++
+			mu.Unlock()
+			http.Error(w, http.StatusText(status), status)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	scanner := NewScanner(logClient, ScannerOptions{
+		Matcher:       &MatchAll{},
+		BatchSize:     10,
+		NumWorkers:    1,
+		ParallelFetch: 1,
+		Quiet:         true,
+		MaxRetries:    2,
+		RetryBackoff:  time.Millisecond,
+	})
+	found := func(e *ct.LogEntry) {}
+	err = scanner.ScanWithContext(context.Background(), found, found)
+	mu.Lock()
+	defer mu.Unlock()
+	return fetches, err
+}
+
+func TestScannerGivesUpAfterRetries(t *testing.T) {
+	for _, status := range []int{http.StatusInternalServerError, http.StatusTooManyRequests} {
+		fetches, err := scanFailingLog(t, status)
+		if err == nil {
+			t.Errorf("ScanWithContext() against log returning %d=nil; want error", status)
+		}
+		if got, want := fetches, 3; got != want {
+			t.Errorf("ScanWithContext() against log returning %d made %d get-entries requests; want %d", status, got, want)
+		}
+	}
+}
+
+func TestScannerFailsWithoutRetryingRejectedRequests(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound} {
+		fetches, err := scanFailingLog(t, status)
+		if err == nil {
+			t.Errorf("ScanWithContext() against log returning %d=nil; want error", status)
+		}
+		if got, want := fetches, 1; got != want {
+			t.Errorf("ScanWithContext() against log returning %d made %d get-entries requests; want %d", status, got, want)
+		}
+	}
+}
+
+func TestScanWithContextCancel(t *testing.T) {
+	ts := newVerifiableLogServer(t, nil)
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpointer := &memCheckpointer{}
+	scanner := NewScanner(logClient, ScannerOptions{
+		Matcher:       &MatchAll{},
+		BatchSize:     1,
+		NumWorkers:    1,
+		ParallelFetch: 1,
+		Quiet:         true,
+		Checkpointer:  checkpointer,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var indices []int64
+	found := func(e *ct.LogEntry) {
+		// Only one matcher, so no locking needed.
+		indices = append(indices, e.Index)
+		cancel()
+	}
+	if err := scanner.ScanWithContext(ctx, found, found); err != context.Canceled {
+		t.Fatalf("ScanWithContext()=%v; want %v", err, context.Canceled)
+	}
+	if diff := pretty.Compare(indices, []int64{0}); diff != "" {
+		t.Errorf("ScanWithContext() found entries diff (-got +want):\n%s", diff)
+	}
+	if got, want := checkpointer.cp.NextIndex, int64(1); got != want {
+		t.Errorf("Checkpoint.NextIndex=%d; want %d", got, want)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	s := NewScanner(nil, ScannerOptions{RetryBackoff: time.Second, MaxRetryBackoff: 5 * time.Second})
+	for _, test := range []struct {
+		failures int
+		want     time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 5 * time.Second},
+		{100, 5 * time.Second},
+	} {
+		if got := s.retryBackoff(test.failures); got != test.want {
+			t.Errorf("retryBackoff(%d)=%s; want %s", test.failures, got, test.want)
+		}
+	}
+}
+
+func TestScannerFoundUnparsable(t *testing.T) {
+	var entries ct.GetEntriesResponse
+	if err := json.Unmarshal([]byte(FourEntries), &entries); err != nil {
+		t.Fatal(err)
+	}
+	// Replace entry 1 with one that isn't a MerkleTreeLeaf at all, and entry
+	// 2 with one holding an invalid certificate.
+	entries.Entries[1].LeafInput = []byte("bogus")
+	badCertLeaf, err := tls.Marshal(ct.MerkleTreeLeaf{
+		Version:  ct.V1,
+		LeafType: ct.TimestampedEntryLeafType,
+		TimestampedEntry: &ct.TimestampedEntry{
+			EntryType: ct.X509LogEntryType,
+			X509Entry: &ct.ASN1Cert{Data: []byte("not a certificate")},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries.Entries[2].LeafInput = badCertLeaf
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ct/v1/get-sth":
+			w.Write([]byte(FourEntrySTH))
+		case "/ct/v1/get-entries":
+			start, _ := strconv.Atoi(r.FormValue("start"))
+			end, _ := strconv.Atoi(r.FormValue("end"))
+			json.NewEncoder(w).Encode(ct.GetEntriesResponse{Entries: entries.Entries[start : end+1]})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+	logClient, err := client.New(ts.URL, &http.Client{}, jsonclient.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, ordered := range []bool{false, true} {
+		var mu sync.Mutex
+		var found, unparsable []int64
+		scanner := NewScanner(logClient, ScannerOptions{
+			Matcher:         &MatchAll{},
+			BatchSize:       1,
+			NumWorkers:      2,
+			ParallelFetch:   2,
+			Quiet:           true,
+			OrderedDelivery: ordered,
+			FoundUnparsable: func(u *UnparsableEntry) {
+				mu.Lock()
+				defer mu.Unlock()
+				unparsable = append(unparsable, u.Index)
+				if got, want := u.Leaf, entries.Entries[u.Index]; !reflect.DeepEqual(got, want) {
+					t.Errorf("UnparsableEntry(%d).Leaf=%+v; want %+v", u.Index, got, want)
+				}
+				if u.Err == nil || u.NonFatal() {
+					t.Errorf("UnparsableEntry(%d).Err=%v; want fatal error", u.Index, u.Err)
+				}
+				if got, want := u.Entry != nil, u.Index == 2; got != want {
+					t.Errorf("UnparsableEntry(%d).Entry=%v; want present=%v", u.Index, u.Entry, want)
+				}
+			},
+		})
+		foundEntry := func(e *ct.LogEntry) {
+			mu.Lock()
+			defer mu.Unlock()
+			found = append(found, e.Index)
+		}
+		if err := scanner.Scan(foundEntry, foundEntry); err != nil {
+			t.Fatalf("Scan()=%v", err)
+		}
+		sort.Slice(found, func(i, j int) bool { return found[i] < found[j] })
+		sort.Slice(unparsable, func(i, j int) bool { return unparsable[i] < unparsable[j] })
+		if diff := pretty.Compare(found, []int64{0, 3}); diff != "" {
+			t.Errorf("ordered=%v: Scan() found entries diff (-got +want):\n%s", ordered, diff)
+		}
+		if diff := pretty.Compare(unparsable, []int64{1, 2}); diff != "" {
+			t.Errorf("ordered=%v: Scan() found unparsable entries diff (-got +want):\n%s", ordered, diff)
+		}
+		if got, want := scanner.counts.UnparsableEntries, int64(2); got != want {
+			t.Errorf("ordered=%v: UnparsableEntries=%d; want %d", ordered, got, want)
+		}
+	}
+}
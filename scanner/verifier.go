@@ -0,0 +1,198 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+
+	ct "github.com/google/certificate-transparency-go"
+	"github.com/google/certificate-transparency-go/client"
+	"github.com/google/certificate-transparency-go/merkletree"
+	"golang.org/x/net/context"
+)
+
+// InconsistentEntriesError indicates that the entries returned by a log do not
+// match the tree committed to by the log's STH.
+type InconsistentEntriesError struct {
+	// TreeSize is the number of entries (from the start of the log) which
+	// had been verified when the inconsistency was found.
+	TreeSize uint64
+	// CalculatedRoot is the tree root calculated from those entries.
+	CalculatedRoot []byte
+	// STH is the tree head that the entries failed to match.
+	STH ct.SignedTreeHead
+	// Err holds the underlying verification failure.
+	Err error
+}
+
+func (e InconsistentEntriesError) Error() string {
+	return fmt.Sprintf("log entries [0, %d) inconsistent with STH for tree size %d: %v", e.TreeSize, e.STH.TreeSize, e.Err)
+}
+
+func sha256Hash(b []byte) []byte {
+	h := sha256.Sum256(b)
+	return h[:]
+}
+
+// entryVerifier accumulates the leaf hashes of processed entries, in order,
+// and checks the resulting tree against an STH.
+type entryVerifier struct {
+	logClient *client.LogClient
+	sth       ct.SignedTreeHead
+	verifier  merkletree.MerkleVerifier
+	// Number of entries between intermediate consistency checks, or zero
+	// for none.
+	checkpointInterval int64
+	log                func(msg string)
+
+	mu sync.Mutex
+	// tree covers all entries verified so far, from the start of the log.
+	tree           *merkletree.CompactRange
+	lastCheckpoint uint64
+	err            error
+}
+
+func newEntryVerifier(logClient *client.LogClient, sth ct.SignedTreeHead, tree *merkletree.CompactRange, checkpointInterval int64, log func(msg string)) *entryVerifier {
+	return &entryVerifier{
+		logClient:          logClient,
+		sth:                sth,
+		verifier:           merkletree.NewMerkleVerifier(sha256Hash),
+		checkpointInterval: checkpointInterval,
+		log:                log,
+		tree:               tree,
+		lastCheckpoint:     tree.End(),
+	}
+}
+
+// newRange returns an empty range for leaves starting at index begin, to be
+// passed to addRange once filled.
+func (v *entryVerifier) newRange(begin int64) *merkletree.CompactRange {
+	return merkletree.NewCompactRange(sha256Hash, uint64(begin))
+}
+
+// consistencyCheck describes a prefix of the tree, to be checked against
+// an STH with a consistency proof from the log.
+type consistencyCheck struct {
+	size uint64
+	root []byte
+	sth  ct.SignedTreeHead
+}
+
+// addRange incorporates a range of leaf hashes, which must follow on from
+// those already added, into the verified tree.  If a checkpoint has been
+// reached, it returns the check to pass to checkConsistency; the check is
+// not made here, so that the caller need not hold any locks during the
+// round trip to the log.
+func (v *entryVerifier) addRange(r *merkletree.CompactRange) (*consistencyCheck, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if err := v.tree.AppendRange(r); err != nil {
+		// Can't happen, as ranges are added in order.
+		panic(err)
+	}
+
+	if v.err != nil || v.checkpointInterval <= 0 {
+		return nil, nil
+	}
+	size := v.tree.End()
+	if size >= v.sth.TreeSize || size-v.lastCheckpoint < uint64(v.checkpointInterval) {
+		return nil, nil
+	}
+	v.lastCheckpoint = size
+	root, err := v.tree.Root()
+	if err != nil {
+		v.err = err
+		return nil, err
+	}
+	return &consistencyCheck{size: size, root: root, sth: v.sth}, nil
+}
+
+// checkConsistency verifies that the prefix of the tree described by c is a
+// prefix of the tree in c's STH, using a consistency proof from the log.  Any
+// inconsistency found is returned, and also reported again by finish.  Must
+// be called without mu held.
+func (v *entryVerifier) checkConsistency(ctx context.Context, c *consistencyCheck) error {
+	err := v.verifyConsistency(ctx, c)
+	if err != nil {
+		v.mu.Lock()
+		if v.err == nil {
+			v.err = err
+		}
+		v.mu.Unlock()
+	}
+	return err
+}
+
+func (v *entryVerifier) verifyConsistency(ctx context.Context, c *consistencyCheck) error {
+	proof, err := v.logClient.GetSTHConsistency(ctx, c.size, c.sth.TreeSize)
+	if err != nil {
+		// Not evidence of misbehaviour; a later check may succeed.
+		v.log(fmt.Sprintf("Failed to get consistency proof %d->%d, skipping checkpoint: %v", c.size, c.sth.TreeSize, err))
+		return nil
+	}
+	if err := v.verifier.VerifyConsistencyProof(int64(c.size), int64(c.sth.TreeSize), c.root, c.sth.SHA256RootHash[:], proof); err != nil {
+		return InconsistentEntriesError{TreeSize: c.size, CalculatedRoot: c.root, STH: c.sth, Err: err}
+	}
+	v.log(fmt.Sprintf("Verified entries [0, %d) against STH for tree size %d", c.size, c.sth.TreeSize))
+	return nil
+}
+
+// setSTH changes the STH which the tree is checked against.
+func (v *entryVerifier) setSTH(sth ct.SignedTreeHead) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.sth = sth
+}
+
+// finish checks the complete tree against the STH root, returning any
+// inconsistency found either now or at an earlier checkpoint.
+func (v *entryVerifier) finish() error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.err != nil {
+		return v.err
+	}
+	if size := v.tree.End(); size != v.sth.TreeSize {
+		return fmt.Errorf("only verified entries [0, %d) of %d", size, v.sth.TreeSize)
+	}
+	root, err := v.tree.Root()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(root, v.sth.SHA256RootHash[:]) {
+		return InconsistentEntriesError{
+			TreeSize:       v.sth.TreeSize,
+			CalculatedRoot: root,
+			STH:            v.sth,
+			Err: merkletree.RootMismatchError{
+				ExpectedRoot:   v.sth.SHA256RootHash[:],
+				CalculatedRoot: root,
+			},
+		}
+	}
+	v.log(fmt.Sprintf("Verified all %d entries against STH root", v.sth.TreeSize))
+	return nil
+}
+
+// treeHashes returns a copy of the subtree hashes covering the entries
+// verified so far.
+func (v *entryVerifier) treeHashes() [][]byte {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	return append([][]byte(nil), v.tree.Hashes()...)
+}
@@ -16,11 +16,14 @@ package ct
 
 import (
 	"crypto"
+	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"strings"
 
+	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
 )
 
 // SerializeSCTSignatureInput serializes the passed in sct and log entry into
@@ -76,6 +79,48 @@ func SerializeSTHSignatureInput(sth SignedTreeHead) ([]byte, error) {
 	}
 }
 
+// LogEntryFromLeaf converts a LeafEntry object (which has the raw leaf data
+// after JSON parsing) into a LogEntry object (which includes the chain
+// parsed from the TLS-encoded extra data).
+func LogEntryFromLeaf(index int64, leafEntry *LeafEntry) (*LogEntry, error) {
+	var leaf MerkleTreeLeaf
+	if rest, err := tls.Unmarshal(leafEntry.LeafInput, &leaf); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal MerkleTreeLeaf for index %d: %v", index, err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data (%d bytes) after MerkleTreeLeaf for index %d", len(rest), index)
+	}
+
+	var chain []ASN1Cert
+	switch leaf.TimestampedEntry.EntryType {
+	case X509LogEntryType:
+		var certChain CertificateChain
+		if rest, err := tls.Unmarshal(leafEntry.ExtraData, &certChain); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal ExtraData for index %d: %v", index, err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("trailing data (%d bytes) after CertificateChain for index %d", len(rest), index)
+		}
+		chain = certChain.Entries
+
+	case PrecertLogEntryType:
+		var precertChain PrecertChainEntry
+		if rest, err := tls.Unmarshal(leafEntry.ExtraData, &precertChain); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal PrecertChainEntry for index %d: %v", index, err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("trailing data (%d bytes) after PrecertChainEntry for index %d", len(rest), index)
+		}
+		chain = append(chain, precertChain.PreCertificate)
+		chain = append(chain, precertChain.CertificateChain...)
+
+	default:
+		return nil, fmt.Errorf("saw unknown entry type at index %d: %v", index, leaf.TimestampedEntry.EntryType)
+	}
+	return &LogEntry{
+		Index: index,
+		Leaf:  leaf,
+		Chain: chain,
+	}, nil
+}
+
 // CreateX509MerkleTreeLeaf generates a MerkleTreeLeaf for an X509 cert
 func CreateX509MerkleTreeLeaf(cert ASN1Cert, timestamp uint64) *MerkleTreeLeaf {
 	return &MerkleTreeLeaf{
@@ -89,6 +134,172 @@ func CreateX509MerkleTreeLeaf(cert ASN1Cert, timestamp uint64) *MerkleTreeLeaf {
 	}
 }
 
+// X509SCTList converts SCTs into the x509 package's form of an RFC 6962 s3.3
+// SignedCertificateTimestampList, e.g. for embedding in a certificate template.
+func X509SCTList(scts []SignedCertificateTimestamp) (*x509.SignedCertificateTimestampList, error) {
+	var list x509.SignedCertificateTimestampList
+	for i, sct := range scts {
+		data, err := tls.Marshal(sct)
+		if err != nil {
+			return nil, fmt.Errorf("failed to tls-encode SCT %d: %v", i, err)
+		}
+		list.SCTList = append(list.SCTList, x509.SerializedSCT{Val: data})
+	}
+	return &list, nil
+}
+
+// SCTsFromX509List parses the SCTs held in the x509 package's form of a
+// SignedCertificateTimestampList, such as the embedded SCTs of a certificate.
+func SCTsFromX509List(list *x509.SignedCertificateTimestampList) ([]SignedCertificateTimestamp, error) {
+	scts := make([]SignedCertificateTimestamp, 0, len(list.SCTList))
+	for i, serialized := range list.SCTList {
+		var sct SignedCertificateTimestamp
+		if rest, err := tls.Unmarshal(serialized.Val, &sct); err != nil {
+			return nil, fmt.Errorf("failed to parse SCT %d: %v", i, err)
+		} else if len(rest) > 0 {
+			return nil, fmt.Errorf("trailing data (%d bytes) after SCT %d", len(rest), i)
+		}
+		scts = append(scts, sct)
+	}
+	return scts, nil
+}
+
+// SerializeSCTList TLS-encodes SCTs as a SignedCertificateTimestampList, in
+// the form carried by the certificate, OCSP and TLS SCT extensions (RFC 6962
+// s3.3).
+func SerializeSCTList(scts []SignedCertificateTimestamp) ([]byte, error) {
+	list, err := X509SCTList(scts)
+	if err != nil {
+		return nil, err
+	}
+	return tls.Marshal(*list)
+}
+
+// DeserializeSCTList parses a TLS-encoded SignedCertificateTimestampList, as
+// produced by SerializeSCTList.
+func DeserializeSCTList(data []byte) ([]SignedCertificateTimestamp, error) {
+	var list x509.SignedCertificateTimestampList
+	if rest, err := tls.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("failed to parse SignedCertificateTimestampList: %v", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data (%d bytes) after SignedCertificateTimestampList", len(rest))
+	}
+	return SCTsFromX509List(&list)
+}
+
+// OIDExtKeyUsagePrecertificateSigning is the extended key usage marking a
+// Precertificate Signing Certificate (RFC 6962 s3.1).
+var OIDExtKeyUsagePrecertificateSigning = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 4}
+
+// MerkleTreeLeafFromRawChain generates a MerkleTreeLeaf from a DER-encoded
+// chain and a timestamp (normally that of the corresponding SCT).  For a
+// precertificate, the chain must include the issuer after the leaf (and the
+// issuer's issuer, if that is a Precertificate Signing Certificate).
+func MerkleTreeLeafFromRawChain(rawChain []ASN1Cert, etype LogEntryType, timestamp uint64) (*MerkleTreeLeaf, error) {
+	if len(rawChain) == 0 {
+		return nil, fmt.Errorf("no certificates in chain")
+	}
+	if etype == X509LogEntryType {
+		return CreateX509MerkleTreeLeaf(rawChain[0], timestamp), nil
+	}
+	// Only the certificates that are needed are parsed: the leaf, its issuer
+	// and, if the issuer is a Precertificate Signing Certificate, the CA
+	// that issued that.
+	chain := make([]*x509.Certificate, 0, 3)
+	for i := 0; i < len(rawChain) && i < 3; i++ {
+		if i == 2 && !isPrecertSigningCert(chain[1]) {
+			break
+		}
+		cert, err := x509.ParseCertificate(rawChain[i].Data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse chain[%d] certificate: %v", i, err)
+		}
+		chain = append(chain, cert)
+	}
+	return MerkleTreeLeafFromChain(chain, etype, timestamp)
+}
+
+// MerkleTreeLeafFromChain generates a MerkleTreeLeaf from a parsed chain and a
+// timestamp (normally that of the corresponding SCT).  For a precertificate,
+// the chain must include the issuer after the leaf; if the issuer is a
+// Precertificate Signing Certificate, the chain must also include the CA that
+// issued it.
+func MerkleTreeLeafFromChain(chain []*x509.Certificate, etype LogEntryType, timestamp uint64) (*MerkleTreeLeaf, error) {
+	if len(chain) == 0 {
+		return nil, fmt.Errorf("no certificates in chain")
+	}
+	switch etype {
+	case X509LogEntryType:
+		return CreateX509MerkleTreeLeaf(ASN1Cert{Data: chain[0].Raw}, timestamp), nil
+	case PrecertLogEntryType:
+	default:
+		return nil, fmt.Errorf("unsupported entry type %v", etype)
+	}
+
+	// Pre-certs are more complicated; we need the hash of the public key
+	// (not of the whole certificate) of the CA that will issue the final
+	// certificate, and the DER-encoded TBSCertificate with the CT poison
+	// extension removed, as described in RFC 6962 s3.2.
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("no issuer cert available for precert leaf construction")
+	}
+	issuer := chain[1]
+	var preIssuer *x509.Certificate
+	if isPrecertSigningCert(issuer) {
+		if len(chain) < 3 {
+			return nil, fmt.Errorf("no issuer cert available for Precertificate Signing Certificate")
+		}
+		preIssuer, issuer = issuer, chain[2]
+	}
+	defangedTBS, err := x509.BuildPrecertTBS(chain[0].RawTBSCertificate, preIssuer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build precert TBSCertificate: %v", err)
+	}
+	return precertMerkleTreeLeaf(defangedTBS, issuer, timestamp), nil
+}
+
+// MerkleTreeLeafForEmbeddedSCT generates the MerkleTreeLeaf that an SCT
+// embedded in a final certificate was issued for, from a parsed chain and the
+// SCT's timestamp.  The chain must include the final certificate's issuer
+// after it.  The TBSCertificate of the leaf is that of the final certificate
+// with its SCT list extension removed; this already names the final issuer,
+// as any issuer changes made for a Precertificate Signing Certificate were
+// made by the log (RFC 6962 s3.2).
+func MerkleTreeLeafForEmbeddedSCT(chain []*x509.Certificate, timestamp uint64) (*MerkleTreeLeaf, error) {
+	if len(chain) < 2 {
+		return nil, fmt.Errorf("no issuer cert available for embedded SCT leaf construction")
+	}
+	tbs, err := x509.RemoveSCTList(chain[0].RawTBSCertificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to remove SCT list extension: %v", err)
+	}
+	return precertMerkleTreeLeaf(tbs, chain[1], timestamp), nil
+}
+
+func precertMerkleTreeLeaf(tbs []byte, issuer *x509.Certificate, timestamp uint64) *MerkleTreeLeaf {
+	return &MerkleTreeLeaf{
+		Version:  V1,
+		LeafType: TimestampedEntryLeafType,
+		TimestampedEntry: &TimestampedEntry{
+			Timestamp: timestamp,
+			EntryType: PrecertLogEntryType,
+			PrecertEntry: &PreCert{
+				IssuerKeyHash:  sha256.Sum256(issuer.RawSubjectPublicKeyInfo),
+				TBSCertificate: tbs,
+			},
+		},
+	}
+}
+
+func isPrecertSigningCert(cert *x509.Certificate) bool {
+	for _, eku := range cert.UnknownExtKeyUsage {
+		if eku.Equal(OIDExtKeyUsagePrecertificateSigning) {
+			return true
+		}
+	}
+	return false
+}
+
 // CreateJSONMerkleTreeLeaf creates the merkle tree leaf for json data.
 func CreateJSONMerkleTreeLeaf(data interface{}, timestamp uint64) *MerkleTreeLeaf {
 	jsonData, err := json.Marshal(AddJSONRequest{Data: data})
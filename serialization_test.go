@@ -16,13 +16,16 @@ package ct
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"encoding/pem"
 	"io/ioutil"
 	"reflect"
 	"testing"
 
+	"github.com/google/certificate-transparency-go/testdata"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
 )
 
 func dh(h string) []byte {
@@ -353,6 +356,48 @@ func TestUnmarshalSCT(t *testing.T) {
 	}
 }
 
+func TestSCTListRoundTrip(t *testing.T) {
+	sct2 := defaultSCT()
+	sct2.Timestamp = 5678
+	sct2.Extensions = []byte("ext")
+	scts := []SignedCertificateTimestamp{defaultSCT(), sct2}
+
+	data, err := SerializeSCTList(scts)
+	if err != nil {
+		t.Fatalf("SerializeSCTList()=nil,%v; want data,nil", err)
+	}
+	sct1Data, err := tls.Marshal(scts[0])
+	if err != nil {
+		t.Fatalf("tls.Marshal(sct)=nil,%v", err)
+	}
+	// Overall length, then the length of the first SCT.
+	if got, want := int(data[0])<<8|int(data[1]), len(data)-2; got != want {
+		t.Errorf("SerializeSCTList(): list length=%d; want %d", got, want)
+	}
+	if got, want := int(data[2])<<8|int(data[3]), len(sct1Data); got != want {
+		t.Errorf("SerializeSCTList(): first SCT length=%d; want %d", got, want)
+	}
+
+	got, err := DeserializeSCTList(data)
+	if err != nil {
+		t.Fatalf("DeserializeSCTList()=nil,%v; want scts,nil", err)
+	}
+	if !reflect.DeepEqual(got, scts) {
+		t.Errorf("DeserializeSCTList()=%+v; want %+v", got, scts)
+	}
+
+	for _, bad := range [][]byte{
+		nil,
+		{0x00, 0x00},                   // empty list
+		append(data, 0x00),             // trailing data
+		{0x00, 0x03, 0x00, 0x01, 0x00}, // truncated SCT
+	} {
+		if _, err := DeserializeSCTList(bad); err == nil {
+			t.Errorf("DeserializeSCTList(%x)=_,nil; want error", bad)
+		}
+	}
+}
+
 func TestX509MerkleTreeLeafHash(t *testing.T) {
 	certFile := "./testdata/test-cert.pem"
 	sctFile := "./testdata/test-cert.proof"
@@ -384,6 +429,70 @@ func TestX509MerkleTreeLeafHash(t *testing.T) {
 
 }
 
+func parsePEMCert(t *testing.T, data string) *x509.Certificate {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		t.Fatalf("Failed to decode PEM certificate")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func TestMerkleTreeLeafFromChain(t *testing.T) {
+	cert := parsePEMCert(t, testdata.TestCertPEM)
+	precert := parsePEMCert(t, testdata.TestPreCertPEM)
+	issuer := parsePEMCert(t, testdata.CACertPEM)
+
+	leaf, err := MerkleTreeLeafFromChain([]*x509.Certificate{cert, issuer}, X509LogEntryType, 1234)
+	if err != nil {
+		t.Fatalf("MerkleTreeLeafFromChain(cert)=nil,%v; want leaf,nil", err)
+	}
+	if want := CreateX509MerkleTreeLeaf(ASN1Cert{Data: cert.Raw}, 1234); !reflect.DeepEqual(leaf, want) {
+		t.Errorf("MerkleTreeLeafFromChain(cert)=%+v; want %+v", leaf, want)
+	}
+
+	leaf, err = MerkleTreeLeafFromChain([]*x509.Certificate{precert, issuer}, PrecertLogEntryType, 1234)
+	if err != nil {
+		t.Fatalf("MerkleTreeLeafFromChain(precert)=nil,%v; want leaf,nil", err)
+	}
+	entry := leaf.TimestampedEntry
+	if entry.EntryType != PrecertLogEntryType || entry.Timestamp != 1234 || entry.PrecertEntry == nil {
+		t.Fatalf("MerkleTreeLeafFromChain(precert)=%+v; want precert entry", entry)
+	}
+	if want := sha256.Sum256(issuer.RawSubjectPublicKeyInfo); entry.PrecertEntry.IssuerKeyHash != want {
+		t.Errorf("IssuerKeyHash=%x; want %x", entry.PrecertEntry.IssuerKeyHash, want)
+	}
+	wantTBS, err := x509.RemoveCTPoison(precert.RawTBSCertificate)
+	if err != nil {
+		t.Fatalf("RemoveCTPoison() failed: %v", err)
+	}
+	if !bytes.Equal(entry.PrecertEntry.TBSCertificate, wantTBS) {
+		t.Errorf("TBSCertificate=%x; want %x", entry.PrecertEntry.TBSCertificate, wantTBS)
+	}
+
+	if _, err := MerkleTreeLeafFromChain([]*x509.Certificate{precert}, PrecertLogEntryType, 1234); err == nil {
+		t.Error("MerkleTreeLeafFromChain(precert without issuer)=_,nil; want error")
+	}
+	if _, err := MerkleTreeLeafFromChain(nil, X509LogEntryType, 1234); err == nil {
+		t.Error("MerkleTreeLeafFromChain(empty chain)=_,nil; want error")
+	}
+	if _, err := MerkleTreeLeafFromRawChain([]ASN1Cert{{Data: []byte("not a cert")}}, PrecertLogEntryType, 1234); err == nil {
+		t.Error("MerkleTreeLeafFromRawChain(bad cert)=_,nil; want error")
+	}
+
+	// Certificates after an ordinary issuer aren't needed, so aren't parsed.
+	rawLeaf, err := MerkleTreeLeafFromRawChain([]ASN1Cert{{Data: precert.Raw}, {Data: issuer.Raw}, {Data: []byte("not a cert")}}, PrecertLogEntryType, 1234)
+	if err != nil {
+		t.Fatalf("MerkleTreeLeafFromRawChain(precert, issuer, bad cert)=nil,%v; want leaf,nil", err)
+	}
+	if !reflect.DeepEqual(rawLeaf, leaf) {
+		t.Errorf("MerkleTreeLeafFromRawChain(precert, issuer, bad cert)=%+v; want %+v", rawLeaf, leaf)
+	}
+}
+
 func TestJSONMerkleTreeLeaf(t *testing.T) {
 	data := `CioaINV25GV8X4a6M6Q10avSLP9PYd5N8MwWxQvWU7E2CzZ8IgYI0KnavAUSWAoIZDc1NjMzMzMSTAgEEAMaRjBEAiBQlnp6Q3di86g8M3l5gz+9qls/Cz1+KJ+tK/jpaBtUCgIgXaJ94uLsnChA1NY7ocGwKrQwPU688hwaZ5L/DboV4mQ=2`
 	timestamp := uint64(1469664866615)
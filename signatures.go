@@ -20,13 +20,14 @@ import (
 	"crypto/elliptic"
 	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/pem"
 	"flag"
 	"fmt"
 	"log"
 
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/crypto/ed25519"
 )
 
 var allowVerificationWithNonCompliantKeys = flag.Bool("allow_verification_with_non_compliant_keys", false,
@@ -68,6 +69,10 @@ func NewSignatureVerifier(pk crypto.PublicKey) (*SignatureVerifier, error) {
 			log.Printf("WARNING: %v", e)
 
 		}
+	case ed25519.PublicKey:
+		if len(pkType) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("public key is Ed25519 with wrong size %d", len(pkType))
+		}
 	default:
 		return nil, fmt.Errorf("Unsupported public key type %v", pkType)
 	}
@@ -91,6 +96,40 @@ func (s SignatureVerifier) VerifySCTSignature(sct SignedCertificateTimestamp, en
 	return s.VerifySignature(sctData, tls.DigitallySigned(sct.Signature))
 }
 
+// VerifyEmbeddedSCTs checks the SCTs embedded in a final certificate which were
+// issued by the log whose public key this verifier holds, against the
+// precertificate entry reconstructed from cert and its issuer.  SCTs from
+// other logs are ignored.  It returns the SCTs which were verified, or an
+// error if any SCT from this log fails to verify.
+func (s SignatureVerifier) VerifyEmbeddedSCTs(cert, issuer *x509.Certificate) ([]SignedCertificateTimestamp, error) {
+	scts, err := SCTsFromX509List(&cert.SCTList)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse embedded SCTs: %v", err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(s.pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal log public key: %v", err)
+	}
+	logID := sha256.Sum256(der)
+
+	var verified []SignedCertificateTimestamp
+	for i, sct := range scts {
+		if sct.LogID.KeyID != logID {
+			continue
+		}
+		leaf, err := MerkleTreeLeafForEmbeddedSCT([]*x509.Certificate{cert, issuer}, sct.Timestamp)
+		if err != nil {
+			return nil, err
+		}
+		leaf.TimestampedEntry.Extensions = sct.Extensions
+		if err := s.VerifySCTSignature(sct, LogEntry{Leaf: *leaf}); err != nil {
+			return nil, fmt.Errorf("failed to verify embedded SCT %d: %v", i, err)
+		}
+		verified = append(verified, sct)
+	}
+	return verified, nil
+}
+
 // VerifySTHSignature verifies that the STH's signature is valid.
 func (s SignatureVerifier) VerifySTHSignature(sth SignedTreeHead) error {
 	sthData, err := SerializeSTHSignatureInput(sth)
@@ -99,3 +138,43 @@ func (s SignatureVerifier) VerifySTHSignature(sth SignedTreeHead) error {
 	}
 	return s.VerifySignature(sthData, tls.DigitallySigned(sth.TreeHeadSignature))
 }
+
+// VerifySCTV2Signature verifies that a v2 SCT's signature is valid for the
+// given x509_entry_v2 or precert_entry_v2 TransItem.
+func (s SignatureVerifier) VerifySCTV2Signature(sct SignedCertificateTimestampDataV2, entry TransItem) error {
+	data, err := SerializeSCTV2SignatureInput(entry)
+	if err != nil {
+		return err
+	}
+	return s.verifyV2Signature(data, sct.Signature)
+}
+
+// VerifySTHV2Signature verifies that a v2 STH's signature is valid.
+func (s SignatureVerifier) VerifySTHV2Signature(sth SignedTreeHeadDataV2) error {
+	data, err := SerializeSTHV2SignatureInput(sth)
+	if err != nil {
+		return err
+	}
+	return s.verifyV2Signature(data, sth.Signature)
+}
+
+// verifyV2Signature checks a bare v2 signature, which (unlike a v1
+// DigitallySigned) doesn't indicate its algorithm; that is determined by the
+// log's key instead.  RFC 9162 s4.1 only permits ECDSA with the P-256 curve
+// and Ed25519, so other keys are rejected even if non-compliant keys are
+// allowed for v1.
+func (s SignatureVerifier) verifyV2Signature(data, sig []byte) error {
+	var algo tls.SignatureAndHashAlgorithm
+	switch key := s.pubKey.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return fmt.Errorf("ECDSA public key is not on the P-256 curve, as required for v2 signatures")
+		}
+		algo = tls.SignatureAndHashAlgorithm{Hash: tls.SHA256, Signature: tls.ECDSA}
+	case ed25519.PublicKey:
+		algo = tls.SignatureAndHashAlgorithm{Hash: tls.Intrinsic, Signature: tls.Ed25519}
+	default:
+		return fmt.Errorf("unsupported public key type %T for v2 signatures", s.pubKey)
+	}
+	return s.VerifySignature(data, tls.DigitallySigned{Algorithm: algo, Signature: sig})
+}
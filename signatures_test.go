@@ -21,11 +21,19 @@ import (
 	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"encoding/hex"
+	"encoding/pem"
+	"math/big"
 	mrand "math/rand"
 	"testing"
+	"time"
 
+	"github.com/google/certificate-transparency-go/testdata"
 	"github.com/google/certificate-transparency-go/tls"
+	"github.com/google/certificate-transparency-go/x509"
+	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/crypto/ed25519"
 )
 
 const (
@@ -351,6 +359,137 @@ func TestVerifySCTSignatureFailsForSignatureCreatedWithDifferentKey(t *testing.T
 	testVerifySCTSignatureFailsForIncorrectSignature(t, sigTestSCTEC(t), v)
 }
 
+// parseTestCert parses a certificate, ignoring non-fatal errors such as the
+// unhandled critical poison extension of a precertificate.
+func parseTestCert(t *testing.T, der []byte) *x509.Certificate {
+	cert, err := x509.ParseCertificate(der)
+	if _, ok := err.(x509.NonFatalErrors); err != nil && !ok {
+		t.Fatalf("Failed to parse certificate: %v", err)
+	}
+	return cert
+}
+
+func makeTestCA(t *testing.T, name string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Unix(1000, 0),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("Failed to create CA certificate: %v", err)
+	}
+	return parseTestCert(t, der), key
+}
+
+func TestVerifyEmbeddedSCTs(t *testing.T) {
+	ca, caKey := makeTestCA(t, "CA")
+	otherCA, _ := makeTestCA(t, "Other CA")
+	var logKeys []*ecdsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatalf("Failed to generate key: %v", err)
+		}
+		logKeys = append(logKeys, key)
+	}
+
+	template := x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "www.example.com"},
+		DNSNames:     []string{"www.example.com"},
+		NotBefore:    time.Unix(1000, 0),
+		NotAfter:     time.Now().AddDate(1, 0, 0),
+	}
+	precertTemplate := template
+	precertTemplate.ExtraExtensions = []pkix.Extension{{Id: x509.OIDExtensionCTPoison, Critical: true, Value: []byte{0x05, 0x00}}}
+	der, err := x509.CreateCertificate(rand.Reader, &precertTemplate, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create precertificate: %v", err)
+	}
+	precert := parseTestCert(t, der)
+
+	// The first two logs issue SCTs for the precertificate.
+	var scts []SignedCertificateTimestamp
+	for i, logKey := range logKeys[:2] {
+		leaf, err := MerkleTreeLeafFromChain([]*x509.Certificate{precert, ca}, PrecertLogEntryType, uint64(1000+i))
+		if err != nil {
+			t.Fatalf("MerkleTreeLeafFromChain() failed: %v", err)
+		}
+		keyDER, err := x509.MarshalPKIXPublicKey(&logKey.PublicKey)
+		if err != nil {
+			t.Fatalf("Failed to marshal public key: %v", err)
+		}
+		sct := SignedCertificateTimestamp{
+			SCTVersion: V1,
+			LogID:      LogID{KeyID: sha256.Sum256(keyDER)},
+			Timestamp:  uint64(1000 + i),
+			Extensions: CTExtensions{},
+		}
+		data, err := SerializeSCTSignatureInput(sct, LogEntry{Leaf: *leaf})
+		if err != nil {
+			t.Fatalf("SerializeSCTSignatureInput() failed: %v", err)
+		}
+		sig, err := tls.CreateSignature(*logKey, tls.SHA256, data)
+		if err != nil {
+			t.Fatalf("CreateSignature() failed: %v", err)
+		}
+		sct.Signature = DigitallySigned(sig)
+		scts = append(scts, sct)
+	}
+
+	list, err := X509SCTList(scts)
+	if err != nil {
+		t.Fatalf("X509SCTList() failed: %v", err)
+	}
+	template.SCTList = *list
+	der, err = x509.CreateCertificate(rand.Reader, &template, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatalf("Failed to create final certificate: %v", err)
+	}
+	cert := parseTestCert(t, der)
+
+	for i, test := range []struct {
+		logKey  *ecdsa.PrivateKey
+		issuer  *x509.Certificate
+		want    int
+		wantErr bool
+	}{
+		{logKey: logKeys[0], issuer: ca, want: 1},
+		{logKey: logKeys[1], issuer: ca, want: 1},
+		{logKey: logKeys[2], issuer: ca, want: 0},
+		{logKey: logKeys[0], issuer: otherCA, wantErr: true},
+	} {
+		v := mustCreateSignatureVerifier(t, &test.logKey.PublicKey)
+		verified, err := v.VerifyEmbeddedSCTs(cert, test.issuer)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%d: VerifyEmbeddedSCTs()=%d SCTs,nil; want error", i, len(verified))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%d: VerifyEmbeddedSCTs()=nil,%v; want %d SCTs,nil", i, err, test.want)
+		} else if len(verified) != test.want {
+			t.Errorf("%d: VerifyEmbeddedSCTs()=%d SCTs,nil; want %d SCTs,nil", i, len(verified), test.want)
+		}
+	}
+
+	// The precertificate itself has no embedded SCTs.
+	v := mustCreateSignatureVerifier(t, &logKeys[0].PublicKey)
+	if verified, err := v.VerifyEmbeddedSCTs(precert, ca); err != nil || len(verified) != 0 {
+		t.Errorf("VerifyEmbeddedSCTs(precert)=%d SCTs,%v; want 0 SCTs,nil", len(verified), err)
+	}
+}
+
 func expectVerifySTHToPass(t *testing.T, v SignatureVerifier, sth SignedTreeHead) {
 	if err := v.VerifySTHSignature(sth); err != nil {
 		t.Fatalf("Incorrectly failed to verify STH signature: %v", err)
@@ -422,6 +561,63 @@ func TestVerifySTHFailsToVerifyForDifferentKey(t *testing.T) {
 	expectVerifySTHToFail(t, v, sth)
 }
 
+func TestVerifyEd25519Signatures(t *testing.T) {
+	pk, keyID, _, err := PublicKeyFromPEM([]byte(testdata.Ed25519PublicKeyPEM))
+	if err != nil {
+		t.Fatalf("Failed to parse Ed25519 public key: %v", err)
+	}
+	block, _ := pem.Decode([]byte(testdata.Ed25519PrivateKeyPEM))
+	privKey, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse Ed25519 private key: %v", err)
+	}
+	v := mustCreateSignatureVerifier(t, pk)
+
+	sth := sigTestDefaultSTH(t)
+	data, err := SerializeSTHSignatureInput(sth)
+	if err != nil {
+		t.Fatalf("SerializeSTHSignatureInput()=%v", err)
+	}
+	sig, err := tls.CreateSignature(privKey, tls.SHA256, data)
+	if err != nil {
+		t.Fatalf("CreateSignature()=%v", err)
+	}
+	if want := (tls.SignatureAndHashAlgorithm{Hash: tls.Intrinsic, Signature: tls.Ed25519}); sig.Algorithm != want {
+		t.Errorf("CreateSignature() gave algorithm %+v; want %+v", sig.Algorithm, want)
+	}
+	sth.TreeHeadSignature = DigitallySigned(sig)
+	expectVerifySTHToPass(t, v, sth)
+	sth.TreeSize++
+	expectVerifySTHToFail(t, v, sth)
+
+	entry := sigTestCertLogEntry(t)
+	sct := SignedCertificateTimestamp{
+		SCTVersion: V1,
+		LogID:      LogID{KeyID: keyID},
+		Timestamp:  sigTestSCTTimestamp,
+		Extensions: CTExtensions{},
+	}
+	data, err = SerializeSCTSignatureInput(sct, entry)
+	if err != nil {
+		t.Fatalf("SerializeSCTSignatureInput()=%v", err)
+	}
+	if sig, err = tls.CreateSignature(privKey, tls.Intrinsic, data); err != nil {
+		t.Fatalf("CreateSignature()=%v", err)
+	}
+	sct.Signature = DigitallySigned(sig)
+	if err := v.VerifySCTSignature(sct, entry); err != nil {
+		t.Errorf("VerifySCTSignature()=%v; want nil", err)
+	}
+	// An ECDSA verifier must not accept the Ed25519 signature.
+	expectVerifySCTToFail(t, mustCreateSignatureVerifier(t, sigTestECPublicKey(t)), sct, "Verified Ed25519 SCT signature with ECDSA key")
+	sct.Timestamp++
+	expectVerifySCTToFail(t, v, sct, "Incorrectly verified Ed25519 SCT signature over wrong timestamp")
+
+	if _, err := NewSignatureVerifier(ed25519.PublicKey(make([]byte, 31))); err == nil {
+		t.Error("NewSignatureVerifier() with short Ed25519 key succeeded; want error")
+	}
+}
+
 func TestNewSignatureVerifierFailsWithUnsupportedKeyType(t *testing.T) {
 	var k dsa.PrivateKey
 	if err := dsa.GenerateParameters(&k.Parameters, rand.Reader, dsa.L1024N160); err != nil {
@@ -30,6 +30,7 @@ import (
 	"math/big"
 
 	"github.com/google/certificate-transparency-go/asn1"
+	"golang.org/x/crypto/ed25519"
 )
 
 type dsaSig struct {
@@ -64,6 +65,24 @@ func generateHash(algo HashAlgorithm, data []byte) ([]byte, crypto.Hash, error)
 
 // VerifySignature verifies that the passed in signature over data was created by the given PublicKey.
 func VerifySignature(pubKey crypto.PublicKey, data []byte, sig DigitallySigned) error {
+	if sig.Algorithm.Signature == Ed25519 {
+		// Ed25519 signs the data itself, rather than a hash of it.
+		if sig.Algorithm.Hash != Intrinsic {
+			return fmt.Errorf("unsupported Algorithm.Hash in Ed25519 signature: %v", sig.Algorithm.Hash)
+		}
+		ed25519Key, ok := pubKey.(ed25519.PublicKey)
+		if !ok {
+			return fmt.Errorf("cannot verify Ed25519 signature with %T key", pubKey)
+		}
+		if len(ed25519Key) != ed25519.PublicKeySize {
+			return fmt.Errorf("Ed25519 public key has wrong size %d", len(ed25519Key))
+		}
+		if !ed25519.Verify(ed25519Key, data, sig.Signature) {
+			return errors.New("failed to verify Ed25519 signature")
+		}
+		return nil
+	}
+
 	hash, hashType, err := generateHash(sig.Algorithm.Hash, data)
 	if err != nil {
 		return err
@@ -124,8 +143,19 @@ func VerifySignature(pubKey crypto.PublicKey, data []byte, sig DigitallySigned)
 }
 
 // CreateSignature builds a signature over the given data using the specified hash algorithm and private key.
+// Ed25519 keys sign the data directly, so for them hashAlgo is ignored and the signature's hash algorithm
+// is always Intrinsic.
 func CreateSignature(privKey crypto.PrivateKey, hashAlgo HashAlgorithm, data []byte) (DigitallySigned, error) {
 	var sig DigitallySigned
+	if ed25519Key, ok := privKey.(ed25519.PrivateKey); ok {
+		if len(ed25519Key) != ed25519.PrivateKeySize {
+			return sig, fmt.Errorf("Ed25519 private key has wrong size %d", len(ed25519Key))
+		}
+		sig.Algorithm = SignatureAndHashAlgorithm{Hash: Intrinsic, Signature: Ed25519}
+		sig.Signature = ed25519.Sign(ed25519Key, data)
+		return sig, nil
+	}
+
 	sig.Algorithm.Hash = hashAlgo
 	hash, hashType, err := generateHash(sig.Algorithm.Hash, data)
 	if err != nil {
@@ -137,6 +167,15 @@ func CreateSignature(privKey crypto.PrivateKey, hashAlgo HashAlgorithm, data []b
 		sig.Algorithm.Signature = RSA
 		sig.Signature, err = rsa.SignPKCS1v15(rand.Reader, &privKey, hashType, hash)
 		return sig, err
+	case dsa.PrivateKey:
+		sig.Algorithm.Signature = DSA
+		var dsaSig dsaSig
+		dsaSig.R, dsaSig.S, err = dsa.Sign(rand.Reader, &privKey, hash)
+		if err != nil {
+			return sig, err
+		}
+		sig.Signature, err = asn1.Marshal(dsaSig)
+		return sig, err
 	case ecdsa.PrivateKey:
 		sig.Algorithm.Signature = ECDSA
 		var ecdsaSig dsaSig
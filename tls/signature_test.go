@@ -16,11 +16,16 @@ package tls
 
 import (
 	"crypto"
+	"crypto/dsa"
 	"crypto/ecdsa"
 	"crypto/elliptic"
 	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
 	"encoding/hex"
 	"encoding/pem"
+	"errors"
+	"math/big"
 	mathrand "math/rand"
 	"reflect"
 	"strings"
@@ -28,7 +33,7 @@ import (
 	"time"
 
 	"github.com/google/certificate-transparency-go/testdata"
-	"github.com/google/certificate-transparency-go/x509"
+	"golang.org/x/crypto/ed25519"
 )
 
 func TestGenerateHash(t *testing.T) {
@@ -102,9 +107,18 @@ func TestVerifySignature(t *testing.T) {
 		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", SHA256, ECDSA, "failed to verify ECDSA signature", "3006020101020101eeff"},
 		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", SHA256, ECDSA, "zero or negative values", "3006020100020181"},
 
+		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", Intrinsic, Ed25519, "cannot verify Ed25519", testdata.Ed25519SignedAbcdHex},
+
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626364", Intrinsic, ECDSA, "unsupported Algorithm.Hash", "1234"},
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626364", SHA256, ECDSA, "cannot verify ECDSA", testdata.EcdsaSignedAbcdHex},
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626364", SHA256, Ed25519, "unsupported Algorithm.Hash", testdata.Ed25519SignedAbcdHex},
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626364", Intrinsic, Ed25519, "failed to verify Ed25519 signature", "1234"},
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626365", Intrinsic, Ed25519, "failed to verify Ed25519 signature", testdata.Ed25519SignedAbcdHex},
+
 		{PEM2PK(testdata.RsaPublicKeyPEM), "61626364", SHA256, RSA, "", testdata.RsaSignedAbcdHex},
 		{PEM2PK(testdata.DsaPublicKeyPEM), "61626364", SHA1, DSA, "", testdata.DsaSignedAbcdHex},
 		{PEM2PK(testdata.EcdsaPublicKeyPEM), "61626364", SHA256, ECDSA, "", testdata.EcdsaSignedAbcdHex},
+		{PEM2PK(testdata.Ed25519PublicKeyPEM), "61626364", Intrinsic, Ed25519, "", testdata.Ed25519SignedAbcdHex},
 	}
 	for _, test := range tests {
 		algo := SignatureAndHashAlgorithm{Hash: test.hashAlgo, Signature: test.sigAlgo}
@@ -132,7 +146,9 @@ func TestCreateSignatureVerifySignatureRoundTrip(t *testing.T) {
 		hashAlgo HashAlgorithm
 	}{
 		{PEM2PrivKey(testdata.RsaPrivateKeyPEM), PEM2PK(testdata.RsaPublicKeyPEM), SHA256},
+		{PEM2PrivKey(testdata.DsaPrivateKeyPEM), PEM2PK(testdata.DsaPublicKeyPEM), SHA1},
 		{PEM2PrivKey(testdata.EcdsaPrivateKeyPKCS8PEM), PEM2PK(testdata.EcdsaPublicKeyPEM), SHA256},
+		{PEM2PrivKey(testdata.Ed25519PrivateKeyPEM), PEM2PK(testdata.Ed25519PublicKeyPEM), SHA256},
 	}
 	seed := time.Now().UnixNano()
 	r := mathrand.New(mathrand.NewSource(seed))
@@ -162,6 +178,7 @@ func TestCreateSignatureFailures(t *testing.T) {
 		errstr   string
 	}{
 		{PEM2PrivKey(testdata.EcdsaPrivateKeyPKCS8PEM), 99, "abcd", "unsupported Algorithm.Hash"},
+		{ed25519.PrivateKey([]byte{1, 2, 3}), Intrinsic, "abcd", "wrong size"},
 		{nil, SHA256, "abcd", "unsupported private key type"},
 		{bogusKey(), MD5, "abcd", "zero parameter"},
 	}
@@ -195,6 +212,9 @@ func PEM2PrivKey(s string) crypto.PrivateKey {
 	if rsaPrivKey, err := x509.ParsePKCS1PrivateKey(p.Bytes); err == nil {
 		return *rsaPrivKey
 	}
+	if dsaPrivKey, err := parseDSAPrivateKey(p.Bytes); err == nil {
+		return *dsaPrivKey
+	}
 	if pkcs8Key, err := x509.ParsePKCS8PrivateKey(p.Bytes); err == nil {
 		if reflect.TypeOf(pkcs8Key).Kind() == reflect.Ptr {
 			pkcs8Key = reflect.ValueOf(pkcs8Key).Elem().Interface()
@@ -204,6 +224,30 @@ func PEM2PrivKey(s string) crypto.PrivateKey {
 
 	return nil
 }
+
+// parseDSAPrivateKey parses an OpenSSL "DSA PRIVATE KEY", which crypto/x509
+// has no parser for.
+func parseDSAPrivateKey(der []byte) (*dsa.PrivateKey, error) {
+	var k struct {
+		Version       int
+		P, Q, G, Y, X *big.Int
+	}
+	rest, err := asn1.Unmarshal(der, &k)
+	if err != nil {
+		return nil, err
+	}
+	if len(rest) > 0 {
+		return nil, errors.New("trailing data after DSA private key")
+	}
+	return &dsa.PrivateKey{
+		PublicKey: dsa.PublicKey{
+			Parameters: dsa.Parameters{P: k.P, Q: k.Q, G: k.G},
+			Y:          k.Y,
+		},
+		X: k.X,
+	}, nil
+}
+
 func bogusKey() crypto.PrivateKey {
 	bogusCurve := elliptic.P224()
 	bogusCurve.Params().N.SetInt64(0)
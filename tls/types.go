@@ -46,6 +46,9 @@ const (
 	SHA256 HashAlgorithm = 4
 	SHA384 HashAlgorithm = 5
 	SHA512 HashAlgorithm = 6
+	// Intrinsic indicates a signature algorithm which doesn't prehash the
+	// data, from RFC 8422 s5.1.3.
+	Intrinsic HashAlgorithm = 8
 )
 
 func (h HashAlgorithm) String() string {
@@ -64,6 +67,8 @@ func (h HashAlgorithm) String() string {
 		return "SHA384"
 	case SHA512:
 		return "SHA512"
+	case Intrinsic:
+		return "Intrinsic"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", h)
 	}
@@ -78,6 +83,9 @@ const (
 	RSA       SignatureAlgorithm = 1
 	DSA       SignatureAlgorithm = 2
 	ECDSA     SignatureAlgorithm = 3
+	// Ed25519 is from RFC 8422 s5.1.3, and is always used with the Intrinsic
+	// HashAlgorithm.
+	Ed25519 SignatureAlgorithm = 7
 )
 
 func (s SignatureAlgorithm) String() string {
@@ -90,6 +98,8 @@ func (s SignatureAlgorithm) String() string {
 		return "DSA"
 	case ECDSA:
 		return "ECDSA"
+	case Ed25519:
+		return "Ed25519"
 	default:
 		return fmt.Sprintf("UNKNOWN(%d)", s)
 	}
@@ -28,6 +28,7 @@ func TestHashAlgorithmString(t *testing.T) {
 		{SHA256, "SHA256"},
 		{SHA384, "SHA384"},
 		{SHA512, "SHA512"},
+		{Intrinsic, "Intrinsic"},
 		{99, "UNKNOWN(99)"},
 	}
 	for _, test := range tests {
@@ -46,6 +47,7 @@ func TestSignatureAlgorithmString(t *testing.T) {
 		{RSA, "RSA"},
 		{DSA, "DSA"},
 		{ECDSA, "ECDSA"},
+		{Ed25519, "Ed25519"},
 		{99, "UNKNOWN(99)"},
 	}
 	for _, test := range tests {
@@ -47,10 +47,13 @@ func IsPrecertificate(cert *x509.Certificate) (bool, error) {
 }
 
 // ValidateChain takes the certificate chain as it was parsed from a JSON request. Ensures all
-// elements in the chain decode as X.509 certificates. Ensures that there is a valid path from the
-// end entity certificate in the chain to a trusted root cert, possibly using the intermediates
-// supplied in the chain. Then applies the RFC requirement that the path must involve all
-// the submitted chain in the order of submission.
+// elements in the chain decode as X.509 certificates, and that the first certificate's NotAfter
+// falls within any range given in the validation options (returning a PolicyError if not).
+// Ensures that there is a valid path from the end entity certificate in the chain to a trusted
+// root cert, possibly using the intermediates supplied in the chain. Then applies the RFC
+// requirement that the path must involve all the submitted chain in the order of submission,
+// and finally checks the path against any ChainPolicy in the validation options, returning a
+// PolicyError on failure.
 func ValidateChain(rawChain [][]byte, validationOpts CertValidationOpts) ([]*x509.Certificate, error) {
 	// First make sure the certs parse as X.509
 	chain := make([]*x509.Certificate, 0, len(rawChain))
@@ -73,6 +76,15 @@ func ValidateChain(rawChain [][]byte, validationOpts CertValidationOpts) ([]*x50
 		}
 	}
 
+	// Temporal shards only accept certificates that expire within their range.
+	notAfter := chain[0].NotAfter
+	if start := validationOpts.notAfterStart; start != nil && notAfter.Before(*start) {
+		return nil, PolicyError{fmt.Sprintf("certificate NotAfter (%v) is before %v", notAfter, *start)}
+	}
+	if limit := validationOpts.notAfterLimit; limit != nil && !notAfter.Before(*limit) {
+		return nil, PolicyError{fmt.Sprintf("certificate NotAfter (%v) is not before %v", notAfter, *limit)}
+	}
+
 	// We can now do the verification
 	verifyOpts := x509.VerifyOptions{
 		Roots:             validationOpts.trustedRoots.CertPool(),
@@ -99,6 +111,11 @@ func ValidateChain(rawChain [][]byte, validationOpts CertValidationOpts) ([]*x50
 	// requirements detailed in Section 3.1.
 	for _, verifiedChain := range chains {
 		if chainsEquivalent(chain, verifiedChain) {
+			if validationOpts.policy != nil {
+				if err := validationOpts.policy.CheckChain(verifiedChain); err != nil {
+					return nil, err
+				}
+			}
 			return verifiedChain, nil
 		}
 	}
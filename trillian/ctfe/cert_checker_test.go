@@ -17,6 +17,7 @@ package ctfe
 import (
 	"encoding/pem"
 	"testing"
+	"time"
 
 	"github.com/google/certificate-transparency-go/trillian/ctfe/testonly"
 	"github.com/google/certificate-transparency-go/x509"
@@ -153,6 +154,46 @@ func TestValidateChain(t *testing.T) {
 	}
 }
 
+func TestValidateChainNotAfterRange(t *testing.T) {
+	fakeCARoots := NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testonly.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	// The leaf certificate expires on 2019-07-12.
+	chain := pemsToDERChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPEM, testonly.FakeIntermediateCertPEM})
+	y2019 := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	y2020 := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	notAfter := time.Date(2019, 7, 12, 14, 26, 44, 0, time.UTC)
+
+	var tests = []struct {
+		desc         string
+		start, limit *time.Time
+		wantErr      bool
+	}{
+		{desc: "unbounded"},
+		{desc: "in-range", start: &y2019, limit: &y2020},
+		{desc: "at-start", start: &notAfter},
+		{desc: "too-early", start: &y2020, wantErr: true},
+		{desc: "at-limit", limit: &notAfter, wantErr: true},
+		{desc: "too-late", limit: &y2019, wantErr: true},
+	}
+	for _, test := range tests {
+		validateOpts := NewCertValidationOpts(fakeCARoots, false, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, test.start, test.limit, nil)
+		_, err := ValidateChain(chain, validateOpts)
+		if err != nil {
+			if !test.wantErr {
+				t.Errorf("ValidateChain(%v)=_,%v; want _,nil", test.desc, err)
+			} else if _, ok := err.(PolicyError); !ok {
+				t.Errorf("ValidateChain(%v)=_,%T; want _,PolicyError", test.desc, err)
+			}
+			continue
+		}
+		if test.wantErr {
+			t.Errorf("ValidateChain(%v)=_,nil; want _,non-nil", test.desc)
+		}
+	}
+}
+
 // Builds a chain of DER-encoded certs.
 // Note: ordering is important
 func pemsToDERChain(t *testing.T, pemCerts []string) [][]byte {
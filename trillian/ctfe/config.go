@@ -0,0 +1,145 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/jsonpb"
+	"github.com/golang/protobuf/proto"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+)
+
+// LogMultiConfig holds a validated configpb.LogMultiConfig, with its
+// server-wide options parsed.  Options that are left unset are zero, and
+// keep the server's defaults.
+type LogMultiConfig struct {
+	// MaxGetEntriesAllowed limits the number of entries returned by a
+	// get-entries request.
+	MaxGetEntriesAllowed int64
+	// RPCDeadline is the deadline for backend RPC requests; individual logs
+	// may override it.
+	RPCDeadline time.Duration
+	Logs        []*configpb.LogConfig
+}
+
+// ConfigErrors holds all of the problems found in a configuration.
+type ConfigErrors []error
+
+func (e ConfigErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// newConfigErrors returns a ConfigErrors holding errs, or nil if there are none.
+func newConfigErrors(errs []error) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return ConfigErrors(errs)
+}
+
+// LogMultiConfigFromFile reads a configpb.LogMultiConfig from the given
+// filename, which should hold it in text or JSON protobuf format; fields that
+// aren't in the schema are rejected.  For compatibility, the file may instead
+// hold the legacy JSON encoded list of LogConfig read by LogConfigFromFile,
+// which can't set the server-wide options and isn't checked for unknown
+// fields.  The configuration is validated with ValidateLogMultiConfig, and all
+// of the problems found are reported together, as ConfigErrors.
+func LogMultiConfigFromFile(filename string) (*LogMultiConfig, error) {
+	if len(filename) == 0 {
+		return nil, errors.New("log config filename empty")
+	}
+	cfgData, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read log config: %v", err)
+	}
+
+	var pb configpb.LogMultiConfig
+	var errs []error
+	switch trimmed := bytes.TrimSpace(cfgData); {
+	case bytes.HasPrefix(trimmed, []byte("[")):
+		var cfgs []LogConfig
+		if err := json.Unmarshal(cfgData, &cfgs); err != nil {
+			return nil, fmt.Errorf("failed to parse config data: %v", err)
+		}
+		for i, cfg := range cfgs {
+			logPB, logErrs := cfg.toProto()
+			for _, err := range logErrs {
+				errs = append(errs, fmt.Errorf("Logs[%d] (%q): %v", i, cfg.Prefix, err))
+			}
+			pb.Logs = append(pb.Logs, logPB)
+		}
+	case bytes.HasPrefix(trimmed, []byte("{")):
+		if err := jsonpb.Unmarshal(bytes.NewReader(cfgData), &pb); err != nil {
+			return nil, fmt.Errorf("failed to parse config data: %v", err)
+		}
+	default:
+		if err := proto.UnmarshalText(string(cfgData), &pb); err != nil {
+			return nil, fmt.Errorf("failed to parse config data: %v", err)
+		}
+	}
+	if len(pb.Logs) == 0 {
+		return nil, errors.New("empty log config found")
+	}
+
+	cfg, validateErrs := validateLogMultiConfig(&pb)
+	if errs = append(errs, validateErrs...); len(errs) > 0 {
+		return nil, ConfigErrors(errs)
+	}
+	return cfg, nil
+}
+
+// ValidateLogMultiConfig checks the server-wide options and each of the logs
+// in the given configuration, and checks that the logs can be served together
+// (see ValidateLogConfigs).  The files that the configuration refers to are not
+// read.  All of the problems found are reported, as ConfigErrors.
+func ValidateLogMultiConfig(pb *configpb.LogMultiConfig) (*LogMultiConfig, error) {
+	cfg, errs := validateLogMultiConfig(pb)
+	if len(errs) > 0 {
+		return nil, ConfigErrors(errs)
+	}
+	return cfg, nil
+}
+
+func validateLogMultiConfig(pb *configpb.LogMultiConfig) (*LogMultiConfig, []error) {
+	cfg := LogMultiConfig{MaxGetEntriesAllowed: pb.MaxGetEntriesAllowed, Logs: pb.Logs}
+	var errs []error
+	if pb.MaxGetEntriesAllowed < 0 {
+		errs = append(errs, fmt.Errorf("MaxGetEntriesAllowed must not be negative, not %d", pb.MaxGetEntriesAllowed))
+	}
+	if pb.RpcDeadline != nil {
+		var err error
+		if cfg.RPCDeadline, err = parseDeadline(pb.RpcDeadline); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	for i, logCfg := range pb.Logs {
+		_, logErrs := parseLogConfig(logCfg)
+		for _, err := range logErrs {
+			errs = append(errs, fmt.Errorf("Logs[%d] (%q): %v", i, logCfg.Prefix, err))
+		}
+	}
+	return &cfg, append(errs, validateLogConfigs(pb.Logs)...)
+}
@@ -0,0 +1,284 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+
+	durpb "github.com/golang/protobuf/ptypes/duration"
+)
+
+func TestValidateLogMultiConfig(t *testing.T) {
+	valid := &configpb.LogConfig{
+		LogId:          1,
+		Prefix:         "log",
+		RootsPemFile:   []string{"roots.pem"},
+		PrivKeyPemFile: "key.pem",
+	}
+
+	var tests = []struct {
+		desc         string
+		cfg          configpb.LogMultiConfig
+		wantDeadline time.Duration
+		wantErr      []string
+	}{
+		{
+			desc: "valid",
+			cfg: configpb.LogMultiConfig{
+				MaxGetEntriesAllowed: 1000,
+				RpcDeadline:          &durpb.Duration{Seconds: 30},
+				Logs:                 []*configpb.LogConfig{valid},
+			},
+			wantDeadline: 30 * time.Second,
+		},
+		{
+			desc: "bad-server-options",
+			cfg: configpb.LogMultiConfig{
+				MaxGetEntriesAllowed: -1,
+				RpcDeadline:          &durpb.Duration{Seconds: -5},
+				Logs:                 []*configpb.LogConfig{valid},
+			},
+			wantErr: []string{"MaxGetEntriesAllowed must not be negative", "RPCDeadline -5s is not positive"},
+		},
+		{
+			desc: "bad-logs",
+			cfg: configpb.LogMultiConfig{
+				Logs: []*configpb.LogConfig{
+					valid,
+					{
+						LogId:               2,
+						Prefix:              "other",
+						PrivKeyPassword:     "dirk",
+						PrivKeyPasswordFile: "password.txt",
+						ExtKeyUsages:        []configpb.ExtKeyUsage{configpb.ExtKeyUsage_ANY, 42},
+						RpcDeadline:         &durpb.Duration{Nanos: -1},
+					},
+					{
+						LogId:          1,
+						Prefix:         "/log",
+						RootsPemFile:   []string{"roots.pem"},
+						PrivKeyPemFile: "key.pem",
+						Policy:         &configpb.PolicyConfig{MaxChainLength: -1},
+					},
+				},
+			},
+			wantErr: []string{
+				`Logs[1] ("other"): need to specify RootsPEMFile`,
+				`Logs[1] ("other"): need to specify PrivKeyPEMFile`,
+				`Logs[1] ("other"): specify at most one of PrivKeyPassword and PrivKeyPasswordFile`,
+				`Logs[1] ("other"): unknown extended key usage: 42`,
+				`Logs[1] ("other"): RPCDeadline -1ns is not positive`,
+				`Logs[2] ("/log"): invalid Policy`,
+				"duplicate LogID 1",
+				`duplicate Prefix "/log"`,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		cfg, err := ValidateLogMultiConfig(&test.cfg)
+		checkConfigErrors(t, "ValidateLogMultiConfig("+test.desc+")", err, test.wantErr)
+		if err == nil && cfg.RPCDeadline != test.wantDeadline {
+			t.Errorf("ValidateLogMultiConfig(%s): RPCDeadline=%v; want %v", test.desc, cfg.RPCDeadline, test.wantDeadline)
+		}
+	}
+}
+
+func TestLogMultiConfigFromFile(t *testing.T) {
+	var tests = []struct {
+		desc          string
+		contents      string
+		wantLogs      int
+		wantMaxGetEnt int64
+		wantDeadline  time.Duration
+		wantErr       []string
+	}{
+		{
+			desc: "text",
+			contents: `
+				max_get_entries_allowed: 100
+				rpc_deadline { seconds: 5 }
+				logs {
+					log_id: 1
+					prefix: "one"
+					roots_pem_file: "roots.pem"
+					priv_key_pem_file: "key.pem"
+					ext_key_usages: SERVER_AUTH
+					not_after_start { seconds: 1483228800 }
+					policy {
+						required_extensions { arc: [2, 5, 29, 19] }
+						key_algorithms: ECDSA
+					}
+				}
+				logs {
+					log_id: 2
+					prefix: "two"
+					roots_pem_file: "roots.pem"
+					priv_key_pem_file: "key.pem"
+					rpc_deadline { seconds: 2 }
+				}`,
+			wantLogs:      2,
+			wantMaxGetEnt: 100,
+			wantDeadline:  5 * time.Second,
+		},
+		{
+			desc: "json",
+			contents: `{
+				"maxGetEntriesAllowed": 100,
+				"rpcDeadline": "5s",
+				"logs": [
+					{"logId": 1, "prefix": "one", "rootsPemFile": ["roots.pem"], "privKeyPemFile": "key.pem",
+					 "notAfterLimit": "2018-01-01T00:00:00Z",
+					 "policy": {"keyAlgorithms": ["ECDSA", "ED25519"]}}
+				]
+			}`,
+			wantLogs:      1,
+			wantMaxGetEnt: 100,
+			wantDeadline:  5 * time.Second,
+		},
+		{
+			desc: "legacy-list",
+			contents: `[
+				{"LogID": 1, "Prefix": "one", "RootsPEMFile": ["roots.pem"], "PrivKeyPEMFile": "key.pem", "RPCDeadline": "2s"},
+				{"LogID": 2, "Prefix": "two", "RootsPEMFile": ["roots.pem"], "PrivKeyPEMFile": "key.pem",
+				 "Policy": {"NotAfterStart": "2017-01-01T00:00:00Z", "KeyAlgorithms": ["Ed25519"]}}
+			]`,
+			wantLogs: 2,
+		},
+		{
+			desc: "legacy-list-invalid",
+			contents: `[
+				{"LogID": 1, "Prefix": "one", "RootsPEMFile": ["roots.pem"], "PrivKeyPEMFile": "key.pem",
+				 "ExtKeyUsages": ["ServerAuth", "Nothing"], "RPCDeadline": "soon"},
+				{"LogID": 1, "Prefix": "two", "PrivKeyPEMFile": "key.pem"}
+			]`,
+			wantErr: []string{
+				`Logs[0] ("one"): unknown extended key usage: Nothing`,
+				`Logs[0] ("one"): invalid RPCDeadline`,
+				`Logs[1] ("two"): need to specify RootsPEMFile`,
+				"duplicate LogID 1",
+			},
+		},
+		{
+			desc:     "unknown-text-field",
+			contents: `max_get_entries: 100 logs { log_id: 1 }`,
+			wantErr:  []string{"failed to parse config data"},
+		},
+		{
+			desc:     "unknown-json-field",
+			contents: `{"logs": [{"logId": 1, "prefix": "one", "rootsPemFiles": ["roots.pem"], "privKeyPemFile": "key.pem"}]}`,
+			wantErr:  []string{"failed to parse config data"},
+		},
+		{
+			desc:     "unknown-enum-value",
+			contents: `logs { log_id: 1 ext_key_usages: TIME_STOMPING }`,
+			wantErr:  []string{"failed to parse config data"},
+		},
+		{
+			desc:     "empty",
+			contents: `rpc_deadline { seconds: 5 }`,
+			wantErr:  []string{"empty log config found"},
+		},
+		{
+			desc:     "malformed",
+			contents: `[{"LogID": "one"}]`,
+			wantErr:  []string{"failed to parse config data"},
+		},
+	}
+
+	for _, test := range tests {
+		f, err := ioutil.TempFile("", "ctfe-config")
+		if err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+		defer os.Remove(f.Name())
+		if _, err := f.WriteString(test.contents); err != nil {
+			t.Fatalf("Failed to write temp file: %v", err)
+		}
+		f.Close()
+
+		cfg, err := LogMultiConfigFromFile(f.Name())
+		checkConfigErrors(t, "LogMultiConfigFromFile("+test.desc+")", err, test.wantErr)
+		if err != nil {
+			continue
+		}
+		if got := len(cfg.Logs); got != test.wantLogs {
+			t.Errorf("LogMultiConfigFromFile(%s): got %d logs; want %d", test.desc, got, test.wantLogs)
+		}
+		if got := cfg.MaxGetEntriesAllowed; got != test.wantMaxGetEnt {
+			t.Errorf("LogMultiConfigFromFile(%s): MaxGetEntriesAllowed=%d; want %d", test.desc, got, test.wantMaxGetEnt)
+		}
+		if got := cfg.RPCDeadline; got != test.wantDeadline {
+			t.Errorf("LogMultiConfigFromFile(%s): RPCDeadline=%v; want %v", test.desc, got, test.wantDeadline)
+		}
+	}
+}
+
+func TestLogConfigFromFile(t *testing.T) {
+	// LogConfigFromFile just parses the legacy form, leaving validation to
+	// its callers.
+	f, err := ioutil.TempFile("", "ctfe-config")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`[{"LogID": 1, "Prefix": "one", "ExtKeyUsages": ["Nothing"]}]`); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	f.Close()
+
+	cfgs, err := LogConfigFromFile(f.Name())
+	if err != nil {
+		t.Fatalf("LogConfigFromFile()=_,%v; want _,nil", err)
+	}
+	if len(cfgs) != 1 || cfgs[0].Prefix != "one" {
+		t.Errorf("LogConfigFromFile()=%+v; want one log with Prefix one", cfgs)
+	}
+	checkConfigErrors(t, "Validate()", cfgs[0].Validate(), []string{
+		"unknown extended key usage: Nothing",
+		"need to specify RootsPEMFile",
+		"need to specify PrivKeyPEMFile",
+	})
+}
+
+// checkConfigErrors checks that err holds one error containing each of the
+// wanted strings, in order.
+func checkConfigErrors(t *testing.T, desc string, err error, want []string) {
+	if err == nil {
+		if len(want) > 0 {
+			t.Errorf("%s=nil; want errors containing %q", desc, want)
+		}
+		return
+	}
+	errs, ok := err.(ConfigErrors)
+	if !ok {
+		errs = ConfigErrors{err}
+	}
+	if len(errs) != len(want) {
+		t.Errorf("%s=%v; want %d errors containing %q", desc, err, len(want), want)
+		return
+	}
+	for i, e := range errs {
+		if !strings.Contains(e.Error(), want[i]) {
+			t.Errorf("%s: error[%d]=%v; want error containing %q", desc, i, e, want[i])
+		}
+	}
+}
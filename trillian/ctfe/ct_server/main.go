@@ -18,6 +18,7 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
@@ -29,21 +30,29 @@ import (
 	etcdnaming "github.com/coreos/etcd/clientv3/naming"
 	"github.com/golang/glog"
 	"github.com/google/certificate-transparency-go/trillian/ctfe"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
 	"github.com/google/certificate-transparency-go/trillian/util"
 	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
 	"github.com/google/trillian/monitoring/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/naming"
 )
 
+// defaultMaxGetEntries is the get-entries limit used unless the config or flags
+// override it.
+var defaultMaxGetEntries = ctfe.MaxGetEntriesAllowed
+
 // Global flags that affect all log instances.
 var (
 	httpEndpoint      = flag.String("http_endpoint", "localhost:6962", "Endpoint for HTTP (host:port)")
 	rpcBackendFlag    = flag.String("log_rpc_server", "localhost:8090", "Backend specification; comma-separated list or etcd service name (if --etcd_servers specified)")
-	rpcDeadlineFlag   = flag.Duration("rpc_deadline", time.Second*10, "Deadline for backend RPC requests")
-	logConfigFlag     = flag.String("log_config", "", "File holding log config in JSON")
-	maxGetEntriesFlag = flag.Int64("maxGetEntriesAllowed", 0, "Max number of entries we allow in a get-entries request (default 50)")
+	rpcDeadlineFlag   = flag.Duration("rpc_deadline", time.Second*10, "Deadline for backend RPC requests (unless set in the log config)")
+	logConfigFlag     = flag.String("log_config", "", "File holding log config as a text or JSON LogMultiConfig protobuf (or a legacy JSON list of LogConfig)")
+	logConfigPollFlag = flag.Duration("log_config_poll", time.Minute, "Interval between checks for changes to the log config and roots files (0 to only reload on SIGHUP)")
+	validateOnlyFlag  = flag.Bool("validate_only", false, "Check the log config (including the files it refers to) and exit")
+	maxGetEntriesFlag = flag.Int64("maxGetEntriesAllowed", 0, "Max number of entries we allow in a get-entries request (default 50, unless set in the log config)")
 	etcdServers       = flag.String("etcd_servers", "", "A comma-separated list of etcd servers")
 	etcdHTTPService   = flag.String("etcd_http_service", "trillian-ctfe-http", "Service name to announce our HTTP endpoint under")
 )
@@ -52,16 +61,22 @@ func main() {
 	flag.Parse()
 	ctx := context.Background()
 
-	if *maxGetEntriesFlag > 0 {
-		ctfe.MaxGetEntriesAllowed = *maxGetEntriesFlag
-	}
-
 	// Get log config from file before we start.
-	cfg, err := ctfe.LogConfigFromFile(*logConfigFlag)
+	cfg, err := ctfe.LogMultiConfigFromFile(*logConfigFlag)
+	if *validateOnlyFlag {
+		if err == nil {
+			err = checkLogFiles(cfg)
+		}
+		reportValidation(err)
+		return
+	}
 	if err != nil {
 		glog.Exitf("Failed to read log config: %v", err)
 	}
 
+	var rpcDeadline time.Duration
+	ctfe.MaxGetEntriesAllowed, rpcDeadline = serverOptions(cfg)
+
 	glog.CopyStandardLogTo("WARNING")
 	glog.Info("**** CT HTTP Server Starting ****")
 
@@ -101,15 +116,12 @@ func main() {
 	defer conn.Close()
 	client := trillian.NewTrillianLogClient(conn)
 
-	for _, c := range cfg {
-		handlers, err := c.SetUpInstance(client, *rpcDeadlineFlag, prometheus.MetricFactory{})
-		if err != nil {
-			glog.Exitf("Failed to set up log instance for %+v: %v", cfg, err)
-		}
-		for path, handler := range *handlers {
-			http.Handle(path, handler)
-		}
+	logs := ctfe.NewReloadingHandler(client, rpcDeadline, prometheus.MetricFactory{})
+	if err := logs.Reload(cfg.Logs); err != nil {
+		glog.Exitf("Failed to set up log instances: %v", err)
 	}
+	go watchConfig(logs, *logConfigFlag, cfg, *logConfigPollFlag)
+	http.Handle("/", logs)
 	http.Handle("/metrics", promhttp.Handler())
 
 	// Bring up the HTTP server and serve until we get a signal not to.
@@ -122,6 +134,119 @@ func main() {
 	glog.Flush()
 }
 
+// serverOptions returns the get-entries limit and the RPC deadline to use with
+// the given config: those set in the config, or else by the flags.
+func serverOptions(cfg *ctfe.LogMultiConfig) (int64, time.Duration) {
+	maxGetEntries := defaultMaxGetEntries
+	if cfg.MaxGetEntriesAllowed > 0 {
+		maxGetEntries = cfg.MaxGetEntriesAllowed
+	} else if *maxGetEntriesFlag > 0 {
+		maxGetEntries = *maxGetEntriesFlag
+	}
+	rpcDeadline := *rpcDeadlineFlag
+	if cfg.RPCDeadline > 0 {
+		rpcDeadline = cfg.RPCDeadline
+	}
+	return maxGetEntries, rpcDeadline
+}
+
+// watchConfig reloads the log config from filename whenever a SIGHUP is
+// received or, if interval is non-zero, whenever the config file or any of the
+// roots files that it refers to is modified; cfg is the config that is
+// initially loaded.  A config that fails to load is logged, and the previous
+// config remains in use.  Server-wide options in the config only take effect at
+// startup, so changes to them are logged as warnings.  It should be run as a
+// separate goroutine.
+func watchConfig(logs *ctfe.ReloadingHandler, filename string, cfg *ctfe.LogMultiConfig, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	maxGetEntries, rpcDeadline := serverOptions(cfg)
+	stamp := configStamp(filename, cfg.Logs)
+	for {
+		select {
+		case <-hup:
+			glog.Infof("SIGHUP received, reloading %s", filename)
+		case <-tick:
+			if configStamp(filename, cfg.Logs) == stamp {
+				continue
+			}
+			glog.Infof("Change detected, reloading %s", filename)
+		}
+		newCfg, err := ctfe.LogMultiConfigFromFile(filename)
+		if err == nil {
+			// Watch the roots files that the new config refers to, even if
+			// it fails to load, so that fixing them triggers another attempt.
+			cfg = newCfg
+			if m, d := serverOptions(newCfg); m != maxGetEntries || d != rpcDeadline {
+				glog.Warningf("Server-wide options in %s (MaxGetEntriesAllowed %d, RPCDeadline %v) differ from those in use (%d, %v), and only take effect on restart", filename, m, d, maxGetEntries, rpcDeadline)
+			}
+			err = logs.Reload(newCfg.Logs)
+		}
+		if err != nil {
+			glog.Errorf("Failed to reload log config, keeping version %d: %v", logs.Version(), err)
+		}
+		// Don't retry until something changes again.
+		stamp = configStamp(filename, cfg.Logs)
+	}
+}
+
+// configStamp summarizes the modification times of the log config file and of
+// the roots files referenced by cfg, so that changes to any of them are noticed.
+func configStamp(filename string, cfg []*configpb.LogConfig) string {
+	files := []string{filename}
+	for _, c := range cfg {
+		files = append(files, c.RootsPemFile...)
+	}
+	var stamp []string
+	for _, f := range files {
+		if fi, err := os.Stat(f); err == nil {
+			stamp = append(stamp, fi.ModTime().String())
+		} else {
+			stamp = append(stamp, err.Error())
+		}
+	}
+	return strings.Join(stamp, ",")
+}
+
+// checkLogFiles checks that the roots and keys that the logs in cfg refer to
+// can be loaded, returning all of the problems found.
+func checkLogFiles(cfg *ctfe.LogMultiConfig) error {
+	var errs ctfe.ConfigErrors
+	for i, c := range cfg.Logs {
+		if _, err := ctfe.SetUpInstance(c, nil, *rpcDeadlineFlag, monitoring.InertMetricFactory{}); err != nil {
+			errs = append(errs, fmt.Errorf("Logs[%d] (%q): %v", i, c.Prefix, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// reportValidation reports the outcome of --validate_only, listing each problem
+// found on a separate line, and exits with a non-zero status if there were any.
+func reportValidation(err error) {
+	if err == nil {
+		fmt.Printf("%s: OK\n", *logConfigFlag)
+		return
+	}
+	errs, ok := err.(ctfe.ConfigErrors)
+	if !ok {
+		errs = ctfe.ConfigErrors{err}
+	}
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", *logConfigFlag, err)
+	}
+	os.Exit(1)
+}
+
 // awaitSignal waits for standard termination signals, then runs the given
 // function; it should be run as a separate goroutine.
 func awaitSignal(doneFn func()) {
@@ -38,6 +38,7 @@ import (
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/monitoring"
+	"google.golang.org/grpc/codes"
 )
 
 const (
@@ -68,7 +69,19 @@ const (
 // MaxGetEntriesAllowed is the number of entries we allow in a get-entries request
 var MaxGetEntriesAllowed int64 = 50
 
-// EntrypointName identifies a CT entrypoint as defined in section 4 of RFC 6962.
+// GetLogMetadataPath is the path (below the log's prefix) of the get-log-metadata
+// entrypoint, which is not part of RFC 6962.
+const GetLogMetadataPath = "/ct/v1/get-log-metadata"
+
+// LogMetadataResponse is the JSON response to a get-log-metadata request.  The
+// NotAfter range is given as RFC 3339 timestamps, and is omitted if unset.
+type LogMetadataResponse struct {
+	NotAfterStart string `json:"not_after_start,omitempty"`
+	NotAfterLimit string `json:"not_after_limit,omitempty"`
+}
+
+// EntrypointName identifies a CT entrypoint as defined in section 4 of RFC 6962,
+// plus the get-log-metadata extension.
 type EntrypointName string
 
 // Constants for entrypoint names, as exposed in statistics/logging.
@@ -81,19 +94,24 @@ const (
 	GetEntriesName        = EntrypointName("GetEntries")
 	GetRootsName          = EntrypointName("GetRoots")
 	GetEntryAndProofName  = EntrypointName("GetEntryAndProof")
+	GetLogMetadataName    = EntrypointName("GetLogMetadata")
 )
 
 var (
-	// Metrics are all per-log (label "logid"), but may also be
+	// Metrics are mostly per-log (label "logid"), but may also be
 	// per-entrypoint (label "ep") or per-return-code (label "rc").
 	once             sync.Once
-	knownLogs        monitoring.Gauge     // logid => value (always 1.0)
+	knownLogs        monitoring.Gauge     // logid => value (1.0, or 0.0 once reloaded away)
 	lastSCTTimestamp monitoring.Gauge     // logid => value
 	lastSTHTimestamp monitoring.Gauge     // logid => value
 	lastSTHTreeSize  monitoring.Gauge     // logid => value
 	reqsCounter      monitoring.Counter   // logid, ep => value
 	rspsCounter      monitoring.Counter   // logid, ep, rc => value
 	rspLatency       monitoring.Histogram // logid, ep, rc => value
+	dedupedLeaves    monitoring.Counter   // logid, ep => value
+	// Per-server metrics, for the set of logs being served.
+	configVersion        monitoring.Gauge   // value
+	configReloadFailures monitoring.Counter // value
 )
 
 // setupMetrics initializes all the exported metrics.
@@ -106,10 +124,13 @@ func setupMetrics(mf monitoring.MetricFactory) {
 	reqsCounter = mf.NewCounter("http_reqs", "Number of requests", "logid", "ep")
 	rspsCounter = mf.NewCounter("http_rsps", "Number of responses", "logid", "ep", "rc")
 	rspLatency = mf.NewHistogram("http_latency", "Latency of responses in milliseconds", "logid", "ep", "rc")
+	dedupedLeaves = mf.NewCounter("deduped_leaves", "Number of submissions of already-logged leaves", "logid", "ep")
+	configVersion = mf.NewGauge("config_version", "Number of times the log config has been loaded")
+	configReloadFailures = mf.NewCounter("config_reload_failures", "Number of failed attempts to reload the log config")
 }
 
 // Entrypoints is a list of entrypoint names as exposed in statistics/logging.
-var Entrypoints = []EntrypointName{AddChainName, AddPreChainName, GetSTHName, GetSTHConsistencyName, GetProofByHashName, GetEntriesName, GetRootsName, GetEntryAndProofName}
+var Entrypoints = []EntrypointName{AddChainName, AddPreChainName, GetSTHName, GetSTHConsistencyName, GetProofByHashName, GetEntriesName, GetRootsName, GetEntryAndProofName, GetLogMetadataName}
 
 // PathHandlers maps from a path to the relevant AppHandler instance.
 type PathHandlers map[string]AppHandler
@@ -181,6 +202,25 @@ type CertValidationOpts struct {
 	rejectExpired bool
 	// extKeyUsages contains the list of EKUs to use during chain verification
 	extKeyUsages []x509.ExtKeyUsage
+	// notAfterStart (if set) is the earliest NotAfter accepted for a submitted certificate
+	notAfterStart *time.Time
+	// notAfterLimit (if set) is the point at or after which a submitted certificate's
+	// NotAfter is rejected; together with notAfterStart this allows temporal sharding
+	notAfterLimit *time.Time
+	// policy (if set) holds further admission rules for verified chains
+	policy ChainPolicy
+}
+
+// NewCertValidationOpts builds validation options based on parameters.
+func NewCertValidationOpts(trustedRoots *PEMCertPool, rejectExpired bool, extKeyUsages []x509.ExtKeyUsage, notAfterStart, notAfterLimit *time.Time, policy ChainPolicy) CertValidationOpts {
+	return CertValidationOpts{
+		trustedRoots:  trustedRoots,
+		rejectExpired: rejectExpired,
+		extKeyUsages:  extKeyUsages,
+		notAfterStart: notAfterStart,
+		notAfterLimit: notAfterLimit,
+		policy:        policy,
+	}
 }
 
 // LogContext holds information for a specific log instance.
@@ -205,23 +245,18 @@ type LogContext struct {
 }
 
 // NewLogContext creates a new instance of LogContext.
-func NewLogContext(logID int64, prefix string, trustedRoots *PEMCertPool, rejectExpired bool, extKeyUsages []x509.ExtKeyUsage, rpcClient trillian.TrillianLogClient, signer *crypto.Signer, rpcDeadline time.Duration, timeSource util.TimeSource, mf monitoring.MetricFactory) *LogContext {
+func NewLogContext(logID int64, prefix string, validationOpts CertValidationOpts, rpcClient trillian.TrillianLogClient, signer *crypto.Signer, rpcDeadline time.Duration, timeSource util.TimeSource, mf monitoring.MetricFactory) *LogContext {
 	ctx := &LogContext{
-		logID:       logID,
-		urlPrefix:   prefix,
-		LogPrefix:   fmt.Sprintf("%s{%d}", prefix, logID),
-		rpcClient:   rpcClient,
-		signer:      signer,
-		rpcDeadline: rpcDeadline,
-		TimeSource:  timeSource,
-		validationOpts: CertValidationOpts{
-			trustedRoots:  trustedRoots,
-			rejectExpired: rejectExpired,
-			extKeyUsages:  extKeyUsages,
-		},
+		logID:          logID,
+		urlPrefix:      prefix,
+		LogPrefix:      fmt.Sprintf("%s{%d}", prefix, logID),
+		validationOpts: validationOpts,
+		rpcClient:      rpcClient,
+		signer:         signer,
+		rpcDeadline:    rpcDeadline,
+		TimeSource:     timeSource,
 	}
 	once.Do(func() { setupMetrics(mf) })
-	knownLogs.Set(1.0, strconv.FormatInt(logID, 10))
 
 	return ctx
 }
@@ -244,6 +279,7 @@ func (c LogContext) Handlers(prefix string) PathHandlers {
 		prefix + ct.GetEntriesPath:        AppHandler{Context: c, Handler: getEntries, Name: GetEntriesName, Method: http.MethodGet},
 		prefix + ct.GetRootsPath:          AppHandler{Context: c, Handler: getRoots, Name: GetRootsName, Method: http.MethodGet},
 		prefix + ct.GetEntryAndProofPath:  AppHandler{Context: c, Handler: getEntryAndProof, Name: GetEntryAndProofName, Method: http.MethodGet},
+		prefix + GetLogMetadataPath:       AppHandler{Context: c, Handler: getLogMetadata, Name: GetLogMetadataName, Method: http.MethodGet},
 	}
 }
 
@@ -270,9 +306,9 @@ func parseBodyAsJSONChain(c LogContext, r *http.Request) (ct.AddChainRequest, er
 }
 
 // addChainInternal is called by add-chain and add-pre-chain as the logic involved in
-// processing these requests is almost identical
-// TODO(Martin2112): Doesn't properly handle duplicate submissions yet but the backend
-// needs this to be implemented before we can do it here
+// processing these requests is almost identical.  If the backend reports that the
+// chain has already been logged, the SCT is built from the existing leaf, so it
+// carries the timestamp of the original submission.
 func addChainInternal(ctx context.Context, c LogContext, w http.ResponseWriter, r *http.Request, isPrecert bool) (int, error) {
 	var makeLeafFn func(*x509.Certificate, *x509.Certificate, uint64) (*ct.MerkleTreeLeaf, error)
 	var method EntrypointName
@@ -328,8 +364,26 @@ func addChainInternal(ctx context.Context, c LogContext, w http.ResponseWriter,
 		return http.StatusInternalServerError, fmt.Errorf("unexpected QueueLeaves response leaf count: %d", len(rsp.QueuedLeaves))
 	}
 	queuedLeaf := rsp.QueuedLeaves[0]
+	isDup := false
+	if queuedLeaf.Status != nil {
+		switch code := codes.Code(queuedLeaf.Status.Code); code {
+		case codes.OK:
+		case codes.AlreadyExists:
+			isDup = true
+		default:
+			return http.StatusInternalServerError, fmt.Errorf("backend failed to queue leaf: %v %s", code, queuedLeaf.Status.Message)
+		}
+	}
+	if queuedLeaf.Leaf == nil {
+		return http.StatusInternalServerError, errors.New("missing leaf in QueueLeaves response")
+	}
+	if isDup {
+		glog.V(2).Infof("%s: %s <= duplicate of existing leaf", c.LogPrefix, method)
+		dedupedLeaves.Inc(strconv.FormatInt(c.logID, 10), string(method))
+	}
 
-	// Always use the returned leaf as the basis for an SCT.
+	// Always use the returned leaf as the basis for an SCT; for a duplicate
+	// this is the originally logged leaf, including its timestamp.
 	var loggedLeaf ct.MerkleTreeLeaf
 	if rest, err := tls.Unmarshal(queuedLeaf.Leaf.LeafValue, &loggedLeaf); err != nil {
 		return http.StatusInternalServerError, fmt.Errorf("failed to reconstruct MerkleTreeLeaf: %v", err)
@@ -350,7 +404,9 @@ func addChainInternal(ctx context.Context, c LogContext, w http.ResponseWriter,
 		return http.StatusInternalServerError, fmt.Errorf("failed to write response: %v", err)
 	}
 	glog.V(3).Infof("%s: %s <= SCT", c.LogPrefix, method)
-	lastSCTTimestamp.Set(float64(sct.Timestamp), strconv.FormatInt(c.logID, 10))
+	if !isDup {
+		lastSCTTimestamp.Set(float64(sct.Timestamp), strconv.FormatInt(c.logID, 10))
+	}
 
 	return http.StatusOK, nil
 }
@@ -604,6 +660,32 @@ func getRoots(ctx context.Context, c LogContext, w http.ResponseWriter, r *http.
 	return http.StatusOK, nil
 }
 
+// getLogMetadata describes the log's configuration that is of interest to
+// submitters, in particular the NotAfter range of a temporal shard.
+func getLogMetadata(ctx context.Context, c LogContext, w http.ResponseWriter, r *http.Request) (int, error) {
+	var rsp LogMetadataResponse
+	if start := c.validationOpts.notAfterStart; start != nil {
+		rsp.NotAfterStart = start.UTC().Format(time.RFC3339)
+	}
+	if limit := c.validationOpts.notAfterLimit; limit != nil {
+		rsp.NotAfterLimit = limit.UTC().Format(time.RFC3339)
+	}
+
+	w.Header().Set(contentTypeHeader, contentTypeJSON)
+	jsonData, err := json.Marshal(&rsp)
+	if err != nil {
+		return http.StatusInternalServerError, fmt.Errorf("failed to marshal get-log-metadata resp: %v because %v", rsp, err)
+	}
+
+	_, err = w.Write(jsonData)
+	if err != nil {
+		// Probably too late for this as headers might have been written but we don't know for sure
+		return http.StatusInternalServerError, fmt.Errorf("failed to write get-log-metadata resp: %v because %v", rsp, err)
+	}
+
+	return http.StatusOK, nil
+}
+
 // See RFC 6962 Section 4.8. This is mostly used for debug purposes rather than by normal
 // CT clients.
 func getEntryAndProof(ctx context.Context, c LogContext, w http.ResponseWriter, r *http.Request) (int, error) {
@@ -666,6 +748,10 @@ func verifyAddChain(c LogContext, req ct.AddChainRequest, w http.ResponseWriter,
 	// We already checked that the chain is not empty so can move on to verification
 	validPath, err := ValidateChain(req.Chain, c.validationOpts)
 	if err != nil {
+		if _, ok := err.(PolicyError); ok {
+			// The reason is intended for the submitter, so return it as-is.
+			return nil, err
+		}
 		// We rejected it because the cert failed checks or we could not find a path to a root etc.
 		// Lots of possible causes for errors
 		return nil, fmt.Errorf("chain failed to verify: %v because: %v", req, err)
@@ -112,7 +112,8 @@ func setupTest(t *testing.T, pemRoots []string, signer *crypto.Signer) handlerTe
 	}
 
 	info.client = mockclient.NewMockTrillianLogClient(info.mockCtrl)
-	info.c = *NewLogContext(0x42, "test", info.roots, false, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, info.client, signer, time.Millisecond*500, fakeTimeSource, monitoring.InertMetricFactory{})
+	validationOpts := NewCertValidationOpts(info.roots, false, []x509.ExtKeyUsage{x509.ExtKeyUsageAny}, nil, nil, nil)
+	info.c = *NewLogContext(0x42, "test", validationOpts, info.client, signer, time.Millisecond*500, fakeTimeSource, monitoring.InertMetricFactory{})
 
 	for _, pemRoot := range pemRoots {
 		if !info.roots.AppendCertsFromPEM([]byte(pemRoot)) {
@@ -131,6 +132,7 @@ func (info handlerTestInfo) getHandlers() map[string]AppHandler {
 		"get-entries":         {Context: info.c, Handler: getEntries, Name: "GetEntries", Method: http.MethodGet},
 		"get-roots":           {Context: info.c, Handler: getRoots, Name: "GetRoots", Method: http.MethodGet},
 		"get-entry-and-proof": {Context: info.c, Handler: getEntryAndProof, Name: "GetEntryAndProof", Method: http.MethodGet},
+		"get-log-metadata":    {Context: info.c, Handler: getLogMetadata, Name: "GetLogMetadata", Method: http.MethodGet},
 	}
 }
 
@@ -281,6 +283,44 @@ func TestGetRoots(t *testing.T) {
 	}
 }
 
+func TestGetLogMetadata(t *testing.T) {
+	start := time.Date(2017, 1, 1, 0, 0, 0, 0, time.UTC)
+	limit := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	var tests = []struct {
+		desc         string
+		start, limit *time.Time
+		want         string
+	}{
+		{desc: "unsharded", want: `{}`},
+		{desc: "start-only", start: &start, want: `{"not_after_start":"2017-01-01T00:00:00Z"}`},
+		{desc: "limit-only", limit: &limit, want: `{"not_after_limit":"2018-01-01T00:00:00Z"}`},
+		{desc: "shard", start: &start, limit: &limit, want: `{"not_after_start":"2017-01-01T00:00:00Z","not_after_limit":"2018-01-01T00:00:00Z"}`},
+	}
+
+	info := setupTest(t, nil, nil)
+	defer info.mockCtrl.Finish()
+	for _, test := range tests {
+		c := info.c
+		c.validationOpts.notAfterStart = test.start
+		c.validationOpts.notAfterLimit = test.limit
+		handler := AppHandler{Context: c, Handler: getLogMetadata, Name: "GetLogMetadata", Method: http.MethodGet}
+
+		req, err := http.NewRequest("GET", "http://example.com"+GetLogMetadataPath, nil)
+		if err != nil {
+			t.Fatalf("Failed to create request: %v", err)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if got, want := w.Code, http.StatusOK; got != want {
+			t.Errorf("http.Get(get-log-metadata)[%s]=%d; want %d", test.desc, got, want)
+			continue
+		}
+		if got := w.Body.String(); got != test.want {
+			t.Errorf("http.Get(get-log-metadata)[%s]=%s; want %s", test.desc, got, test.want)
+		}
+	}
+}
+
 func TestAddChain(t *testing.T) {
 	var tests = []struct {
 		descr  string
@@ -490,6 +530,72 @@ func TestAddPrechain(t *testing.T) {
 	}
 }
 
+func TestAddChainDuplicate(t *testing.T) {
+	var tests = []struct {
+		descr  string
+		status *status.Status
+		want   int
+		wantTS uint64
+	}{
+		{
+			descr:  "duplicate",
+			status: status.New(codes.AlreadyExists, "already exists"),
+			want:   http.StatusOK,
+			wantTS: fakeTimeMillis - 5000,
+		},
+		{
+			descr:  "queue-failure",
+			status: status.New(codes.ResourceExhausted, "too many leaves"),
+			want:   http.StatusInternalServerError,
+		},
+	}
+
+	signer, err := setupSigner(fakeSignature)
+	if err != nil {
+		t.Fatalf("Failed to create test signer: %v", err)
+	}
+
+	info := setupTest(t, []string{cttestonly.FakeCACertPEM}, signer)
+	defer info.mockCtrl.Finish()
+
+	chainPEMs := []string{cttestonly.LeafSignedByFakeIntermediateCertPEM, cttestonly.FakeIntermediateCertPEM, cttestonly.FakeCACertPEM}
+	for _, test := range tests {
+		pool := loadCertsIntoPoolOrDie(t, chainPEMs)
+		chain := createJSONChain(t, *pool)
+		merkleLeaf, err := buildV1MerkleTreeLeafForCert(pool.RawCertificates()[0], nil, fakeTimeMillis)
+		if err != nil {
+			t.Fatalf("Failed to build Merkle leaf: %v", err)
+		}
+		leaves := logLeavesForCert(t, pool.RawCertificates(), merkleLeaf, false)
+
+		// The backend returns the leaf that was logged by an earlier submission.
+		origLeaf, err := buildV1MerkleTreeLeafForCert(pool.RawCertificates()[0], nil, fakeTimeMillis-5000)
+		if err != nil {
+			t.Fatalf("Failed to build Merkle leaf: %v", err)
+		}
+		origLeaves := logLeavesForCert(t, pool.RawCertificates(), origLeaf, false)
+		rsp := trillian.QueueLeavesResponse{
+			QueuedLeaves: []*trillian.QueuedLogLeaf{{Leaf: origLeaves[0], Status: test.status.Proto()}},
+		}
+		info.client.EXPECT().QueueLeaves(deadlineMatcher(), &trillian.QueueLeavesRequest{LogId: 0x42, Leaves: leaves}).Return(&rsp, nil)
+
+		recorder := makeAddChainRequest(t, info.c, chain)
+		if recorder.Code != test.want {
+			t.Errorf("addChain(%s)=%d (body:%v); want %d", test.descr, recorder.Code, recorder.Body, test.want)
+			continue
+		}
+		if test.want == http.StatusOK {
+			var resp ct.AddChainResponse
+			if err := json.NewDecoder(recorder.Body).Decode(&resp); err != nil {
+				t.Fatalf("json.Decode(%s)=%v; want nil", recorder.Body.Bytes(), err)
+			}
+			if got, want := resp.Timestamp, test.wantTS; got != want {
+				t.Errorf("addChain(%s): resp.Timestamp=%d; want %d", test.descr, got, want)
+			}
+		}
+	}
+}
+
 func TestGetSTH(t *testing.T) {
 	var tests = []struct {
 		descr   string
@@ -19,32 +19,152 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/golang/protobuf/ptypes"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
 	"github.com/google/certificate-transparency-go/trillian/util"
 	"github.com/google/certificate-transparency-go/x509"
 	"github.com/google/trillian"
 	"github.com/google/trillian/crypto"
 	"github.com/google/trillian/crypto/keys"
 	"github.com/google/trillian/monitoring"
+
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
 )
 
-// LogConfig describes the configuration options for a log instance.
+// LogConfig describes the configuration options for a log instance, in the
+// legacy JSON form read by LogConfigFromFile; see configpb.LogConfig for the
+// form used by the CT personality, which this converts to.
 type LogConfig struct {
-	LogID           int64
-	Prefix          string
-	RootsPEMFile    []string
-	PrivKeyPEMFile  string
-	PrivKeyPassword string
+	LogID          int64
+	Prefix         string
+	RootsPEMFile   []string
+	PrivKeyPEMFile string
+	// The password for the private key can be given directly, or (to keep it
+	// out of the config) in a separate file; at most one of these may be set.
+	PrivKeyPassword     string
+	PrivKeyPasswordFile string
 	// The public key is included for the convenience of test tools (and obviously should
 	// match the private key above); it is not used by the CT personality.
 	PubKeyPEMFile string
 	RejectExpired bool
 	ExtKeyUsages  []string
+	// NotAfterStart and NotAfterLimit (RFC 3339 timestamps) optionally make the log a
+	// temporal shard, which only accepts certificates whose NotAfter is in the range
+	// [NotAfterStart, NotAfterLimit).
+	NotAfterStart string
+	NotAfterLimit string
+	// Policy optionally restricts the chains that the log accepts.
+	Policy *PolicyConfig
+	// RPCDeadline optionally overrides the server's deadline for backend RPC
+	// requests for this log, as a Go duration string (e.g. "5s").
+	RPCDeadline string
+}
+
+// Proto converts the LogConfig to its configpb form, reporting any values
+// that can't be converted as ConfigErrors.  The result is not validated.
+func (cfg LogConfig) Proto() (*configpb.LogConfig, error) {
+	pb, errs := cfg.toProto()
+	if len(errs) > 0 {
+		return nil, ConfigErrors(errs)
+	}
+	return pb, nil
+}
+
+// toProto converts as much of the LogConfig as it can, leaving unset any
+// fields that can't be converted, and returns the problems found.
+func (cfg LogConfig) toProto() (*configpb.LogConfig, []error) {
+	pb := configpb.LogConfig{
+		LogId:               cfg.LogID,
+		Prefix:              cfg.Prefix,
+		RootsPemFile:        cfg.RootsPEMFile,
+		PrivKeyPemFile:      cfg.PrivKeyPEMFile,
+		PrivKeyPassword:     cfg.PrivKeyPassword,
+		PrivKeyPasswordFile: cfg.PrivKeyPasswordFile,
+		PubKeyPemFile:       cfg.PubKeyPEMFile,
+		RejectExpired:       cfg.RejectExpired,
+	}
+	var errs []error
+	for _, kuStr := range cfg.ExtKeyUsages {
+		if ku, present := stringToKeyUsage[kuStr]; present {
+			pb.ExtKeyUsages = append(pb.ExtKeyUsages, ku)
+		} else {
+			errs = append(errs, fmt.Errorf("unknown extended key usage: %s", kuStr))
+		}
+	}
+
+	// The NotAfter range may instead be given by the deprecated fields of
+	// Policy, but not by both.
+	start, limit := cfg.NotAfterStart, cfg.NotAfterLimit
+	if p := cfg.Policy; p != nil && (len(p.NotAfterStart) > 0 || len(p.NotAfterLimit) > 0) {
+		if len(start) > 0 || len(limit) > 0 {
+			errs = append(errs, errors.New("specify the NotAfter range in LogConfig or (deprecated) in Policy, not both"))
+		}
+		start, limit = p.NotAfterStart, p.NotAfterLimit
+	}
+	var err error
+	if pb.NotAfterStart, err = parseOptionalTime(start); err != nil {
+		errs = append(errs, fmt.Errorf("invalid NotAfterStart: %v", err))
+	}
+	if pb.NotAfterLimit, err = parseOptionalTime(limit); err != nil {
+		errs = append(errs, fmt.Errorf("invalid NotAfterLimit: %v", err))
+	}
+
+	if cfg.Policy != nil {
+		if pb.Policy, err = cfg.Policy.proto(); err != nil {
+			errs = append(errs, fmt.Errorf("invalid Policy: %v", err))
+		}
+	}
+
+	if len(cfg.RPCDeadline) > 0 {
+		d, err := time.ParseDuration(cfg.RPCDeadline)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid RPCDeadline: %v", err))
+		} else {
+			pb.RpcDeadline = ptypes.DurationProto(d)
+		}
+	}
+	return &pb, errs
+}
+
+func parseOptionalTime(s string) (*tspb.Timestamp, error) {
+	if len(s) == 0 {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return nil, err
+	}
+	return ptypes.TimestampProto(t)
+}
+
+// Validate checks the LogConfig for problems that can be found without
+// reading the files that it refers to, and reports all of them as ConfigErrors.
+func (cfg LogConfig) Validate() error {
+	pb, errs := cfg.toProto()
+	_, logErrs := parseLogConfig(pb)
+	return newConfigErrors(append(errs, logErrs...))
+}
+
+// SetUpInstance converts the LogConfig to its configpb form, and sets up a log
+// instance for it as the function SetUpInstance does.
+func (cfg LogConfig) SetUpInstance(client trillian.TrillianLogClient, deadline time.Duration, mf monitoring.MetricFactory) (*PathHandlers, error) {
+	pb, errs := cfg.toProto()
+	if len(errs) > 0 {
+		// Report any problems with the rest of the config too.
+		_, logErrs := parseLogConfig(pb)
+		return nil, ConfigErrors(append(errs, logErrs...))
+	}
+	return SetUpInstance(pb, client, deadline, mf)
 }
 
 // LogConfigFromFile creates a slice of LogConfig options from the given
-// filename, which should contain JSON encoded configuration data.
+// filename, which should contain JSON encoded configuration data.  This is
+// the legacy form of configuration; LogMultiConfigFromFile also accepts it.
 func LogConfigFromFile(filename string) ([]LogConfig, error) {
 	if len(filename) == 0 {
 		return nil, errors.New("log config filename empty")
@@ -63,62 +183,238 @@ func LogConfigFromFile(filename string) ([]LogConfig, error) {
 	return cfg, nil
 }
 
-var stringToKeyUsage = map[string]x509.ExtKeyUsage{
-	"Any":                        x509.ExtKeyUsageAny,
-	"ServerAuth":                 x509.ExtKeyUsageServerAuth,
-	"ClientAuth":                 x509.ExtKeyUsageClientAuth,
-	"CodeSigning":                x509.ExtKeyUsageCodeSigning,
-	"EmailProtection":            x509.ExtKeyUsageEmailProtection,
-	"IPSECEndSystem":             x509.ExtKeyUsageIPSECEndSystem,
-	"IPSECTunnel":                x509.ExtKeyUsageIPSECTunnel,
-	"IPSECUser":                  x509.ExtKeyUsageIPSECUser,
-	"TimeStamping":               x509.ExtKeyUsageTimeStamping,
-	"OCSPSigning":                x509.ExtKeyUsageOCSPSigning,
-	"MicrosoftServerGatedCrypto": x509.ExtKeyUsageMicrosoftServerGatedCrypto,
-	"NetscapeServerGatedCrypto":  x509.ExtKeyUsageNetscapeServerGatedCrypto,
+// logSettings holds the values parsed from a configpb.LogConfig.
+type logSettings struct {
+	keyUsages     []x509.ExtKeyUsage
+	notAfterStart *time.Time
+	notAfterLimit *time.Time
+	policy        ChainPolicy
+	rpcDeadline   time.Duration // zero if not overridden
+}
+
+// parseLogConfig checks the config for problems that can be found without
+// reading the files that it refers to, and returns the parsed settings along
+// with all of the problems found.
+func parseLogConfig(cfg *configpb.LogConfig) (*logSettings, []error) {
+	var s logSettings
+	var errs []error
+	if cfg.LogId <= 0 {
+		errs = append(errs, fmt.Errorf("LogID must be positive, not %d", cfg.LogId))
+	}
+	if len(cfg.RootsPemFile) == 0 {
+		errs = append(errs, errors.New("need to specify RootsPEMFile"))
+	}
+	if len(cfg.PrivKeyPemFile) == 0 {
+		errs = append(errs, errors.New("need to specify PrivKeyPEMFile"))
+	}
+	if len(cfg.PrivKeyPassword) > 0 && len(cfg.PrivKeyPasswordFile) > 0 {
+		errs = append(errs, errors.New("specify at most one of PrivKeyPassword and PrivKeyPasswordFile"))
+	}
+
+	if len(cfg.ExtKeyUsages) > 0 {
+		for _, ku := range cfg.ExtKeyUsages {
+			if xku, present := keyUsages[ku]; present {
+				s.keyUsages = append(s.keyUsages, xku)
+			} else {
+				errs = append(errs, fmt.Errorf("unknown extended key usage: %v", ku))
+			}
+		}
+	} else {
+		s.keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
+	}
+
+	var err error
+	if s.notAfterStart, s.notAfterLimit, err = notAfterRange(cfg); err != nil {
+		errs = append(errs, err)
+	}
+
+	if cfg.Policy != nil {
+		p, err := NewAcceptancePolicy(cfg.Policy)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid Policy: %v", err))
+		} else {
+			s.policy = p
+		}
+	}
+
+	if cfg.RpcDeadline != nil {
+		if s.rpcDeadline, err = parseDeadline(cfg.RpcDeadline); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return &s, errs
+}
+
+// ValidateLogConfig checks the config for problems that can be found without
+// reading the files that it refers to, and reports all of them as
+// ConfigErrors.
+func ValidateLogConfig(cfg *configpb.LogConfig) error {
+	_, errs := parseLogConfig(cfg)
+	return newConfigErrors(errs)
+}
+
+func parseDeadline(pb *durpb.Duration) (time.Duration, error) {
+	d, err := ptypes.Duration(pb)
+	if err != nil {
+		return 0, fmt.Errorf("invalid RPCDeadline: %v", err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("RPCDeadline %v is not positive", d)
+	}
+	return d, nil
+}
+
+// notAfterRange parses the NotAfter range of the log; either end may be nil.
+func notAfterRange(cfg *configpb.LogConfig) (*time.Time, *time.Time, error) {
+	var start, limit *time.Time
+	if cfg.NotAfterStart != nil {
+		t, err := ptypes.Timestamp(cfg.NotAfterStart)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid NotAfterStart: %v", err)
+		}
+		start = &t
+	}
+	if cfg.NotAfterLimit != nil {
+		t, err := ptypes.Timestamp(cfg.NotAfterLimit)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid NotAfterLimit: %v", err)
+		}
+		limit = &t
+	}
+	if start != nil && limit != nil && !start.Before(*limit) {
+		return nil, nil, fmt.Errorf("NotAfterStart %v is not before NotAfterLimit %v", start, limit)
+	}
+	return start, limit, nil
+}
+
+// ValidateLogConfigs checks that a set of log configurations can be hosted by
+// a single server: the logs must have distinct IDs and prefixes, and the NotAfter
+// ranges of any temporal shards among them must not overlap.  All of the
+// problems found are reported, as ConfigErrors.  Problems with the individual
+// logs are left to ValidateLogConfig; in particular, logs with an invalid
+// NotAfter range are not checked for overlaps.
+func ValidateLogConfigs(cfgs []*configpb.LogConfig) error {
+	return newConfigErrors(validateLogConfigs(cfgs))
+}
+
+func validateLogConfigs(cfgs []*configpb.LogConfig) []error {
+	type shard struct {
+		prefix       string
+		start, limit *time.Time
+	}
+	var shards []shard
+	var errs []error
+	logIDs := make(map[int64]bool)
+	prefixes := make(map[string]bool)
+	for _, cfg := range cfgs {
+		if logIDs[cfg.LogId] {
+			errs = append(errs, fmt.Errorf("duplicate LogID %d", cfg.LogId))
+		}
+		logIDs[cfg.LogId] = true
+		prefix := strings.Trim(cfg.Prefix, "/")
+		if prefixes[prefix] {
+			errs = append(errs, fmt.Errorf("duplicate Prefix %q", cfg.Prefix))
+		}
+		prefixes[prefix] = true
+
+		start, limit, err := notAfterRange(cfg)
+		if err != nil || (start == nil && limit == nil) {
+			continue
+		}
+		for _, other := range shards {
+			// Unset ends are unbounded, so two ranges overlap unless one of them
+			// ends at or before the start of the other.
+			if (limit != nil && other.start != nil && !limit.After(*other.start)) ||
+				(other.limit != nil && start != nil && !other.limit.After(*start)) {
+				continue
+			}
+			errs = append(errs, fmt.Errorf("NotAfter range of log %q overlaps with that of log %q", cfg.Prefix, other.prefix))
+		}
+		shards = append(shards, shard{prefix: cfg.Prefix, start: start, limit: limit})
+	}
+	return errs
+}
+
+var stringToKeyUsage = map[string]configpb.ExtKeyUsage{
+	"Any":                        configpb.ExtKeyUsage_ANY,
+	"ServerAuth":                 configpb.ExtKeyUsage_SERVER_AUTH,
+	"ClientAuth":                 configpb.ExtKeyUsage_CLIENT_AUTH,
+	"CodeSigning":                configpb.ExtKeyUsage_CODE_SIGNING,
+	"EmailProtection":            configpb.ExtKeyUsage_EMAIL_PROTECTION,
+	"IPSECEndSystem":             configpb.ExtKeyUsage_IPSEC_END_SYSTEM,
+	"IPSECTunnel":                configpb.ExtKeyUsage_IPSEC_TUNNEL,
+	"IPSECUser":                  configpb.ExtKeyUsage_IPSEC_USER,
+	"TimeStamping":               configpb.ExtKeyUsage_TIME_STAMPING,
+	"OCSPSigning":                configpb.ExtKeyUsage_OCSP_SIGNING,
+	"MicrosoftServerGatedCrypto": configpb.ExtKeyUsage_MICROSOFT_SERVER_GATED_CRYPTO,
+	"NetscapeServerGatedCrypto":  configpb.ExtKeyUsage_NETSCAPE_SERVER_GATED_CRYPTO,
+}
+
+var keyUsages = map[configpb.ExtKeyUsage]x509.ExtKeyUsage{
+	configpb.ExtKeyUsage_ANY:                           x509.ExtKeyUsageAny,
+	configpb.ExtKeyUsage_SERVER_AUTH:                   x509.ExtKeyUsageServerAuth,
+	configpb.ExtKeyUsage_CLIENT_AUTH:                   x509.ExtKeyUsageClientAuth,
+	configpb.ExtKeyUsage_CODE_SIGNING:                  x509.ExtKeyUsageCodeSigning,
+	configpb.ExtKeyUsage_EMAIL_PROTECTION:              x509.ExtKeyUsageEmailProtection,
+	configpb.ExtKeyUsage_IPSEC_END_SYSTEM:              x509.ExtKeyUsageIPSECEndSystem,
+	configpb.ExtKeyUsage_IPSEC_TUNNEL:                  x509.ExtKeyUsageIPSECTunnel,
+	configpb.ExtKeyUsage_IPSEC_USER:                    x509.ExtKeyUsageIPSECUser,
+	configpb.ExtKeyUsage_TIME_STAMPING:                 x509.ExtKeyUsageTimeStamping,
+	configpb.ExtKeyUsage_OCSP_SIGNING:                  x509.ExtKeyUsageOCSPSigning,
+	configpb.ExtKeyUsage_MICROSOFT_SERVER_GATED_CRYPTO: x509.ExtKeyUsageMicrosoftServerGatedCrypto,
+	configpb.ExtKeyUsage_NETSCAPE_SERVER_GATED_CRYPTO:  x509.ExtKeyUsageNetscapeServerGatedCrypto,
 }
 
 // SetUpInstance sets up a log instance that uses the specified client to communicate
-// with the Trillian RPC back end.
-func (cfg LogConfig) SetUpInstance(client trillian.TrillianLogClient, deadline time.Duration, mf monitoring.MetricFactory) (*PathHandlers, error) {
+// with the Trillian RPC back end.  The given deadline for RPC requests is used unless
+// the config overrides it.  The log is reported as known in the metrics.
+func SetUpInstance(cfg *configpb.LogConfig, client trillian.TrillianLogClient, deadline time.Duration, mf monitoring.MetricFactory) (*PathHandlers, error) {
+	handlers, err := setUpInstance(cfg, client, deadline, mf)
+	if err != nil {
+		return nil, err
+	}
+	knownLogs.Set(1.0, strconv.FormatInt(cfg.LogId, 10))
+	return handlers, nil
+}
+
+// setUpInstance sets up a log instance as for SetUpInstance, without reporting
+// it as known; a ReloadingHandler does that once it serves the log.
+func setUpInstance(cfg *configpb.LogConfig, client trillian.TrillianLogClient, deadline time.Duration, mf monitoring.MetricFactory) (*PathHandlers, error) {
 	// Check config validity.
-	if len(cfg.RootsPEMFile) == 0 {
-		return nil, errors.New("need to specify RootsPEMFile")
+	settings, errs := parseLogConfig(cfg)
+	if len(errs) > 0 {
+		return nil, newConfigErrors(errs)
 	}
-	if len(cfg.PrivKeyPEMFile) == 0 {
-		return nil, errors.New("need to specify PrivKeyPEMFile")
+	if settings.rpcDeadline > 0 {
+		deadline = settings.rpcDeadline
 	}
 
 	// Load the trusted roots
 	roots := NewPEMCertPool()
-	for _, pemFile := range cfg.RootsPEMFile {
+	for _, pemFile := range cfg.RootsPemFile {
 		if err := roots.AppendCertsFromPEMFile(pemFile); err != nil {
 			return nil, fmt.Errorf("failed to read trusted roots: %v", err)
 		}
 	}
 
 	// Load the private key for this log.
-	key, err := keys.NewFromPrivatePEMFile(cfg.PrivKeyPEMFile, cfg.PrivKeyPassword)
+	password := cfg.PrivKeyPassword
+	if len(cfg.PrivKeyPasswordFile) > 0 {
+		data, err := ioutil.ReadFile(cfg.PrivKeyPasswordFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read PrivKeyPasswordFile: %v", err)
+		}
+		password = strings.TrimRight(string(data), "\r\n")
+	}
+	key, err := keys.NewFromPrivatePEMFile(cfg.PrivKeyPemFile, password)
 	if err != nil {
 		return nil, fmt.Errorf("failed to load private key: %v", err)
 	}
 	signer := crypto.NewSHA256Signer(key)
 
-	var keyUsages []x509.ExtKeyUsage
-	if len(cfg.ExtKeyUsages) > 0 {
-		for _, kuStr := range cfg.ExtKeyUsages {
-			if ku, present := stringToKeyUsage[kuStr]; present {
-				keyUsages = append(keyUsages, ku)
-			} else {
-				return nil, fmt.Errorf("unknown extended key usage: %s", kuStr)
-			}
-		}
-	} else {
-		keyUsages = []x509.ExtKeyUsage{x509.ExtKeyUsageAny}
-	}
-
 	// Create and register the handlers using the RPC client we just set up
-	ctx := NewLogContext(cfg.LogID, cfg.Prefix, roots, cfg.RejectExpired, keyUsages, client, signer, deadline, new(util.SystemTimeSource), mf)
+	validationOpts := NewCertValidationOpts(roots, cfg.RejectExpired, settings.keyUsages, settings.notAfterStart, settings.notAfterLimit, settings.policy)
+	ctx := NewLogContext(cfg.LogId, cfg.Prefix, validationOpts, client, signer, deadline, new(util.SystemTimeSource), mf)
 
 	handlers := ctx.Handlers(cfg.Prefix)
 	return &handlers, nil
@@ -15,11 +15,17 @@
 package ctfe
 
 import (
+	"io/ioutil"
+	"os"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
 	"github.com/google/trillian/monitoring"
+
+	durpb "github.com/golang/protobuf/ptypes/duration"
+	tspb "github.com/golang/protobuf/ptypes/timestamp"
 )
 
 func TestSetUpInstance(t *testing.T) {
@@ -136,6 +142,162 @@ func TestSetUpInstance(t *testing.T) {
 			},
 			errStr: "unknown extended key usage",
 		},
+		{
+			desc: "valid-policy",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				Policy: &PolicyConfig{
+					MaxChainLength: 4,
+					KeyAlgorithms:  []string{"RSA", "ECDSA"},
+				},
+			},
+		},
+		{
+			desc: "invalid-policy",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				Policy:          &PolicyConfig{KeyAlgorithms: []string{"RSA", "GOST"}},
+			},
+			errStr: "invalid Policy",
+		},
+		{
+			desc: "valid-shard",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				NotAfterStart:   "2017-01-01T00:00:00Z",
+				NotAfterLimit:   "2018-01-01T00:00:00Z",
+			},
+		},
+		{
+			desc: "invalid-shard-start",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				NotAfterStart:   "2017-01-01",
+			},
+			errStr: "invalid NotAfterStart",
+		},
+		{
+			desc: "invalid-shard-range",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				NotAfterStart:   "2018-01-01T00:00:00Z",
+				NotAfterLimit:   "2017-01-01T00:00:00Z",
+			},
+			errStr: "not before NotAfterLimit",
+		},
+		{
+			desc: "deprecated-policy-shard",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				Policy: &PolicyConfig{
+					NotAfterStart: "2017-01-01T00:00:00Z",
+					NotAfterLimit: "2018-01-01T00:00:00Z",
+				},
+			},
+		},
+		{
+			desc: "invalid-deprecated-policy-shard",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				Policy:          &PolicyConfig{NotAfterLimit: "2018"},
+			},
+			errStr: "invalid NotAfterLimit",
+		},
+		{
+			desc: "shard-in-log-and-policy",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				NotAfterStart:   "2017-01-01T00:00:00Z",
+				Policy:          &PolicyConfig{NotAfterLimit: "2018-01-01T00:00:00Z"},
+			},
+			errStr: "not both",
+		},
+		{
+			desc: "valid-rpc-deadline",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				RPCDeadline:     "2s",
+			},
+		},
+		{
+			desc: "invalid-rpc-deadline",
+			cfg: LogConfig{
+				LogID:           1,
+				Prefix:          "log",
+				RootsPEMFile:    []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:  "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword: "dirk",
+				RPCDeadline:     "2",
+			},
+			errStr: "invalid RPCDeadline",
+		},
+		{
+			desc: "two-passwords",
+			cfg: LogConfig{
+				LogID:               1,
+				Prefix:              "log",
+				RootsPEMFile:        []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:      "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPassword:     "dirk",
+				PrivKeyPasswordFile: "../testdata/bogus.password",
+			},
+			errStr: "at most one of PrivKeyPassword and PrivKeyPasswordFile",
+		},
+		{
+			desc: "missing-password-file",
+			cfg: LogConfig{
+				LogID:               1,
+				Prefix:              "log",
+				RootsPEMFile:        []string{"../testdata/fake-ca.cert"},
+				PrivKeyPEMFile:      "../testdata/ct-http-server.privkey.pem",
+				PrivKeyPasswordFile: "../testdata/bogus.password",
+			},
+			errStr: "failed to read PrivKeyPasswordFile",
+		},
+		{
+			desc: "several-problems",
+			cfg: LogConfig{
+				Prefix:       "log",
+				ExtKeyUsages: []string{"Any "},
+			},
+			errStr: "unknown extended key usage: Any ; LogID must be positive, not 0; need to specify RootsPEMFile; need to specify PrivKeyPEMFile",
+		},
 	}
 
 	for _, test := range tests {
@@ -154,3 +316,183 @@ func TestSetUpInstance(t *testing.T) {
 	}
 
 }
+
+func TestSetUpInstancePasswordFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "ctfe-password")
+	if err != nil {
+		t.Fatalf("Failed to create temp file: %v", err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("dirk\n"); err != nil {
+		t.Fatalf("Failed to write temp file: %v", err)
+	}
+	f.Close()
+
+	cfg := LogConfig{
+		LogID:               1,
+		Prefix:              "log",
+		RootsPEMFile:        []string{"../testdata/fake-ca.cert"},
+		PrivKeyPEMFile:      "../testdata/ct-http-server.privkey.pem",
+		PrivKeyPasswordFile: f.Name(),
+	}
+	if _, err := cfg.SetUpInstance(nil, time.Second, monitoring.InertMetricFactory{}); err != nil {
+		t.Errorf("SetUpInstance()=_,%v; want _,nil", err)
+	}
+}
+
+func TestValidateLogConfig(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		cfg    configpb.LogConfig
+		errStr string
+	}{
+		{
+			desc: "valid",
+			cfg: configpb.LogConfig{
+				LogId:          1,
+				Prefix:         "log",
+				RootsPemFile:   []string{"roots.pem"},
+				PrivKeyPemFile: "key.pem",
+				ExtKeyUsages:   []configpb.ExtKeyUsage{configpb.ExtKeyUsage_SERVER_AUTH},
+				NotAfterStart:  &tspb.Timestamp{Seconds: 1483228800},
+				NotAfterLimit:  &tspb.Timestamp{Seconds: 1514764800},
+				Policy:         &configpb.PolicyConfig{KeyAlgorithms: []configpb.KeyAlgorithm{configpb.KeyAlgorithm_ECDSA}},
+				RpcDeadline:    &durpb.Duration{Seconds: 5},
+			},
+		},
+		{
+			desc: "several-problems",
+			cfg: configpb.LogConfig{
+				LogId:          1,
+				Prefix:         "log",
+				RootsPemFile:   []string{"roots.pem"},
+				PrivKeyPemFile: "key.pem",
+				ExtKeyUsages:   []configpb.ExtKeyUsage{99},
+				NotAfterStart:  &tspb.Timestamp{Seconds: 1514764800},
+				NotAfterLimit:  &tspb.Timestamp{Seconds: 1483228800},
+				Policy:         &configpb.PolicyConfig{ForbiddenExtensions: []*configpb.ObjectIdentifier{{Arc: []int32{2}}}},
+				RpcDeadline:    &durpb.Duration{Seconds: -5},
+			},
+			errStr: "unknown extended key usage: 99; NotAfterStart 2018-01-01 00:00:00 +0000 UTC is not before NotAfterLimit 2017-01-01 00:00:00 +0000 UTC; invalid Policy: invalid ForbiddenExtensions: OID [2] too short; RPCDeadline -5s is not positive",
+		},
+	}
+
+	for _, test := range tests {
+		err := ValidateLogConfig(&test.cfg)
+		if err != nil {
+			if test.errStr == "" {
+				t.Errorf("ValidateLogConfig(%v)=%v; want nil", test.desc, err)
+			} else if !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("ValidateLogConfig(%v)=%v; want err containing %q", test.desc, err, test.errStr)
+			}
+			continue
+		}
+		if test.errStr != "" {
+			t.Errorf("ValidateLogConfig(%v)=nil; want err containing %q", test.desc, test.errStr)
+		}
+	}
+}
+
+func TestValidateLogConfigs(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		cfgs   []LogConfig
+		errStr string
+	}{
+		{
+			desc: "unsharded",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "one"},
+				{LogID: 2, Prefix: "two"},
+			},
+		},
+		{
+			desc: "shards",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log2017", NotAfterStart: "2017-01-01T00:00:00Z", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log2018", NotAfterStart: "2018-01-01T00:00:00Z", NotAfterLimit: "2019-01-01T00:00:00Z"},
+				{LogID: 3, Prefix: "log2019+", NotAfterStart: "2019-01-01T00:00:00Z"},
+				{LogID: 4, Prefix: "log-old", NotAfterLimit: "2017-01-01T00:00:00Z"},
+				{LogID: 5, Prefix: "unsharded"},
+			},
+		},
+		{
+			desc: "duplicate-log-id",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "one"},
+				{LogID: 1, Prefix: "two"},
+			},
+			errStr: "duplicate LogID",
+		},
+		{
+			desc: "duplicate-prefix",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log"},
+				{LogID: 2, Prefix: "/log/"},
+			},
+			errStr: "duplicate Prefix",
+		},
+		{
+			desc: "invalid-range-ignored",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log2017", NotAfterStart: "2017-01-01T00:00:00Z", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log", NotAfterStart: "2018-01-01T00:00:00Z", NotAfterLimit: "2017-01-01T00:00:00Z"},
+			},
+		},
+		{
+			desc: "overlapping-shards",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log2017", NotAfterStart: "2017-01-01T00:00:00Z", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log2018", NotAfterStart: "2017-12-31T00:00:00Z", NotAfterLimit: "2019-01-01T00:00:00Z"},
+			},
+			errStr: "overlaps",
+		},
+		{
+			desc: "overlapping-open-shards",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log2017", NotAfterStart: "2017-01-01T00:00:00Z", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log-new", NotAfterStart: "2016-01-01T00:00:00Z"},
+			},
+			errStr: "overlaps",
+		},
+		{
+			desc: "overlapping-deprecated-policy-shards",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log2017", NotAfterStart: "2017-01-01T00:00:00Z", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log2018", Policy: &PolicyConfig{NotAfterStart: "2017-12-31T00:00:00Z"}},
+			},
+			errStr: "overlaps",
+		},
+		{
+			desc: "overlapping-unbounded-shards",
+			cfgs: []LogConfig{
+				{LogID: 1, Prefix: "log-old", NotAfterLimit: "2018-01-01T00:00:00Z"},
+				{LogID: 2, Prefix: "log-older", NotAfterLimit: "2017-01-01T00:00:00Z"},
+			},
+			errStr: "overlaps",
+		},
+	}
+
+	for _, test := range tests {
+		var cfgs []*configpb.LogConfig
+		for _, cfg := range test.cfgs {
+			pb, err := cfg.Proto()
+			if err != nil {
+				t.Fatalf("(%v).Proto()=_,%v; want _,nil", test.desc, err)
+			}
+			cfgs = append(cfgs, pb)
+		}
+		err := ValidateLogConfigs(cfgs)
+		if err != nil {
+			if test.errStr == "" {
+				t.Errorf("ValidateLogConfigs(%v)=%v; want nil", test.desc, err)
+			} else if !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("ValidateLogConfigs(%v)=%v; want err containing %q", test.desc, err, test.errStr)
+			}
+			continue
+		}
+		if test.errStr != "" {
+			t.Errorf("ValidateLogConfigs(%v)=nil; want err containing %q", test.desc, test.errStr)
+		}
+	}
+}
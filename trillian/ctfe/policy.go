@@ -0,0 +1,257 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+	"github.com/google/certificate-transparency-go/trillian/util"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+// ChainPolicy is implemented by admission rules that a log applies to a
+// submitted chain, beyond requiring that it chains to a trusted root.
+type ChainPolicy interface {
+	// CheckChain returns a PolicyError if the log should not accept the
+	// chain, which starts with the submitted (pre-)certificate and ends
+	// with a trusted root.
+	CheckChain(chain []*x509.Certificate) error
+}
+
+// PolicyError indicates that a chain was rejected by a log's admission rules
+// (its NotAfter range or its ChainPolicy); the reason is returned to the
+// submitter.
+type PolicyError struct {
+	Reason string
+}
+
+func (e PolicyError) Error() string {
+	return "chain rejected by log policy: " + e.Reason
+}
+
+// PolicyConfig is the legacy JSON form of a log's configpb.PolicyConfig, as
+// held in a LogConfig.  Fields left empty impose no restriction.
+type PolicyConfig struct {
+	// NotAfterStart and NotAfterLimit are deprecated: use the fields of the
+	// same name in LogConfig instead.  They are still accepted for configs
+	// written before the NotAfter range moved there, and are used as the
+	// log's NotAfter range if LogConfig doesn't specify one.
+	NotAfterStart string
+	NotAfterLimit string
+	// MaxChainLength limits the number of certificates in the validated
+	// chain, including the submitted certificate and the trusted root.
+	MaxChainLength int
+	// RejectSubjects holds regular expressions; unexpired certificates whose
+	// subject common name or any DNS name matches one of them are rejected.
+	// This keeps long-lived test certificates (e.g. for revoked.example.com)
+	// out of the log while they could still be presented to clients; once
+	// they have expired they are accepted like any other certificate.
+	RejectSubjects []string
+	// RequiredExtensions and ForbiddenExtensions hold OIDs (in dotted form)
+	// of extensions that the submitted certificate must or must not have.
+	RequiredExtensions  []string
+	ForbiddenExtensions []string
+	// KeyAlgorithms lists the acceptable public key algorithms for the
+	// submitted certificate, from "RSA", "DSA", "ECDSA" and "Ed25519".
+	KeyAlgorithms []string
+}
+
+var stringToKeyAlgorithm = map[string]configpb.KeyAlgorithm{
+	"RSA":     configpb.KeyAlgorithm_RSA,
+	"DSA":     configpb.KeyAlgorithm_DSA,
+	"ECDSA":   configpb.KeyAlgorithm_ECDSA,
+	"Ed25519": configpb.KeyAlgorithm_ED25519,
+}
+
+var keyAlgorithms = map[configpb.KeyAlgorithm]x509.PublicKeyAlgorithm{
+	configpb.KeyAlgorithm_RSA:     x509.RSA,
+	configpb.KeyAlgorithm_DSA:     x509.DSA,
+	configpb.KeyAlgorithm_ECDSA:   x509.ECDSA,
+	configpb.KeyAlgorithm_ED25519: x509.Ed25519,
+}
+
+// proto converts the PolicyConfig to its configpb form.  The deprecated
+// NotAfter range is not included, as it belongs in the configpb.LogConfig.
+func (cfg PolicyConfig) proto() (*configpb.PolicyConfig, error) {
+	pb := configpb.PolicyConfig{
+		MaxChainLength: int32(cfg.MaxChainLength),
+		RejectSubjects: cfg.RejectSubjects,
+	}
+	var err error
+	if pb.RequiredExtensions, err = parseOIDs(cfg.RequiredExtensions); err != nil {
+		return nil, fmt.Errorf("invalid RequiredExtensions: %v", err)
+	}
+	if pb.ForbiddenExtensions, err = parseOIDs(cfg.ForbiddenExtensions); err != nil {
+		return nil, fmt.Errorf("invalid ForbiddenExtensions: %v", err)
+	}
+	for _, algStr := range cfg.KeyAlgorithms {
+		alg, ok := stringToKeyAlgorithm[algStr]
+		if !ok {
+			return nil, fmt.Errorf("unknown key algorithm: %s", algStr)
+		}
+		pb.KeyAlgorithms = append(pb.KeyAlgorithms, alg)
+	}
+	return &pb, nil
+}
+
+func parseOIDs(strs []string) ([]*configpb.ObjectIdentifier, error) {
+	var oids []*configpb.ObjectIdentifier
+	for _, s := range strs {
+		var oid configpb.ObjectIdentifier
+		for _, part := range strings.Split(s, ".") {
+			n, err := strconv.ParseInt(part, 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("malformed OID %q", s)
+			}
+			oid.Arc = append(oid.Arc, int32(n))
+		}
+		oids = append(oids, &oid)
+	}
+	return oids, nil
+}
+
+// AcceptancePolicy is a ChainPolicy that applies a fixed set of rules to
+// the submitted certificate and its chain.  Zero-valued fields impose no
+// restriction.
+type AcceptancePolicy struct {
+	MaxChainLength      int
+	RejectSubjects      []*regexp.Regexp
+	RequiredExtensions  []asn1.ObjectIdentifier
+	ForbiddenExtensions []asn1.ObjectIdentifier
+	KeyAlgorithms       []x509.PublicKeyAlgorithm
+	// TimeSource determines whether a certificate has expired, for
+	// RejectSubjects; if nil, the system time is used.
+	TimeSource util.TimeSource
+}
+
+// NewAcceptancePolicy creates an AcceptancePolicy from its configuration.
+func NewAcceptancePolicy(cfg *configpb.PolicyConfig) (*AcceptancePolicy, error) {
+	var p AcceptancePolicy
+	var err error
+	if cfg.MaxChainLength < 0 {
+		return nil, fmt.Errorf("negative MaxChainLength %d", cfg.MaxChainLength)
+	}
+	p.MaxChainLength = int(cfg.MaxChainLength)
+	for _, s := range cfg.RejectSubjects {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RejectSubjects pattern %q: %v", s, err)
+		}
+		p.RejectSubjects = append(p.RejectSubjects, re)
+	}
+	if p.RequiredExtensions, err = oidsFromProto(cfg.RequiredExtensions); err != nil {
+		return nil, fmt.Errorf("invalid RequiredExtensions: %v", err)
+	}
+	if p.ForbiddenExtensions, err = oidsFromProto(cfg.ForbiddenExtensions); err != nil {
+		return nil, fmt.Errorf("invalid ForbiddenExtensions: %v", err)
+	}
+	for _, alg := range cfg.KeyAlgorithms {
+		a, ok := keyAlgorithms[alg]
+		if !ok {
+			return nil, fmt.Errorf("unknown key algorithm: %v", alg)
+		}
+		p.KeyAlgorithms = append(p.KeyAlgorithms, a)
+	}
+	return &p, nil
+}
+
+func oidsFromProto(pbs []*configpb.ObjectIdentifier) ([]asn1.ObjectIdentifier, error) {
+	var oids []asn1.ObjectIdentifier
+	for _, pb := range pbs {
+		var oid asn1.ObjectIdentifier
+		for _, arc := range pb.Arc {
+			if arc < 0 {
+				return nil, fmt.Errorf("OID %v has negative arc", pb.Arc)
+			}
+			oid = append(oid, int(arc))
+		}
+		if len(oid) < 2 {
+			return nil, fmt.Errorf("OID %v too short", pb.Arc)
+		}
+		oids = append(oids, oid)
+	}
+	return oids, nil
+}
+
+// CheckChain implements ChainPolicy.
+func (p *AcceptancePolicy) CheckChain(chain []*x509.Certificate) error {
+	cert := chain[0]
+	if p.MaxChainLength > 0 && len(chain) > p.MaxChainLength {
+		return PolicyError{fmt.Sprintf("chain length %d exceeds maximum of %d", len(chain), p.MaxChainLength)}
+	}
+	if len(p.RejectSubjects) > 0 && p.now().Before(cert.NotAfter) {
+		for _, re := range p.RejectSubjects {
+			for _, name := range append([]string{cert.Subject.CommonName}, cert.DNSNames...) {
+				if re.MatchString(name) {
+					return PolicyError{fmt.Sprintf("unexpired certificate name %q is not accepted", name)}
+				}
+			}
+		}
+	}
+	for _, oid := range p.RequiredExtensions {
+		if !hasExtension(cert, oid) {
+			return PolicyError{fmt.Sprintf("certificate lacks required extension %v", oid)}
+		}
+	}
+	for _, oid := range p.ForbiddenExtensions {
+		if hasExtension(cert, oid) {
+			return PolicyError{fmt.Sprintf("certificate has forbidden extension %v", oid)}
+		}
+	}
+	if len(p.KeyAlgorithms) > 0 {
+		found := false
+		for _, alg := range p.KeyAlgorithms {
+			if cert.PublicKeyAlgorithm == alg {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return PolicyError{fmt.Sprintf("certificate public key algorithm %s is not accepted", keyAlgorithmName(cert.PublicKeyAlgorithm))}
+		}
+	}
+	return nil
+}
+
+func (p *AcceptancePolicy) now() time.Time {
+	if p.TimeSource == nil {
+		return time.Now()
+	}
+	return p.TimeSource.Now()
+}
+
+func hasExtension(cert *x509.Certificate, oid asn1.ObjectIdentifier) bool {
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oid) {
+			return true
+		}
+	}
+	return false
+}
+
+func keyAlgorithmName(alg x509.PublicKeyAlgorithm) string {
+	for pb, a := range keyAlgorithms {
+		if a == alg {
+			return pb.String()
+		}
+	}
+	return fmt.Sprintf("unknown(%d)", int(alg))
+}
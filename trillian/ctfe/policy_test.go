@@ -0,0 +1,286 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/testonly"
+	"github.com/google/certificate-transparency-go/trillian/util"
+	"github.com/google/certificate-transparency-go/x509"
+)
+
+func oids(arcs ...[]int32) []*configpb.ObjectIdentifier {
+	var pbs []*configpb.ObjectIdentifier
+	for _, arc := range arcs {
+		pbs = append(pbs, &configpb.ObjectIdentifier{Arc: arc})
+	}
+	return pbs
+}
+
+var (
+	basicConstraintsOID = []int32{2, 5, 29, 19}
+	poisonOID           = []int32{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+)
+
+func TestNewAcceptancePolicy(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		cfg    configpb.PolicyConfig
+		errStr string
+	}{
+		{desc: "empty", cfg: configpb.PolicyConfig{}},
+		{
+			desc: "full",
+			cfg: configpb.PolicyConfig{
+				MaxChainLength:      4,
+				RejectSubjects:      []string{`^revoked\.example\.com$`},
+				RequiredExtensions:  oids([]int32{2, 5, 29, 17}),
+				ForbiddenExtensions: oids(poisonOID),
+				KeyAlgorithms:       []configpb.KeyAlgorithm{configpb.KeyAlgorithm_RSA, configpb.KeyAlgorithm_ECDSA, configpb.KeyAlgorithm_ED25519},
+			},
+		},
+		{
+			desc:   "negative-length",
+			cfg:    configpb.PolicyConfig{MaxChainLength: -1},
+			errStr: "negative MaxChainLength",
+		},
+		{
+			desc:   "bad-regexp",
+			cfg:    configpb.PolicyConfig{RejectSubjects: []string{"(unclosed"}},
+			errStr: "invalid RejectSubjects",
+		},
+		{
+			desc:   "bad-required-oid",
+			cfg:    configpb.PolicyConfig{RequiredExtensions: oids([]int32{2, 5, -1, 17})},
+			errStr: "invalid RequiredExtensions",
+		},
+		{
+			desc:   "short-forbidden-oid",
+			cfg:    configpb.PolicyConfig{ForbiddenExtensions: oids([]int32{2})},
+			errStr: "invalid ForbiddenExtensions",
+		},
+		{
+			desc:   "unknown-key-algorithm",
+			cfg:    configpb.PolicyConfig{KeyAlgorithms: []configpb.KeyAlgorithm{configpb.KeyAlgorithm_ECDSA, configpb.KeyAlgorithm_UNKNOWN_KEY_ALGORITHM}},
+			errStr: "unknown key algorithm",
+		},
+	}
+
+	for _, test := range tests {
+		_, err := NewAcceptancePolicy(&test.cfg)
+		if err != nil {
+			if test.errStr == "" {
+				t.Errorf("NewAcceptancePolicy(%v)=_,%v; want _,nil", test.desc, err)
+			} else if !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("NewAcceptancePolicy(%v)=_,%v; want err containing %q", test.desc, err, test.errStr)
+			}
+			continue
+		}
+		if test.errStr != "" {
+			t.Errorf("NewAcceptancePolicy(%v)=_,nil; want err containing %q", test.desc, test.errStr)
+		}
+	}
+}
+
+func TestPolicyConfigProto(t *testing.T) {
+	var tests = []struct {
+		desc   string
+		cfg    PolicyConfig
+		want   *configpb.PolicyConfig
+		errStr string
+	}{
+		{desc: "empty", cfg: PolicyConfig{}, want: &configpb.PolicyConfig{}},
+		{
+			desc: "full",
+			cfg: PolicyConfig{
+				MaxChainLength:      4,
+				RejectSubjects:      []string{`^revoked\.example\.com$`},
+				RequiredExtensions:  []string{"2.5.29.19"},
+				ForbiddenExtensions: []string{"1.3.6.1.4.1.11129.2.4.3"},
+				KeyAlgorithms:       []string{"RSA", "ECDSA", "Ed25519"},
+			},
+			want: &configpb.PolicyConfig{
+				MaxChainLength:      4,
+				RejectSubjects:      []string{`^revoked\.example\.com$`},
+				RequiredExtensions:  oids(basicConstraintsOID),
+				ForbiddenExtensions: oids(poisonOID),
+				KeyAlgorithms:       []configpb.KeyAlgorithm{configpb.KeyAlgorithm_RSA, configpb.KeyAlgorithm_ECDSA, configpb.KeyAlgorithm_ED25519},
+			},
+		},
+		{
+			desc:   "bad-required-oid",
+			cfg:    PolicyConfig{RequiredExtensions: []string{"2.5.x.17"}},
+			errStr: "invalid RequiredExtensions",
+		},
+		{
+			desc:   "bad-forbidden-oid",
+			cfg:    PolicyConfig{ForbiddenExtensions: []string{"2.5.-1.17"}},
+			errStr: "invalid ForbiddenExtensions",
+		},
+		{
+			desc:   "unknown-key-algorithm",
+			cfg:    PolicyConfig{KeyAlgorithms: []string{"ECDSA", "ecdsa"}},
+			errStr: "unknown key algorithm: ecdsa",
+		},
+	}
+
+	for _, test := range tests {
+		got, err := test.cfg.proto()
+		if err != nil {
+			if test.errStr == "" {
+				t.Errorf("(%v).proto()=_,%v; want _,nil", test.desc, err)
+			} else if !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("(%v).proto()=_,%v; want err containing %q", test.desc, err, test.errStr)
+			}
+			continue
+		}
+		if test.errStr != "" {
+			t.Errorf("(%v).proto()=_,nil; want err containing %q", test.desc, test.errStr)
+			continue
+		}
+		if !proto.Equal(got, test.want) {
+			t.Errorf("(%v).proto()=%v; want %v", test.desc, got, test.want)
+		}
+	}
+}
+
+func TestCheckChain(t *testing.T) {
+	// The leaf has an ECDSA key, a subject CN of *.google.com (plus many SANs)
+	// and a Basic Constraints extension, and expires at 2019-07-12 14:26:44
+	// UTC; the chain has 3 certs.
+	chain := []*x509.Certificate{
+		pemToCert(t, testonly.LeafSignedByFakeIntermediateCertPEM),
+		pemToCert(t, testonly.FakeIntermediateCertPEM),
+		pemToCert(t, testonly.FakeCACertPEM),
+	}
+
+	unexpired := time.Date(2018, 1, 1, 0, 0, 0, 0, time.UTC)
+	expired := time.Date(2019, 7, 12, 14, 26, 44, 0, time.UTC)
+
+	var tests = []struct {
+		desc   string
+		cfg    configpb.PolicyConfig
+		now    time.Time // defaults to unexpired
+		errStr string
+	}{
+		{desc: "no-restrictions", cfg: configpb.PolicyConfig{}},
+		{
+			desc: "all-satisfied",
+			cfg: configpb.PolicyConfig{
+				MaxChainLength:      3,
+				RejectSubjects:      []string{`^revoked\.example\.com$`},
+				RequiredExtensions:  oids(basicConstraintsOID),
+				ForbiddenExtensions: oids(poisonOID),
+				KeyAlgorithms:       []configpb.KeyAlgorithm{configpb.KeyAlgorithm_RSA, configpb.KeyAlgorithm_ECDSA},
+			},
+		},
+		{
+			desc:   "chain-too-long",
+			cfg:    configpb.PolicyConfig{MaxChainLength: 2},
+			errStr: "exceeds maximum of 2",
+		},
+		{
+			desc:   "rejected-common-name",
+			cfg:    configpb.PolicyConfig{RejectSubjects: []string{`^\*\.google\.com$`}},
+			errStr: `"*.google.com" is not accepted`,
+		},
+		{
+			desc:   "rejected-dns-name",
+			cfg:    configpb.PolicyConfig{RejectSubjects: []string{`^youtu\.be$`}},
+			errStr: `"youtu.be" is not accepted`,
+		},
+		{
+			desc: "rejected-name-expired",
+			cfg:  configpb.PolicyConfig{RejectSubjects: []string{`^\*\.google\.com$`, `^youtu\.be$`}},
+			now:  expired,
+		},
+		{
+			desc:   "other-rules-expired",
+			cfg:    configpb.PolicyConfig{ForbiddenExtensions: oids(basicConstraintsOID)},
+			now:    expired,
+			errStr: "has forbidden extension",
+		},
+		{
+			desc:   "missing-extension",
+			cfg:    configpb.PolicyConfig{RequiredExtensions: oids([]int32{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2})},
+			errStr: "lacks required extension",
+		},
+		{
+			desc:   "forbidden-extension",
+			cfg:    configpb.PolicyConfig{ForbiddenExtensions: oids(basicConstraintsOID)},
+			errStr: "has forbidden extension",
+		},
+		{
+			desc:   "wrong-key-algorithm",
+			cfg:    configpb.PolicyConfig{KeyAlgorithms: []configpb.KeyAlgorithm{configpb.KeyAlgorithm_RSA, configpb.KeyAlgorithm_ED25519}},
+			errStr: "algorithm ECDSA is not accepted",
+		},
+	}
+
+	for _, test := range tests {
+		p, err := NewAcceptancePolicy(&test.cfg)
+		if err != nil {
+			t.Errorf("NewAcceptancePolicy(%v)=_,%v; want _,nil", test.desc, err)
+			continue
+		}
+		now := test.now
+		if now.IsZero() {
+			now = unexpired
+		}
+		p.TimeSource = util.NewFixedTimeSource(now)
+		err = p.CheckChain(chain)
+		if err != nil {
+			if test.errStr == "" {
+				t.Errorf("CheckChain(%v)=%v; want nil", test.desc, err)
+			} else if !strings.Contains(err.Error(), test.errStr) {
+				t.Errorf("CheckChain(%v)=%v; want err containing %q", test.desc, err, test.errStr)
+			}
+			if _, ok := err.(PolicyError); !ok {
+				t.Errorf("CheckChain(%v)=%T; want PolicyError", test.desc, err)
+			}
+			continue
+		}
+		if test.errStr != "" {
+			t.Errorf("CheckChain(%v)=nil; want err containing %q", test.desc, test.errStr)
+		}
+	}
+}
+
+func TestValidateChainPolicy(t *testing.T) {
+	fakeCARoots := NewPEMCertPool()
+	if !fakeCARoots.AppendCertsFromPEM([]byte(testonly.FakeCACertPEM)) {
+		t.Fatal("failed to load fake root")
+	}
+	policy, err := NewAcceptancePolicy(&configpb.PolicyConfig{KeyAlgorithms: []configpb.KeyAlgorithm{configpb.KeyAlgorithm_RSA}})
+	if err != nil {
+		t.Fatalf("NewAcceptancePolicy()=_,%v; want _,nil", err)
+	}
+	validateOpts := CertValidationOpts{
+		trustedRoots: fakeCARoots,
+		extKeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		policy:       policy,
+	}
+
+	chain := pemsToDERChain(t, []string{testonly.LeafSignedByFakeIntermediateCertPEM, testonly.FakeIntermediateCertPEM})
+	_, err = ValidateChain(chain, validateOpts)
+	if _, ok := err.(PolicyError); !ok {
+		t.Errorf("ValidateChain()=_,%v; want PolicyError", err)
+	}
+}
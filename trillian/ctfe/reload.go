@@ -0,0 +1,120 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+	"github.com/google/trillian"
+	"github.com/google/trillian/monitoring"
+)
+
+// ReloadingHandler is an http.Handler that serves a set of log instances,
+// which can be replaced while the server is running.  Each request is
+// dispatched to the handlers that were current when it arrived, so in-flight
+// requests are not disturbed by a reload.
+type ReloadingHandler struct {
+	client   trillian.TrillianLogClient
+	deadline time.Duration
+	mf       monitoring.MetricFactory
+
+	// mux holds the current *http.ServeMux.
+	mux atomic.Value
+
+	// mu serializes reloads, and guards the fields below.
+	mu      sync.Mutex
+	version int64
+	logIDs  map[int64]bool
+}
+
+// NewReloadingHandler creates a ReloadingHandler that serves nothing until
+// Reload is called.  The log instances it sets up use the given client to
+// communicate with the Trillian RPC back end.
+func NewReloadingHandler(client trillian.TrillianLogClient, deadline time.Duration, mf monitoring.MetricFactory) *ReloadingHandler {
+	once.Do(func() { setupMetrics(mf) })
+	h := &ReloadingHandler{client: client, deadline: deadline, mf: mf}
+	h.mux.Store(http.NewServeMux())
+	return h
+}
+
+// ServeHTTP implements http.Handler.
+func (h *ReloadingHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.Load().(*http.ServeMux).ServeHTTP(w, r)
+}
+
+// Reload sets up log instances for the given configs (re-reading their roots
+// and keys), and then switches to serving them in place of the previous set.
+// If the configs are invalid, either individually or as a set (see
+// ValidateLogConfigs), an error is returned and the previous set of logs
+// continues to be served.
+func (h *ReloadingHandler) Reload(cfgs []*configpb.LogConfig) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := h.reload(cfgs); err != nil {
+		configReloadFailures.Inc()
+		return err
+	}
+	return nil
+}
+
+func (h *ReloadingHandler) reload(cfgs []*configpb.LogConfig) error {
+	if err := ValidateLogConfigs(cfgs); err != nil {
+		return err
+	}
+	mux := http.NewServeMux()
+	logIDs := make(map[int64]bool)
+	for _, cfg := range cfgs {
+		handlers, err := setUpInstance(cfg, h.client, h.deadline, h.mf)
+		if err != nil {
+			return fmt.Errorf("failed to set up log instance for %q: %v", cfg.Prefix, err)
+		}
+		for path, handler := range *handlers {
+			mux.Handle(path, handler)
+		}
+		logIDs[cfg.LogId] = true
+	}
+
+	h.mux.Store(mux)
+	// Only now are the new logs served, and the removed ones not.
+	for logID := range logIDs {
+		knownLogs.Set(1.0, strconv.FormatInt(logID, 10))
+	}
+	for logID := range h.logIDs {
+		if !logIDs[logID] {
+			knownLogs.Set(0.0, strconv.FormatInt(logID, 10))
+		}
+	}
+	h.logIDs = logIDs
+	h.version++
+	configVersion.Set(float64(h.version))
+	glog.Infof("Serving config version %d with %d logs", h.version, len(cfgs))
+	return nil
+}
+
+// Version returns the number of times that a set of log configs has been
+// successfully loaded.
+func (h *ReloadingHandler) Version() int64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.version
+}
@@ -0,0 +1,161 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ctfe
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/google/certificate-transparency-go/trillian/ctfe/configpb"
+	"github.com/google/trillian/monitoring"
+)
+
+// logConfigForTest returns the config of a log that can be set up, whose
+// NotAfterStart is set if notAfterStart (an RFC 3339 timestamp) is non-empty.
+func logConfigForTest(t *testing.T, logID int64, prefix, notAfterStart string) *configpb.LogConfig {
+	start, err := parseOptionalTime(notAfterStart)
+	if err != nil {
+		t.Fatalf("Failed to parse %q: %v", notAfterStart, err)
+	}
+	return &configpb.LogConfig{
+		LogId:           logID,
+		Prefix:          prefix,
+		RootsPemFile:    []string{"../testdata/fake-ca.cert"},
+		PrivKeyPemFile:  "../testdata/ct-http-server.privkey.pem",
+		PrivKeyPassword: "dirk",
+		NotAfterStart:   start,
+	}
+}
+
+func TestReloadingHandler(t *testing.T) {
+	h := NewReloadingHandler(nil, time.Second, monitoring.InertMetricFactory{})
+	badRoots := logConfigForTest(t, 4, "four", "")
+	badRoots.RootsPemFile = []string{"../testdata/bogus.cert"}
+
+	var tests = []struct {
+		desc        string
+		cfgs        []*configpb.LogConfig
+		wantErr     bool
+		wantVersion int64
+		// Expected responses to get-log-metadata for each prefix.
+		want map[string]string
+		// Expected value of the known_logs metric for each log ID.
+		wantKnown map[int64]float64
+	}{
+		{
+			desc:        "initial",
+			cfgs:        []*configpb.LogConfig{logConfigForTest(t, 1, "one", "2017-01-01T00:00:00Z")},
+			wantVersion: 1,
+			want: map[string]string{
+				"one": `{"not_after_start":"2017-01-01T00:00:00Z"}`,
+				"two": "",
+			},
+			wantKnown: map[int64]float64{1: 1.0, 2: 0.0},
+		},
+		{
+			desc: "add-log",
+			cfgs: []*configpb.LogConfig{
+				logConfigForTest(t, 1, "one", "2018-01-01T00:00:00Z"),
+				logConfigForTest(t, 2, "two", ""),
+			},
+			wantVersion: 2,
+			want: map[string]string{
+				"one": `{"not_after_start":"2018-01-01T00:00:00Z"}`,
+				"two": `{}`,
+			},
+			wantKnown: map[int64]float64{1: 1.0, 2: 1.0},
+		},
+		{
+			// The log that is set up before the failure isn't served, so
+			// isn't reported as known.
+			desc: "invalid-log",
+			cfgs: []*configpb.LogConfig{
+				logConfigForTest(t, 1, "one", ""),
+				logConfigForTest(t, 3, "three", ""),
+				badRoots,
+			},
+			wantErr:     true,
+			wantVersion: 2,
+			want: map[string]string{
+				"one":   `{"not_after_start":"2018-01-01T00:00:00Z"}`,
+				"two":   `{}`,
+				"three": "",
+			},
+			wantKnown: map[int64]float64{1: 1.0, 2: 1.0, 3: 0.0},
+		},
+		{
+			desc: "duplicate-prefix",
+			cfgs: []*configpb.LogConfig{
+				logConfigForTest(t, 1, "one", ""),
+				logConfigForTest(t, 2, "one", ""),
+			},
+			wantErr:     true,
+			wantVersion: 2,
+			want: map[string]string{
+				"one": `{"not_after_start":"2018-01-01T00:00:00Z"}`,
+				"two": `{}`,
+			},
+		},
+		{
+			desc:        "remove-log",
+			cfgs:        []*configpb.LogConfig{logConfigForTest(t, 2, "two", "")},
+			wantVersion: 3,
+			want: map[string]string{
+				"one": "",
+				"two": `{}`,
+			},
+			wantKnown: map[int64]float64{1: 0.0, 2: 1.0},
+		},
+	}
+
+	for _, test := range tests {
+		err := h.Reload(test.cfgs)
+		if gotErr := err != nil; gotErr != test.wantErr {
+			t.Errorf("Reload(%s)=%v; want err? %v", test.desc, err, test.wantErr)
+		}
+		if got := h.Version(); got != test.wantVersion {
+			t.Errorf("Reload(%s): Version()=%d; want %d", test.desc, got, test.wantVersion)
+		}
+		for logID, want := range test.wantKnown {
+			if got := knownLogs.Value(strconv.FormatInt(logID, 10)); got != want {
+				t.Errorf("Reload(%s): known_logs{logid=%d}=%v; want %v", test.desc, logID, got, want)
+			}
+		}
+		for prefix, want := range test.want {
+			req, err := http.NewRequest("GET", "http://example.com/"+prefix+GetLogMetadataPath, nil)
+			if err != nil {
+				t.Fatalf("Failed to create request: %v", err)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			if want == "" {
+				if got := w.Code; got != http.StatusNotFound {
+					t.Errorf("Reload(%s): http.Get(%s)=%d; want %d", test.desc, prefix, got, http.StatusNotFound)
+				}
+				continue
+			}
+			if got := w.Code; got != http.StatusOK {
+				t.Errorf("Reload(%s): http.Get(%s)=%d; want %d", test.desc, prefix, got, http.StatusOK)
+				continue
+			}
+			if got := w.Body.String(); got != want {
+				t.Errorf("Reload(%s): http.Get(%s)=%s; want %s", test.desc, prefix, got, want)
+			}
+		}
+	}
+}
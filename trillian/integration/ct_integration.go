@@ -198,10 +198,10 @@ func RunCTIntegrationForLog(cfg ctfe.LogConfig, servers, testdir string, mmd tim
 	fmt.Printf("%s: Got STH(time=%q, size=%d): roothash=%x\n", cfg.Prefix, timeFromMS(sth2.Timestamp), sth2.TreeSize, sth2.SHA256RootHash)
 
 	// Stage 4: get a consistency proof from size 1-> size 2.
-	proof12, err := pool.Next().GetSTHConsistency(ctx, 1, 2)
+	proof12, err := pool.Next().VerifySTHConsistency(ctx, sth1, sth2)
 	stats.done(ctfe.GetSTHConsistencyName, 200)
 	if err != nil {
-		return fmt.Errorf("got GetSTHConsistency(1, 2)=(nil,%v); want (_,nil)", err)
+		return fmt.Errorf("got VerifySTHConsistency(sth1, sth2)=(nil,%v); want (_,nil)", err)
 	}
 	//                 sth2
 	//                 / \
@@ -213,9 +213,6 @@ func RunCTIntegrationForLog(cfg ctfe.LogConfig, servers, testdir string, mmd tim
 	if len(proof12) != 1 {
 		return fmt.Errorf("len(proof12)=%d; want 1", len(proof12))
 	}
-	if err := checkCTConsistencyProof(sth1, sth2, proof12); err != nil {
-		return fmt.Errorf("got CheckCTConsistencyProof(sth1,sth2,proof12)=%v; want nil", err)
-	}
 	if err := stats.check(cfg, servers); err != nil {
 		return fmt.Errorf("unexpected stats check: %v", err)
 	}
@@ -262,15 +259,12 @@ func RunCTIntegrationForLog(cfg ctfe.LogConfig, servers, testdir string, mmd tim
 	fmt.Printf("%s: Got STH(time=%q, size=%d): roothash=%x\n", cfg.Prefix, timeFromMS(sthN.Timestamp), sthN.TreeSize, sthN.SHA256RootHash)
 
 	// Stage 7: get a consistency proof from 2->(1+N).
-	proof2N, err := pool.Next().GetSTHConsistency(ctx, 2, uint64(treeSize))
+	proof2N, err := pool.Next().VerifySTHConsistency(ctx, sth2, sthN)
 	stats.done(ctfe.GetSTHConsistencyName, 200)
 	if err != nil {
-		return fmt.Errorf("got GetSTHConsistency(2, %d)=(nil,%v); want (_,nil)", treeSize, err)
+		return fmt.Errorf("got VerifySTHConsistency(sth2, sthN)=(nil,%v); want (_,nil)", err)
 	}
 	fmt.Printf("%s: Proof size 2->%d: %x\n", cfg.Prefix, treeSize, proof2N)
-	if err := checkCTConsistencyProof(sth2, sthN, proof2N); err != nil {
-		return fmt.Errorf("got CheckCTConsistencyProof(sth2,sthN,proof2N)=%v; want nil", err)
-	}
 
 	// Stage 8: get entries [1, N] (start at 1 to skip int-ca.cert)
 	entries, err := pool.Next().GetEntries(ctx, 1, int64(count))
@@ -528,12 +522,6 @@ func awaitTreeSize(ctx context.Context, logClient *client.LogClient, size uint64
 	return sth, nil
 }
 
-// checkCTConsistencyProof checks the given consistency proof.
-func checkCTConsistencyProof(sth1, sth2 *ct.SignedTreeHead, proof [][]byte) error {
-	return Verifier.VerifyConsistencyProof(int64(sth1.TreeSize), int64(sth2.TreeSize),
-		sth1.SHA256RootHash[:], sth2.SHA256RootHash[:], proof)
-}
-
 // makePrecertChain builds a precert chain based from the given cert chain and cert, converting and
 // re-signing relative to the given issuer.
 func makePrecertChain(chain []ct.ASN1Cert, cert, issuer *x509.Certificate, signer crypto.Signer) ([]ct.ASN1Cert, []byte, error) {
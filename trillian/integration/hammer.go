@@ -341,12 +341,9 @@ func (s *hammerState) getSTHConsistency(ctx context.Context) error {
 		return errSkip{}
 	}
 
-	proof, err := s.client().GetSTHConsistency(ctx, s.sth[which].TreeSize, sthNow.TreeSize)
+	proof, err := s.client().VerifySTHConsistency(ctx, s.sth[which], sthNow)
 	if err != nil {
-		return fmt.Errorf("failed to get-sth-consistency(%d, %d): %v", s.sth[which].TreeSize, sthNow.TreeSize, err)
-	}
-	if err := checkCTConsistencyProof(s.sth[which], sthNow, proof); err != nil {
-		return fmt.Errorf("get-sth-consistency(%d, %d) proof check failed: %v", s.sth[which].TreeSize, sthNow.TreeSize, err)
+		return fmt.Errorf("failed to verify get-sth-consistency(%d, %d): %v", s.sth[which].TreeSize, sthNow.TreeSize, err)
 	}
 	glog.V(2).Infof("%s: Got STH consistency proof (size=%d => %d) len %d",
 		s.cfg.LogCfg.Prefix, s.sth[which].TreeSize, sthNow.TreeSize, len(proof))
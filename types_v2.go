@@ -0,0 +1,437 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/tls"
+)
+
+///////////////////////////////////////////////////////////////////////////////
+// The following structures represent those outlined in RFC 9162 (Certificate
+// Transparency Version 2.0); any section numbers mentioned refer to that RFC.
+///////////////////////////////////////////////////////////////////////////////
+
+// VersionedTransType represents the VersionedTransType enum from section 4.5:
+//
+//	enum {
+//	    reserved(0),
+//	    x509_entry_v2(1), precert_entry_v2(2),
+//	    x509_sct_v2(3), precert_sct_v2(4),
+//	    signed_tree_head_v2(5), consistency_proof_v2(6),
+//	    inclusion_proof_v2(7),
+//	    (65535)
+//	} VersionedTransType;
+type VersionedTransType tls.Enum // tls:"maxval:65535"
+
+// VersionedTransType constants from section 4.5.
+const (
+	X509EntryV2VersionedType        VersionedTransType = 1
+	PrecertEntryV2VersionedType     VersionedTransType = 2
+	X509SCTV2VersionedType          VersionedTransType = 3
+	PrecertSCTV2VersionedType       VersionedTransType = 4
+	SignedTreeHeadV2VersionedType   VersionedTransType = 5
+	ConsistencyProofV2VersionedType VersionedTransType = 6
+	InclusionProofV2VersionedType   VersionedTransType = 7
+)
+
+func (v VersionedTransType) String() string {
+	switch v {
+	case X509EntryV2VersionedType:
+		return "X509EntryV2"
+	case PrecertEntryV2VersionedType:
+		return "PrecertEntryV2"
+	case X509SCTV2VersionedType:
+		return "X509SCTV2"
+	case PrecertSCTV2VersionedType:
+		return "PrecertSCTV2"
+	case SignedTreeHeadV2VersionedType:
+		return "SignedTreeHeadV2"
+	case ConsistencyProofV2VersionedType:
+		return "ConsistencyProofV2"
+	case InclusionProofV2VersionedType:
+		return "InclusionProofV2"
+	default:
+		return fmt.Sprintf("UnknownVersionedTransType(%d)", v)
+	}
+}
+
+// LogIDV2 holds a log's ID (section 4.4), which is the contents of the
+// DER encoding of an OID, excluding the ASN.1 tag and length bytes.
+//
+//	opaque LogID<2..127>;
+type LogIDV2 struct {
+	Value []byte `tls:"minlen:2,maxlen:127"`
+}
+
+// LogIDV2FromOID returns the LogIDV2 corresponding to an OID.
+func LogIDV2FromOID(oid asn1.ObjectIdentifier) (LogIDV2, error) {
+	der, err := asn1.Marshal(oid)
+	if err != nil {
+		return LogIDV2{}, fmt.Errorf("failed to marshal OID: %v", err)
+	}
+	// The encoding is tag (1 byte), length (1 byte, as contents are short)
+	// then contents.
+	if len(der) < 2 || int(der[1]) != len(der)-2 {
+		return LogIDV2{}, fmt.Errorf("OID %v too long for LogID", oid)
+	}
+	id := LogIDV2{Value: der[2:]}
+	if len(id.Value) < 2 || len(id.Value) > 127 {
+		return LogIDV2{}, fmt.Errorf("OID %v encodes to %d bytes; LogID needs 2-127", oid, len(id.Value))
+	}
+	return id, nil
+}
+
+// OID returns the OID held in the LogIDV2.
+func (l LogIDV2) OID() (asn1.ObjectIdentifier, error) {
+	if len(l.Value) < 2 || len(l.Value) > 127 {
+		return nil, fmt.Errorf("LogID has invalid length %d", len(l.Value))
+	}
+	der := append([]byte{asn1.TagOID, byte(len(l.Value))}, l.Value...)
+	var oid asn1.ObjectIdentifier
+	if rest, err := asn1.Unmarshal(der, &oid); err != nil {
+		return nil, fmt.Errorf("failed to parse LogID: %v", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("trailing data (%d bytes) after LogID", len(rest))
+	}
+	return oid, nil
+}
+
+func (l LogIDV2) String() string {
+	oid, err := l.OID()
+	if err != nil {
+		return fmt.Sprintf("InvalidLogID(%x)", l.Value)
+	}
+	return oid.String()
+}
+
+// ExtensionType represents the ExtensionType enum from section 4.6; no
+// values are defined yet.
+//
+//	enum { reserved(65535), (65535) } ExtensionType;
+type ExtensionType tls.Enum // tls:"maxval:65535"
+
+// Extension represents an SCT or STH extension (section 4.6).
+type Extension struct {
+	ExtensionType ExtensionType `tls:"maxval:65535"`
+	ExtensionData []byte        `tls:"minlen:0,maxlen:65535"`
+}
+
+// NodeHash holds the hash of a node in the Merkle tree (section 4.9).
+//
+//	opaque NodeHash<32..2^8-1>;
+type NodeHash struct {
+	Value []byte `tls:"minlen:32,maxlen:255"`
+}
+
+// TimestampedCertificateEntryDataV2 holds a certificate or precertificate
+// log entry, as the data of an x509_entry_v2 or precert_entry_v2 TransItem
+// (section 4.7).
+type TimestampedCertificateEntryDataV2 struct {
+	Timestamp      uint64
+	IssuerKeyHash  []byte      `tls:"minlen:32,maxlen:255"`
+	TBSCertificate []byte      `tls:"minlen:1,maxlen:16777215"` // DER-encoded TBSCertificate
+	SCTExtensions  []Extension `tls:"minlen:0,maxlen:65535"`
+}
+
+// SignedCertificateTimestampDataV2 holds an SCT, as the data of an
+// x509_sct_v2 or precert_sct_v2 TransItem (section 4.8).  The Signature is
+// over the TLS encoding of the TransItem for the corresponding entry.
+type SignedCertificateTimestampDataV2 struct {
+	LogID         LogIDV2
+	Timestamp     uint64
+	SCTExtensions []Extension `tls:"minlen:0,maxlen:65535"`
+	Signature     []byte      `tls:"minlen:0,maxlen:65535"`
+}
+
+// TreeHeadDataV2 holds the data over which the signature in a
+// SignedTreeHeadDataV2 is generated (section 4.9).
+type TreeHeadDataV2 struct {
+	Timestamp     uint64
+	TreeSize      uint64
+	RootHash      NodeHash
+	STHExtensions []Extension `tls:"minlen:0,maxlen:65535"`
+}
+
+// SignedTreeHeadDataV2 holds a signed tree head, as the data of a
+// signed_tree_head_v2 TransItem (section 4.10).  The Signature is over the
+// TLS encoding of the TreeHead.
+type SignedTreeHeadDataV2 struct {
+	LogID     LogIDV2
+	TreeHead  TreeHeadDataV2
+	Signature []byte `tls:"minlen:0,maxlen:65535"`
+}
+
+// ConsistencyProofDataV2 holds a consistency proof between two tree sizes,
+// as the data of a consistency_proof_v2 TransItem (section 4.11).
+type ConsistencyProofDataV2 struct {
+	LogID           LogIDV2
+	TreeSize1       uint64
+	TreeSize2       uint64
+	ConsistencyPath []NodeHash `tls:"minlen:0,maxlen:65535"`
+}
+
+// InclusionProofDataV2 holds an inclusion proof for a log entry, as the
+// data of an inclusion_proof_v2 TransItem (section 4.12).
+type InclusionProofDataV2 struct {
+	LogID         LogIDV2
+	TreeSize      uint64
+	LeafIndex     uint64
+	InclusionPath []NodeHash `tls:"minlen:0,maxlen:65535"`
+}
+
+// TransItem is the container for all CT v2 data structures (section 4.5).
+// Exactly one of the pointer fields is non-nil, as selected by
+// VersionedType.
+type TransItem struct {
+	VersionedType      VersionedTransType                 `tls:"maxval:65535"`
+	X509EntryV2        *TimestampedCertificateEntryDataV2 `tls:"selector:VersionedType,val:1"`
+	PrecertEntryV2     *TimestampedCertificateEntryDataV2 `tls:"selector:VersionedType,val:2"`
+	X509SCTV2          *SignedCertificateTimestampDataV2  `tls:"selector:VersionedType,val:3"`
+	PrecertSCTV2       *SignedCertificateTimestampDataV2  `tls:"selector:VersionedType,val:4"`
+	SignedTreeHeadV2   *SignedTreeHeadDataV2              `tls:"selector:VersionedType,val:5"`
+	ConsistencyProofV2 *ConsistencyProofDataV2            `tls:"selector:VersionedType,val:6"`
+	InclusionProofV2   *InclusionProofDataV2              `tls:"selector:VersionedType,val:7"`
+}
+
+// Entry returns the log entry held in the TransItem, or nil if it does not
+// hold an x509_entry_v2 or precert_entry_v2.
+func (t *TransItem) Entry() *TimestampedCertificateEntryDataV2 {
+	switch t.VersionedType {
+	case X509EntryV2VersionedType:
+		return t.X509EntryV2
+	case PrecertEntryV2VersionedType:
+		return t.PrecertEntryV2
+	default:
+		return nil
+	}
+}
+
+// SCT returns the SCT held in the TransItem, or nil if it does not hold an
+// x509_sct_v2 or precert_sct_v2.
+func (t *TransItem) SCT() *SignedCertificateTimestampDataV2 {
+	switch t.VersionedType {
+	case X509SCTV2VersionedType:
+		return t.X509SCTV2
+	case PrecertSCTV2VersionedType:
+		return t.PrecertSCTV2
+	default:
+		return nil
+	}
+}
+
+// FromBase64String populates the TransItem from the base64 data passed in.
+// Returns an error if the base64 data is invalid.
+func (t *TransItem) FromBase64String(b64 string) error {
+	raw, err := base64.StdEncoding.DecodeString(b64)
+	if err != nil {
+		return fmt.Errorf("failed to unbase64 TransItem: %v", err)
+	}
+	var item TransItem
+	if rest, err := tls.Unmarshal(raw, &item); err != nil {
+		return fmt.Errorf("failed to unmarshal TransItem: %v", err)
+	} else if len(rest) > 0 {
+		return fmt.Errorf("trailing data (%d bytes) after TransItem", len(rest))
+	}
+	*t = item
+	return nil
+}
+
+// Base64String returns the base64 representation of the TransItem.
+func (t TransItem) Base64String() (string, error) {
+	b, err := tls.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(b), nil
+}
+
+// MarshalJSON implements the json.Marshaller interface.
+func (t TransItem) MarshalJSON() ([]byte, error) {
+	b64, err := t.Base64String()
+	if err != nil {
+		return []byte{}, err
+	}
+	return []byte(`"` + b64 + `"`), nil
+}
+
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (t *TransItem) UnmarshalJSON(b []byte) error {
+	var content string
+	if err := json.Unmarshal(b, &content); err != nil {
+		return fmt.Errorf("failed to unmarshal TransItem: %v", err)
+	}
+	return t.FromBase64String(content)
+}
+
+// NewEntryTransItem returns the x509_entry_v2 or precert_entry_v2 TransItem
+// that an SCT of the given type is issued over.
+func NewEntryTransItem(sctType VersionedTransType, entry TimestampedCertificateEntryDataV2) (*TransItem, error) {
+	switch sctType {
+	case X509SCTV2VersionedType:
+		return &TransItem{VersionedType: X509EntryV2VersionedType, X509EntryV2: &entry}, nil
+	case PrecertSCTV2VersionedType:
+		return &TransItem{VersionedType: PrecertEntryV2VersionedType, PrecertEntryV2: &entry}, nil
+	default:
+		return nil, fmt.Errorf("no entry type corresponds to %v", sctType)
+	}
+}
+
+// SerializeSCTV2SignatureInput serializes the data over which the signature
+// in a v2 SCT is calculated, which is the TransItem for the log entry.
+func SerializeSCTV2SignatureInput(entry TransItem) ([]byte, error) {
+	if entry.Entry() == nil {
+		return nil, fmt.Errorf("cannot sign TransItem of type %v", entry.VersionedType)
+	}
+	return tls.Marshal(entry)
+}
+
+// SerializeSTHV2SignatureInput serializes the data over which the signature
+// in a v2 STH is calculated.
+func SerializeSTHV2SignatureInput(sth SignedTreeHeadDataV2) ([]byte, error) {
+	if len(sth.TreeHead.RootHash.Value) == 0 {
+		return nil, errors.New("STH has no root hash")
+	}
+	return tls.Marshal(sth.TreeHead)
+}
+
+// URI paths for CT v2 Log requests; see section 5.
+const (
+	SubmitEntryV2Path       = "/ct/v2/submit-entry"
+	GetSTHV2Path            = "/ct/v2/get-sth"
+	GetSTHConsistencyV2Path = "/ct/v2/get-sth-consistency"
+	GetProofByHashV2Path    = "/ct/v2/get-proof-by-hash"
+	GetAllByHashV2Path      = "/ct/v2/get-all-by-hash"
+	GetEntriesV2Path        = "/ct/v2/get-entries"
+	GetAnchorsV2Path        = "/ct/v2/get-anchors"
+)
+
+// SubmissionType indicates whether a submission to a v2 log is a
+// certificate or a precertificate (section 5.1).
+type SubmissionType int
+
+// SubmissionType constants from section 5.1.
+const (
+	X509SubmissionType    SubmissionType = 1
+	PrecertSubmissionType SubmissionType = 2
+)
+
+func (s SubmissionType) String() string {
+	switch s {
+	case X509SubmissionType:
+		return "X509Submission"
+	case PrecertSubmissionType:
+		return "PrecertSubmission"
+	default:
+		return fmt.Sprintf("UnknownSubmissionType(%d)", int(s))
+	}
+}
+
+// SCTType returns the type of SCT that a log issues for a submission of
+// this type.
+func (s SubmissionType) SCTType() (VersionedTransType, error) {
+	switch s {
+	case X509SubmissionType:
+		return X509SCTV2VersionedType, nil
+	case PrecertSubmissionType:
+		return PrecertSCTV2VersionedType, nil
+	default:
+		return 0, fmt.Errorf("no SCT type corresponds to %v", s)
+	}
+}
+
+// SubmitEntryV2Request represents the JSON request body sent to the v2
+// submit-entry POST method from section 5.1.  The same structure describes
+// the submitted_entry for each entry returned by get-entries.
+type SubmitEntryV2Request struct {
+	// Submission holds a DER-encoded certificate, or a DER-encoded CMS
+	// precertificate.
+	Submission []byte         `json:"submission"`
+	Type       SubmissionType `json:"type"`
+	// Chain holds the DER-encoded issuer of the submission followed by any
+	// further certificates needed to reach a trust anchor.
+	Chain [][]byte `json:"chain"`
+}
+
+// SubmitEntryV2Response represents the JSON response to the v2 submit-entry
+// POST method.  The STH and inclusion proof are only present if the log
+// has already incorporated the entry.
+type SubmitEntryV2Response struct {
+	SCT       TransItem  `json:"sct"`
+	STH       *TransItem `json:"sth,omitempty"`
+	Inclusion *TransItem `json:"inclusion,omitempty"`
+}
+
+// GetSTHV2Response represents the JSON response to the v2 get-sth GET method
+// from section 5.2.
+type GetSTHV2Response struct {
+	STH TransItem `json:"sth"`
+}
+
+// GetSTHConsistencyV2Response represents the JSON response to the v2
+// get-sth-consistency GET method from section 5.3.  (The corresponding GET
+// request has parameters 'first' and 'second'.)
+type GetSTHConsistencyV2Response struct {
+	Consistency TransItem `json:"consistency"`
+	STH         TransItem `json:"sth"`
+}
+
+// GetProofByHashV2Response represents the JSON response to the v2
+// get-proof-by-hash GET method from section 5.4.  (The corresponding GET
+// request has parameters 'hash' and 'tree_size'.)
+type GetProofByHashV2Response struct {
+	Inclusion TransItem `json:"inclusion"`
+	STH       TransItem `json:"sth"`
+}
+
+// GetAllByHashV2Response represents the JSON response to the v2
+// get-all-by-hash GET method from section 5.5.  (The corresponding GET
+// request has parameters 'hash' and 'tree_size'.)  The consistency proof is
+// between the requested tree size and the returned STH.
+type GetAllByHashV2Response struct {
+	Inclusion   TransItem `json:"inclusion"`
+	STH         TransItem `json:"sth"`
+	Consistency TransItem `json:"consistency"`
+}
+
+// EntryV2 represents a single log entry returned by the v2 get-entries GET
+// method.
+type EntryV2 struct {
+	// LogEntry holds an x509_entry_v2 or precert_entry_v2 TransItem.
+	LogEntry       TransItem            `json:"log_entry"`
+	SubmittedEntry SubmitEntryV2Request `json:"submitted_entry"`
+	// SCT holds an x509_sct_v2 or precert_sct_v2 TransItem.
+	SCT TransItem `json:"sct"`
+}
+
+// GetEntriesV2Response represents the JSON response to the v2 get-entries
+// GET method from section 5.6.  (The corresponding GET request has parameters
+// 'start' and 'end'.)
+type GetEntriesV2Response struct {
+	Entries []EntryV2 `json:"entries"`
+	STH     TransItem `json:"sth"`
+}
+
+// GetAnchorsV2Response represents the JSON response to the v2 get-anchors
+// GET method from section 5.7.
+type GetAnchorsV2Response struct {
+	Certificates   [][]byte `json:"certificates"`
+	MaxChainLength int      `json:"max_chain_length"`
+}
@@ -0,0 +1,372 @@
+// Copyright 2017 Google Inc. All Rights Reserved.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ct
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/tls"
+	"golang.org/x/crypto/ed25519"
+)
+
+var testLogIDV2 = LogIDV2{Value: []byte{0x2a, 0x03}} // OID 1.2.3
+
+func testNodeHash(b byte) NodeHash {
+	return NodeHash{Value: bytes.Repeat([]byte{b}, 32)}
+}
+
+func testEntryV2() TimestampedCertificateEntryDataV2 {
+	return TimestampedCertificateEntryDataV2{
+		Timestamp:      3,
+		IssuerKeyHash:  bytes.Repeat([]byte{0x33}, 32),
+		TBSCertificate: []byte{0x30, 0x00},
+		SCTExtensions:  []Extension{{ExtensionType: 1, ExtensionData: []byte{0xbe, 0xef}}},
+	}
+}
+
+func testSTHV2() SignedTreeHeadDataV2 {
+	return SignedTreeHeadDataV2{
+		LogID: testLogIDV2,
+		TreeHead: TreeHeadDataV2{
+			Timestamp:     1,
+			TreeSize:      2,
+			RootHash:      testNodeHash(0x11),
+			STHExtensions: []Extension{},
+		},
+		Signature: []byte{0xab, 0xcd},
+	}
+}
+
+func TestTransItemMarshalRoundTrip(t *testing.T) {
+	entry := testEntryV2()
+	sth := testSTHV2()
+	var tests = []struct {
+		item TransItem
+		want string // hex string
+	}{
+		{
+			item: TransItem{VersionedType: X509EntryV2VersionedType, X509EntryV2: &entry},
+			want: "0001" + "0000000000000003" + "20" + strings.Repeat("33", 32) + "000002" + "3000" + "0006" + "0001" + "0002" + "beef",
+		},
+		{
+			item: TransItem{
+				VersionedType: PrecertSCTV2VersionedType,
+				PrecertSCTV2: &SignedCertificateTimestampDataV2{
+					LogID:         testLogIDV2,
+					Timestamp:     3,
+					SCTExtensions: []Extension{},
+					Signature:     []byte{0x01, 0x02, 0x03},
+				},
+			},
+			want: "0004" + "022a03" + "0000000000000003" + "0000" + "0003" + "010203",
+		},
+		{
+			item: TransItem{VersionedType: SignedTreeHeadV2VersionedType, SignedTreeHeadV2: &sth},
+			want: "0005" + "022a03" + "0000000000000001" + "0000000000000002" + "20" + strings.Repeat("11", 32) + "0000" + "0002" + "abcd",
+		},
+		{
+			item: TransItem{
+				VersionedType: ConsistencyProofV2VersionedType,
+				ConsistencyProofV2: &ConsistencyProofDataV2{
+					LogID:           testLogIDV2,
+					TreeSize1:       1,
+					TreeSize2:       2,
+					ConsistencyPath: []NodeHash{testNodeHash(0x11), testNodeHash(0x22)},
+				},
+			},
+			want: "0006" + "022a03" + "0000000000000001" + "0000000000000002" + "0042" + "20" + strings.Repeat("11", 32) + "20" + strings.Repeat("22", 32),
+		},
+		{
+			item: TransItem{
+				VersionedType: InclusionProofV2VersionedType,
+				InclusionProofV2: &InclusionProofDataV2{
+					LogID:         testLogIDV2,
+					TreeSize:      2,
+					LeafIndex:     1,
+					InclusionPath: []NodeHash{testNodeHash(0x11)},
+				},
+			},
+			want: "0007" + "022a03" + "0000000000000002" + "0000000000000001" + "0021" + "20" + strings.Repeat("11", 32),
+		},
+	}
+	for _, test := range tests {
+		got, err := tls.Marshal(test.item)
+		if err != nil {
+			t.Errorf("tls.Marshal(%v)=nil,%v; want %s", test.item.VersionedType, err, test.want)
+			continue
+		}
+		if hex.EncodeToString(got) != test.want {
+			t.Errorf("tls.Marshal(%v)=%x; want %s", test.item.VersionedType, got, test.want)
+		}
+
+		var item TransItem
+		if rest, err := tls.Unmarshal(dh(test.want), &item); err != nil {
+			t.Errorf("tls.Unmarshal(%s)=_,%v; want _,nil", test.want, err)
+			continue
+		} else if len(rest) > 0 {
+			t.Errorf("tls.Unmarshal(%s) left %d bytes", test.want, len(rest))
+		}
+		if !reflect.DeepEqual(item, test.item) {
+			t.Errorf("tls.Unmarshal(%s)=%+v; want %+v", test.want, item, test.item)
+		}
+
+		b64, err := test.item.Base64String()
+		if err != nil {
+			t.Errorf("Base64String(%v)=_,%v", test.item.VersionedType, err)
+			continue
+		}
+		var fromB64 TransItem
+		if err := fromB64.FromBase64String(b64); err != nil {
+			t.Errorf("FromBase64String(%s)=%v", b64, err)
+		} else if !reflect.DeepEqual(fromB64, test.item) {
+			t.Errorf("FromBase64String(%s)=%+v; want %+v", b64, fromB64, test.item)
+		}
+	}
+}
+
+func TestUnmarshalTransItemErrors(t *testing.T) {
+	var tests = []struct {
+		in     string // hex string
+		errstr string
+	}{
+		{"0000", "unhandled value"},
+		{"0008", "unhandled value"},
+		// LogID too short.
+		{"0005" + "012a", "too small"},
+		// Root hash too short.
+		{"0005" + "022a03" + "0000000000000001" + "0000000000000002" + "01" + "11", "too small"},
+	}
+	for _, test := range tests {
+		var item TransItem
+		_, err := tls.Unmarshal(dh(test.in), &item)
+		if err == nil {
+			t.Errorf("tls.Unmarshal(%s)=nil; want error containing %q", test.in, test.errstr)
+		} else if !strings.Contains(err.Error(), test.errstr) {
+			t.Errorf("tls.Unmarshal(%s)=%v; want error containing %q", test.in, err, test.errstr)
+		}
+	}
+}
+
+func TestTransItemJSON(t *testing.T) {
+	sth := testSTHV2()
+	want := TransItem{VersionedType: SignedTreeHeadV2VersionedType, SignedTreeHeadV2: &sth}
+	data, err := json.Marshal(struct {
+		Item TransItem `json:"item"`
+	}{want})
+	if err != nil {
+		t.Fatalf("json.Marshal()=nil,%v; want data,nil", err)
+	}
+	var got struct {
+		Item TransItem `json:"item"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("json.Unmarshal(%s)=%v; want nil", data, err)
+	}
+	if !reflect.DeepEqual(got.Item, want) {
+		t.Errorf("json.Unmarshal(%s)=%+v; want %+v", data, got.Item, want)
+	}
+
+	for _, in := range []string{`{"item": 1}`, `{"item": "not base64"}`, `{"item": "AAA="}`} {
+		if err := json.Unmarshal([]byte(in), &got); err == nil {
+			t.Errorf("json.Unmarshal(%s)=nil; want error", in)
+		}
+	}
+}
+
+func TestLogIDV2OID(t *testing.T) {
+	for _, oid := range []asn1.ObjectIdentifier{
+		{1, 2, 3},
+		{1, 3, 101, 8192},
+		{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2},
+	} {
+		id, err := LogIDV2FromOID(oid)
+		if err != nil {
+			t.Errorf("LogIDV2FromOID(%v)=_,%v; want _,nil", oid, err)
+			continue
+		}
+		got, err := id.OID()
+		if err != nil {
+			t.Errorf("LogIDV2FromOID(%v).OID()=nil,%v; want %v,nil", oid, err, oid)
+			continue
+		}
+		if !got.Equal(oid) {
+			t.Errorf("LogIDV2FromOID(%v).OID()=%v; want %v", oid, got, oid)
+		}
+		if got, want := id.String(), oid.String(); got != want {
+			t.Errorf("LogIDV2FromOID(%v).String()=%q; want %q", oid, got, want)
+		}
+	}
+	if got, want := testLogIDV2.String(), "1.2.3"; got != want {
+		t.Errorf("LogIDV2.String()=%q; want %q", got, want)
+	}
+
+	// A single-byte encoding is too short for a LogID.
+	if id, err := LogIDV2FromOID(asn1.ObjectIdentifier{1, 2}); err == nil {
+		t.Errorf("LogIDV2FromOID(1.2)=%v,nil; want error", id)
+	}
+	for _, value := range [][]byte{{0x2a}, bytes.Repeat([]byte{0x2a}, 128), {0x2a, 0x83}} {
+		id := LogIDV2{Value: value}
+		if oid, err := id.OID(); err == nil {
+			t.Errorf("LogIDV2{%x}.OID()=%v,nil; want error", value, oid)
+		}
+	}
+}
+
+func TestNewEntryTransItem(t *testing.T) {
+	entry := testEntryV2()
+	var tests = []struct {
+		sctType VersionedTransType
+		want    VersionedTransType
+	}{
+		{X509SCTV2VersionedType, X509EntryV2VersionedType},
+		{PrecertSCTV2VersionedType, PrecertEntryV2VersionedType},
+	}
+	for _, test := range tests {
+		item, err := NewEntryTransItem(test.sctType, entry)
+		if err != nil {
+			t.Errorf("NewEntryTransItem(%v)=nil,%v; want item,nil", test.sctType, err)
+			continue
+		}
+		if item.VersionedType != test.want {
+			t.Errorf("NewEntryTransItem(%v).VersionedType=%v; want %v", test.sctType, item.VersionedType, test.want)
+		}
+		if got := item.Entry(); !reflect.DeepEqual(got, &entry) {
+			t.Errorf("NewEntryTransItem(%v).Entry()=%+v; want %+v", test.sctType, got, entry)
+		}
+	}
+	for _, sctType := range []VersionedTransType{X509EntryV2VersionedType, SignedTreeHeadV2VersionedType, 0} {
+		if item, err := NewEntryTransItem(sctType, entry); err == nil {
+			t.Errorf("NewEntryTransItem(%v)=%+v,nil; want error", sctType, item)
+		}
+	}
+}
+
+func TestVerifyV2Signatures(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("ed25519.GenerateKey()=%v", err)
+	}
+
+	for _, key := range []struct {
+		priv crypto.PrivateKey
+		pub  crypto.PublicKey
+	}{
+		{*ecKey, ecKey.Public()},
+		{edKey, edKey.Public()},
+	} {
+		sv := mustCreateSignatureVerifier(t, key.pub)
+		sign := func(data []byte) []byte {
+			ds, err := tls.CreateSignature(key.priv, tls.SHA256, data)
+			if err != nil {
+				t.Fatalf("CreateSignature()=%v", err)
+			}
+			return ds.Signature
+		}
+
+		sth := testSTHV2()
+		data, err := SerializeSTHV2SignatureInput(sth)
+		if err != nil {
+			t.Fatalf("SerializeSTHV2SignatureInput()=nil,%v", err)
+		}
+		sth.Signature = sign(data)
+		if err := sv.VerifySTHV2Signature(sth); err != nil {
+			t.Errorf("VerifySTHV2Signature(%T)=%v; want nil", key.pub, err)
+		}
+		sth.TreeHead.TreeSize++
+		if err := sv.VerifySTHV2Signature(sth); err == nil {
+			t.Errorf("VerifySTHV2Signature(%T, modified tree size)=nil; want error", key.pub)
+		}
+		sth.TreeHead.RootHash = NodeHash{}
+		if err := sv.VerifySTHV2Signature(sth); err == nil {
+			t.Errorf("VerifySTHV2Signature(%T, no root hash)=nil; want error", key.pub)
+		}
+
+		entry, err := NewEntryTransItem(PrecertSCTV2VersionedType, testEntryV2())
+		if err != nil {
+			t.Fatalf("NewEntryTransItem()=nil,%v", err)
+		}
+		data, err = SerializeSCTV2SignatureInput(*entry)
+		if err != nil {
+			t.Fatalf("SerializeSCTV2SignatureInput()=nil,%v", err)
+		}
+		sct := SignedCertificateTimestampDataV2{LogID: testLogIDV2, Timestamp: 3, Signature: sign(data)}
+		if err := sv.VerifySCTV2Signature(sct, *entry); err != nil {
+			t.Errorf("VerifySCTV2Signature(%T)=%v; want nil", key.pub, err)
+		}
+		entry.PrecertEntryV2.Timestamp++
+		if err := sv.VerifySCTV2Signature(sct, *entry); err == nil {
+			t.Errorf("VerifySCTV2Signature(%T, modified entry)=nil; want error", key.pub)
+		}
+		entry.PrecertEntryV2.Timestamp--
+		corruptBytes(sct.Signature)
+		if err := sv.VerifySCTV2Signature(sct, *entry); err == nil {
+			t.Errorf("VerifySCTV2Signature(%T, corrupt signature)=nil; want error", key.pub)
+		}
+		sctItem := TransItem{VersionedType: PrecertSCTV2VersionedType, PrecertSCTV2: &sct}
+		if err := sv.VerifySCTV2Signature(sct, sctItem); err == nil {
+			t.Errorf("VerifySCTV2Signature(%T, SCT as entry)=nil; want error", key.pub)
+		}
+	}
+}
+
+func TestVerifyV2SignaturesDisallowedKeys(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("rsa.GenerateKey()=%v", err)
+	}
+	p384Key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatalf("ecdsa.GenerateKey()=%v", err)
+	}
+
+	for _, key := range []struct {
+		priv crypto.PrivateKey
+		pub  crypto.PublicKey
+	}{
+		{*rsaKey, rsaKey.Public()},
+		{*p384Key, p384Key.Public()},
+	} {
+		// The signatures are valid, but the keys aren't allowed for v2.
+		sv := SignatureVerifier{pubKey: key.pub}
+		sth := testSTHV2()
+		data, err := SerializeSTHV2SignatureInput(sth)
+		if err != nil {
+			t.Fatalf("SerializeSTHV2SignatureInput()=nil,%v", err)
+		}
+		ds, err := tls.CreateSignature(key.priv, tls.SHA256, data)
+		if err != nil {
+			t.Fatalf("CreateSignature(%T)=%v", key.priv, err)
+		}
+		sth.Signature = ds.Signature
+		if err := sv.VerifySTHV2Signature(sth); err == nil {
+			t.Errorf("VerifySTHV2Signature(%T)=nil; want error", key.pub)
+		}
+	}
+}
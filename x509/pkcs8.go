@@ -8,6 +8,7 @@ import (
 	// START CT CHANGES
 	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/crypto/ed25519"
 	// END CT CHANGES
 	"errors"
 	"fmt"
@@ -50,6 +51,21 @@ func ParsePKCS8PrivateKey(der []byte) (key interface{}, err error) {
 		}
 		return key, nil
 
+	case privKey.Algo.Algorithm.Equal(oidPublicKeyEd25519):
+		// RFC 8410 s7: the private key is a CurvePrivateKey OCTET STRING
+		// holding the seed.
+		if len(privKey.Algo.Parameters.FullBytes) != 0 {
+			return nil, errors.New("x509: Ed25519 private key has unexpected parameters")
+		}
+		var seed []byte
+		if _, err := asn1.Unmarshal(privKey.PrivateKey, &seed); err != nil {
+			return nil, errors.New("x509: failed to parse Ed25519 private key embedded in PKCS#8: " + err.Error())
+		}
+		if len(seed) != ed25519.SeedSize {
+			return nil, errors.New("x509: wrong Ed25519 private key size")
+		}
+		return ed25519.NewKeyFromSeed(seed), nil
+
 	default:
 		return nil, fmt.Errorf("x509: PKCS#8 wrapping contained private key with unknown algorithm: %v", privKey.Algo.Algorithm)
 	}
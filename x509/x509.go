@@ -21,7 +21,9 @@ import (
 	_ "crypto/sha1"
 	// START CT CHANGES
 	"github.com/google/certificate-transparency-go/asn1"
+	"github.com/google/certificate-transparency-go/tls"
 	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/crypto/ed25519"
 	// END CT CHANGES
 	_ "crypto/sha256"
 	_ "crypto/sha512"
@@ -49,7 +51,7 @@ type pkixPublicKey struct {
 // types result in an error.
 //
 // On success, pub will be of type *rsa.PublicKey, *dsa.PublicKey,
-// or *ecdsa.PublicKey.
+// *ecdsa.PublicKey or ed25519.PublicKey.
 func ParsePKIXPublicKey(derBytes []byte) (pub interface{}, err error) {
 	var pki publicKeyInfo
 	if rest, err := asn1.Unmarshal(derBytes, &pki); err != nil {
@@ -93,8 +95,12 @@ func marshalPublicKey(pub interface{}) (publicKeyBytes []byte, publicKeyAlgorith
 			return
 		}
 		publicKeyAlgorithm.Parameters.FullBytes = paramBytes
+	case ed25519.PublicKey:
+		// RFC 8410 s3: the parameters must be absent.
+		publicKeyBytes = pub
+		publicKeyAlgorithm.Algorithm = oidPublicKeyEd25519
 	default:
-		return nil, pkix.AlgorithmIdentifier{}, errors.New("x509: only RSA and ECDSA public keys supported")
+		return nil, pkix.AlgorithmIdentifier{}, errors.New("x509: only RSA, ECDSA and Ed25519 public keys supported")
 	}
 
 	return publicKeyBytes, publicKeyAlgorithm, nil
@@ -232,6 +238,7 @@ const (
 	RSA
 	DSA
 	ECDSA
+	Ed25519
 )
 
 // OIDs for signature algorithms
@@ -461,10 +468,16 @@ func getSignatureAlgorithmFromAI(ai pkix.AlgorithmIdentifier) SignatureAlgorithm
 //
 // id-ecPublicKey OBJECT IDENTIFIER ::= {
 //       iso(1) member-body(2) us(840) ansi-X9-62(10045) keyType(2) 1 }
+//
+//
+// RFC 8410, 3 Curve25519 and Curve448 Algorithm Identifiers
+//
+// id-Ed25519 OBJECT IDENTIFIER ::= { 1 3 101 112 }
 var (
-	oidPublicKeyRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
-	oidPublicKeyDSA   = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 1}
-	oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyRSA     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 1}
+	oidPublicKeyDSA     = asn1.ObjectIdentifier{1, 2, 840, 10040, 4, 1}
+	oidPublicKeyECDSA   = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+	oidPublicKeyEd25519 = asn1.ObjectIdentifier{1, 3, 101, 112}
 )
 
 func getPublicKeyAlgorithmFromOID(oid asn1.ObjectIdentifier) PublicKeyAlgorithm {
@@ -475,6 +488,8 @@ func getPublicKeyAlgorithmFromOID(oid asn1.ObjectIdentifier) PublicKeyAlgorithm
 		return DSA
 	case oid.Equal(oidPublicKeyECDSA):
 		return ECDSA
+	case oid.Equal(oidPublicKeyEd25519):
+		return Ed25519
 	}
 	return UnknownPublicKeyAlgorithm
 }
@@ -705,6 +720,15 @@ type Certificate struct {
 	CRLDistributionPoints []string
 
 	PolicyIdentifiers []asn1.ObjectIdentifier
+
+	// START CT CHANGES
+	// RFC 6962 s3.3 embedded SCT list.  RawSCT holds the TLS-encoded
+	// SignedCertificateTimestampList from the extension, as parsed into
+	// SCTList.  When marshaling certificates, a non-empty SCTList is
+	// embedded (and RawSCT is ignored).
+	RawSCT  []byte
+	SCTList SignedCertificateTimestampList
+	// END CT CHANGES
 }
 
 // ErrUnsupportedAlgorithm results from attempting to perform an operation that
@@ -900,6 +924,69 @@ func (h UnhandledCriticalExtension) Error() string {
 // and returns the result, still as a DER-encoded TBSCertificate.  This function will
 // fail if there is not exactly 1 CT poison extension present.
 func RemoveCTPoison(tbsData []byte) ([]byte, error) {
+	return BuildPrecertTBS(tbsData, nil)
+}
+
+// RemoveSCTList takes a DER-encoded TBSCertificate and removes the embedded SCT
+// list extension, and returns the result, still as a DER-encoded TBSCertificate.
+// For a final certificate, this gives the TBSCertificate that was logged for
+// its precertificate (RFC 6962 s3.2), so that the embedded SCTs can be
+// verified.  This function will fail if there is not exactly 1 SCT list
+// extension present.
+func RemoveSCTList(tbsData []byte) ([]byte, error) {
+	tbs, err := parseTBSWithoutExtension(tbsData, OIDExtensionCTSCT, "SCT list")
+	if err != nil {
+		return nil, err
+	}
+	return marshalTBS(tbs)
+}
+
+// BuildPrecertTBS takes a DER-encoded precertificate TBSCertificate, removes
+// the CT poison extension and returns the result, still as a DER-encoded
+// TBSCertificate.  If preIssuer is non-nil, it is the Precertificate Signing
+// Certificate that issued the precertificate, and the issuer and authority key
+// ID of the result are changed to those of the CA that will issue the final
+// certificate, as described in RFC 6962 s3.2.  This function will fail if
+// there is not exactly 1 CT poison extension present.
+func BuildPrecertTBS(tbsData []byte, preIssuer *Certificate) ([]byte, error) {
+	tbs, err := parseTBSWithoutExtension(tbsData, OIDExtensionCTPoison, "CT poison")
+	if err != nil {
+		return nil, err
+	}
+	if preIssuer != nil {
+		// Use the raw issuer to avoid any chance of ASN.1 differences on
+		// re-encoding.
+		tbs.Issuer = asn1.RawValue{FullBytes: preIssuer.RawIssuer}
+
+		var issuerKeyID *pkix.Extension
+		for i, ext := range preIssuer.Extensions {
+			if ext.Id.Equal(oidExtensionAuthorityKeyId) {
+				issuerKeyID = &preIssuer.Extensions[i]
+				break
+			}
+		}
+		keyAt := -1
+		for i, ext := range tbs.Extensions {
+			if ext.Id.Equal(oidExtensionAuthorityKeyId) {
+				keyAt = i
+				break
+			}
+		}
+		switch {
+		case keyAt >= 0 && issuerKeyID != nil:
+			tbs.Extensions[keyAt].Value = issuerKeyID.Value
+		case keyAt >= 0:
+			tbs.Extensions = append(tbs.Extensions[:keyAt], tbs.Extensions[keyAt+1:]...)
+		case issuerKeyID != nil:
+			tbs.Extensions = append(tbs.Extensions, pkix.Extension{Id: oidExtensionAuthorityKeyId, Value: issuerKeyID.Value})
+		}
+	}
+	return marshalTBS(tbs)
+}
+
+// parseTBSWithoutExtension parses a DER-encoded TBSCertificate and removes
+// the single extension with the given OID from it.
+func parseTBSWithoutExtension(tbsData []byte, oid asn1.ObjectIdentifier, name string) (*tbsCertificate, error) {
 	var tbs tbsCertificate
 	rest, err := asn1.Unmarshal(tbsData, &tbs)
 	if err != nil {
@@ -907,27 +994,44 @@ func RemoveCTPoison(tbsData []byte) ([]byte, error) {
 	} else if rLen := len(rest); rLen > 0 {
 		return nil, fmt.Errorf("trailing data (%d bytes) after TBSCertificate", rLen)
 	}
-	poisonAt := -1
+	extAt := -1
 	for i, ext := range tbs.Extensions {
-		if ext.Id.Equal(OIDExtensionCTPoison) {
-			if poisonAt != -1 {
-				return nil, errors.New("multiple CT poison extensions present")
+		if ext.Id.Equal(oid) {
+			if extAt != -1 {
+				return nil, fmt.Errorf("multiple %s extensions present", name)
 			}
-			poisonAt = i
+			extAt = i
 		}
 	}
-	if poisonAt == -1 {
-		return nil, errors.New("no CT poison extension present")
+	if extAt == -1 {
+		return nil, fmt.Errorf("no %s extension present", name)
 	}
-	tbs.Extensions = append(tbs.Extensions[:poisonAt], tbs.Extensions[poisonAt+1:]...)
+	tbs.Extensions = append(tbs.Extensions[:extAt], tbs.Extensions[extAt+1:]...)
+	return &tbs, nil
+}
+
+func marshalTBS(tbs *tbsCertificate) ([]byte, error) {
 	tbs.Raw = nil
-	data, err := asn1.Marshal(tbs)
+	data, err := asn1.Marshal(*tbs)
 	if err != nil {
 		return nil, fmt.Errorf("failed to re-marshal TBSCertificate: %v", err)
 	}
 	return data, nil
 }
 
+// SerializedSCT holds a single TLS-encoded SignedCertificateTimestamp, as
+// found in a SignedCertificateTimestampList (RFC 6962 s3.3).
+type SerializedSCT struct {
+	Val []byte `tls:"minlen:1,maxlen:65535"`
+}
+
+// SignedCertificateTimestampList is the list of SCTs carried by the embedded
+// SCT certificate extension, and also by the OCSP and TLS SCT extensions
+// (RFC 6962 s3.3).  The ct package converts between this and parsed SCTs.
+type SignedCertificateTimestampList struct {
+	SCTList []SerializedSCT `tls:"minlen:1,maxlen:65535"`
+}
+
 // END CT CHANGES
 
 type basicConstraints struct {
@@ -1057,6 +1161,17 @@ func parsePublicKey(algo PublicKeyAlgorithm, keyData *publicKeyInfo) (interface{
 			Y:     y,
 		}
 		return pub, nil
+	case Ed25519:
+		// RFC 8410 s3: the parameters must be absent.
+		if len(keyData.Algorithm.Parameters.FullBytes) != 0 {
+			return nil, errors.New("x509: Ed25519 key has unexpected parameters")
+		}
+		if len(asn1Data) != ed25519.PublicKeySize {
+			return nil, errors.New("x509: wrong Ed25519 public key size")
+		}
+		pub := make(ed25519.PublicKey, ed25519.PublicKeySize)
+		copy(pub, asn1Data)
+		return pub, nil
 	default:
 		return nil, nil
 	}
@@ -1406,6 +1521,20 @@ func parseCertificate(in *certificate) (*Certificate, error) {
 					out.IssuingCertificateURL = append(out.IssuingCertificateURL, string(v.Location.Bytes))
 				}
 			}
+			// START CT CHANGES
+		} else if e.Id.Equal(OIDExtensionCTSCT) {
+			// RFC 6962 s3.3: an OCTET STRING holding a TLS-encoded
+			// SignedCertificateTimestampList.
+			if rest, err := asn1.Unmarshal(e.Value, &out.RawSCT); err != nil {
+				nfe.AddError(fmt.Errorf("x509: failed to parse SCT list extension: %v", err))
+			} else if len(rest) != 0 {
+				nfe.AddError(errors.New("x509: trailing data after SCT list extension"))
+			} else if rest, err := tls.Unmarshal(out.RawSCT, &out.SCTList); err != nil {
+				nfe.AddError(fmt.Errorf("x509: failed to parse SCT list: %v", err))
+			} else if len(rest) != 0 {
+				nfe.AddError(errors.New("x509: trailing data after SCT list"))
+			}
+			// END CT CHANGES
 		} else {
 			// Unknown extensions are recorded if critical.
 			unhandled = true
@@ -1524,6 +1653,8 @@ var (
 	oidExtensionAuthorityInfoAccess   = []int{1, 3, 6, 1, 5, 5, 7, 1, 1}
 	// OIDExtensionCTPoison is defined in RFC 6962 s3.1.
 	OIDExtensionCTPoison = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 3}
+	// OIDExtensionCTSCT is defined in RFC 6962 s3.3.
+	OIDExtensionCTSCT = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
 )
 
 var (
@@ -1564,7 +1695,7 @@ func marshalSANs(dnsNames, emailAddresses []string, ipAddresses []net.IP) (derBy
 }
 
 func buildExtensions(template *Certificate) (ret []pkix.Extension, err error) {
-	ret = make([]pkix.Extension, 10 /* maximum number of elements. */)
+	ret = make([]pkix.Extension, 11 /* maximum number of elements. */)
 	n := 0
 
 	if template.KeyUsage != 0 &&
@@ -1733,6 +1864,23 @@ func buildExtensions(template *Certificate) (ret []pkix.Extension, err error) {
 		n++
 	}
 
+	// START CT CHANGES
+	if len(template.SCTList.SCTList) > 0 &&
+		!oidInExtensions(OIDExtensionCTSCT, template.ExtraExtensions) {
+		ret[n].Id = OIDExtensionCTSCT
+		var sctList []byte
+		sctList, err = tls.Marshal(template.SCTList)
+		if err != nil {
+			return
+		}
+		ret[n].Value, err = asn1.Marshal(sctList)
+		if err != nil {
+			return
+		}
+		n++
+	}
+	// END CT CHANGES
+
 	// Adding another extension here? Remember to update the maximum number
 	// of elements in the make() at the top of the function.
 
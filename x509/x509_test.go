@@ -17,6 +17,7 @@ import (
 	// START CT CHANGES
 	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/crypto/ed25519"
 	// END CT CHANGES
 	"encoding/base64"
 	"encoding/hex"
@@ -82,6 +83,38 @@ func TestParsePKIXPublicKey(t *testing.T) {
 	}
 }
 
+func TestParsePKIXPublicKeyEd25519(t *testing.T) {
+	block, _ := pem.Decode([]byte(pemEd25519PublicKey))
+	pub, err := ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		t.Fatalf("Failed to parse Ed25519 public key: %s", err)
+	}
+	edPub, ok := pub.(ed25519.PublicKey)
+	if !ok {
+		t.Fatalf("Value returned from ParsePKIXPublicKey was %T, not an Ed25519 public key", pub)
+	}
+	pubBytes2, err := MarshalPKIXPublicKey(edPub)
+	if err != nil {
+		t.Fatalf("Failed to marshal Ed25519 public key: %s", err)
+	}
+	if !bytes.Equal(pubBytes2, block.Bytes) {
+		t.Errorf("Reserialization of public key didn't match. got %x, want %x", pubBytes2, block.Bytes)
+	}
+
+	shortBytes, err := MarshalPKIXPublicKey(edPub[1:])
+	if err != nil {
+		t.Fatalf("Failed to marshal short Ed25519 public key: %s", err)
+	}
+	if _, err := ParsePKIXPublicKey(shortBytes); err == nil {
+		t.Error("ParsePKIXPublicKey() of short Ed25519 key succeeded; want error")
+	}
+}
+
+var pemEd25519PublicKey = `-----BEGIN PUBLIC KEY-----
+MCowBQYDK2VwAyEArKV0M9OJptUBbjHDCq2LMnkhtNLO4vOmqtFFPI5vpoQ=
+-----END PUBLIC KEY-----
+`
+
 var pemPublicKey = `-----BEGIN PUBLIC KEY-----
 MIIBIjANBgkqhkiG9w0BAQEFAAOCAQ8AMIIBCgKCAQEA3VoPN9PKUjKFLMwOge6+
 wnDi8sbETGIx2FKXGgqtAKpzmem53kRGEQg8WeqRmp12wgp74TGpkEXsGae7RS1k
@@ -1112,6 +1145,160 @@ func TestRemoveCTPoison(t *testing.T) {
 	}
 }
 
+func TestBuildPrecertTBSWithPreIssuer(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %s", err)
+	}
+	for _, authorityKeyID := range [][]byte{{0x01, 0x02, 0x03}, nil} {
+		template := Certificate{
+			SerialNumber:   big.NewInt(1),
+			Subject:        pkix.Name{CommonName: "Precert Signer"},
+			Issuer:         pkix.Name{CommonName: "Final CA"},
+			NotBefore:      time.Unix(1000, 0),
+			NotAfter:       time.Now().AddDate(1, 0, 0),
+			AuthorityKeyId: authorityKeyID,
+		}
+		parent := template
+		parent.Subject = template.Issuer
+		derBytes, err := CreateCertificate(rand.Reader, &template, &parent, &priv.PublicKey, priv)
+		if err != nil {
+			t.Fatalf("CreateCertificate() failed: %v", err)
+		}
+		preIssuer, err := ParseCertificate(derBytes)
+		if err != nil {
+			t.Fatalf("ParseCertificate() failed: %v", err)
+		}
+
+		in, _ := hex.DecodeString(tbsPoisonMiddle)
+		data, err := BuildPrecertTBS(in, preIssuer)
+		if err != nil {
+			t.Fatalf("BuildPrecertTBS()=nil,%v; want data,nil", err)
+		}
+		var tbs tbsCertificate
+		if _, err := asn1.Unmarshal(data, &tbs); err != nil {
+			t.Fatalf("Failed to parse result of BuildPrecertTBS(): %v", err)
+		}
+		if !bytes.Equal(tbs.Issuer.FullBytes, preIssuer.RawIssuer) {
+			t.Errorf("BuildPrecertTBS(): issuer=%x; want %x", tbs.Issuer.FullBytes, preIssuer.RawIssuer)
+		}
+		var gotKeyID []byte
+		for _, ext := range tbs.Extensions {
+			if ext.Id.Equal(OIDExtensionCTPoison) {
+				t.Error("BuildPrecertTBS(): poison extension still present")
+			}
+			if ext.Id.Equal(oidExtensionAuthorityKeyId) {
+				var akid authKeyId
+				if _, err := asn1.Unmarshal(ext.Value, &akid); err != nil {
+					t.Fatalf("Failed to parse authority key ID: %v", err)
+				}
+				gotKeyID = akid.Id
+			}
+		}
+		if !bytes.Equal(gotKeyID, authorityKeyID) {
+			t.Errorf("BuildPrecertTBS(): authority key ID=%x; want %x", gotKeyID, authorityKeyID)
+		}
+	}
+}
+
+func TestRemoveSCTList(t *testing.T) {
+	withSCTs, _ := hex.DecodeString(tbsPoisonMiddle)
+	var tbs tbsCertificate
+	if _, err := asn1.Unmarshal(withSCTs, &tbs); err != nil {
+		t.Fatalf("Failed to parse TBSCertificate: %v", err)
+	}
+	for i, ext := range tbs.Extensions {
+		if ext.Id.Equal(OIDExtensionCTPoison) {
+			tbs.Extensions[i] = pkix.Extension{Id: OIDExtensionCTSCT, Value: []byte{0x04, 0x00}}
+		}
+	}
+	tbs.Raw = nil
+	withSCTs, err := asn1.Marshal(tbs)
+	if err != nil {
+		t.Fatalf("Failed to marshal TBSCertificate: %v", err)
+	}
+
+	got, err := RemoveSCTList(withSCTs)
+	if err != nil {
+		t.Fatalf("RemoveSCTList()=nil,%v; want data,nil", err)
+	}
+	if want, _ := hex.DecodeString(tbsNoPoison); !bytes.Equal(got, want) {
+		t.Errorf("RemoveSCTList()=%x; want %x", got, want)
+	}
+	if _, err := RemoveSCTList(got); err == nil {
+		t.Error("RemoveSCTList() without SCT list extension=_,nil; want error")
+	}
+}
+
+func TestEmbeddedSCTList(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate ECDSA key: %s", err)
+	}
+	makeTemplate := func() *Certificate {
+		return &Certificate{
+			SerialNumber: big.NewInt(1),
+			Subject:      pkix.Name{CommonName: "sct.example.com"},
+			NotBefore:    time.Unix(1000, 0),
+			NotAfter:     time.Now().AddDate(1, 0, 0),
+		}
+	}
+
+	sctList := SignedCertificateTimestampList{
+		SCTList: []SerializedSCT{{Val: []byte("sct one")}, {Val: []byte("sct two")}},
+	}
+	template := makeTemplate()
+	template.SCTList = sctList
+	derBytes, err := CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate() failed: %v", err)
+	}
+	cert, err := ParseCertificate(derBytes)
+	if err != nil {
+		t.Fatalf("ParseCertificate() failed: %v", err)
+	}
+	if !reflect.DeepEqual(cert.SCTList, sctList) {
+		t.Errorf("SCTList=%+v; want %+v", cert.SCTList, sctList)
+	}
+	// Overall length, then each SCT preceded by its length.
+	wantRaw := []byte("\x00\x12\x00\x07sct one\x00\x07sct two")
+	if !bytes.Equal(cert.RawSCT, wantRaw) {
+		t.Errorf("RawSCT=%x; want %x", cert.RawSCT, wantRaw)
+	}
+
+	// A certificate without the extension has no SCTs.
+	template = makeTemplate()
+	derBytes, err = CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+	if err != nil {
+		t.Fatalf("CreateCertificate() failed: %v", err)
+	}
+	if cert, err = ParseCertificate(derBytes); err != nil {
+		t.Fatalf("ParseCertificate() failed: %v", err)
+	}
+	if len(cert.RawSCT) != 0 || len(cert.SCTList.SCTList) != 0 {
+		t.Errorf("ParseCertificate() without extension: RawSCT=%x, SCTList=%+v; want empty", cert.RawSCT, cert.SCTList)
+	}
+
+	// A malformed extension is reported as a non-fatal error.
+	for _, value := range [][]byte{
+		{0x01, 0x02},             // not an OCTET STRING
+		{0x04, 0x02, 0x00, 0x05}, // truncated list
+	} {
+		template = makeTemplate()
+		template.ExtraExtensions = []pkix.Extension{{Id: OIDExtensionCTSCT, Value: value}}
+		derBytes, err = CreateCertificate(rand.Reader, template, template, &priv.PublicKey, priv)
+		if err != nil {
+			t.Fatalf("CreateCertificate() failed: %v", err)
+		}
+		cert, err = ParseCertificate(derBytes)
+		if _, ok := err.(NonFatalErrors); !ok {
+			t.Errorf("ParseCertificate(SCT list %x)=_,%v; want NonFatalErrors", value, err)
+		} else if cert == nil {
+			t.Errorf("ParseCertificate(SCT list %x)=nil,%v; want cert", value, err)
+		}
+	}
+}
+
 // END CT CHANGES
 
 func TestImports(t *testing.T) {
@@ -29,6 +29,7 @@ import (
 	"github.com/google/certificate-transparency-go/asn1"
 	"github.com/google/certificate-transparency-go/x509"
 	"github.com/google/certificate-transparency-go/x509/pkix"
+	"golang.org/x/crypto/ed25519"
 )
 
 //////////////////////////////////////
@@ -170,6 +171,8 @@ func publicKeyAlgorithmToString(algo x509.PublicKeyAlgorithm) string {
 		return "dsaEncryption"
 	case x509.ECDSA:
 		return "id-ecPublicKey"
+	case x509.Ed25519:
+		return "ED25519"
 	default:
 		return strconv.Itoa(int(algo))
 	}
@@ -239,6 +242,9 @@ func publicKeyToString(algo x509.PublicKeyAlgorithm, pub interface{}) string {
 		appendHexData(&buf, data, 15, "                    ")
 		buf.WriteString("\n")
 		buf.WriteString(fmt.Sprintf("                ASN1 OID: %s", oidname))
+	case ed25519.PublicKey:
+		buf.WriteString("                pub:\n")
+		appendHexData(&buf, pub, 15, "                    ")
 	default:
 		buf.WriteString(fmt.Sprintf("%v", pub))
 	}